@@ -18,7 +18,7 @@ func main() {
 	cmd := command.New()
 
 	// Run the command
-	if err := cmd.Run(ctx); err != nil {
+	if err := cmd.Run(ctx, os.Args[1:]); err != nil {
 		os.Exit(1)
 	}
 }
@@ -2,33 +2,73 @@ package ocr
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"regexp"
-	"strings"
+	"path"
+	"slices"
 	"sync/atomic"
 	"time"
 )
 
+// DefaultMaxImageDimension is the longest side, in pixels, images are resized to when none is configured
+const DefaultMaxImageDimension = 1500
+
+const (
+	// leftPage and rightPage follow the name of a spread to name its pages, e.g. IMG_0001.jpg#L
+	leftPage  = "#L"
+	rightPage = "#R"
+)
+
 // AppConfig contains only the configuration parameters needed by the app
 type AppConfig struct {
-	Concurrency int
-	StartDate   string
+	Concurrency       int
+	StartDate         string
+	MaxImageDimension int
+	// DateParser finds and formats the dates of the entries (default: English, month first, ISO 8601)
+	DateParser *DateParser
+	// SplitByDate saves the results of each entry date to their own output instead of a single one
+	SplitByDate bool
+	// SplitByDirectory saves the results of the images of each subdirectory to their own output, and those of the
+	// images of the base directory to the usual output
+	SplitByDirectory bool
+	// MaxCost is the most a run may spend on OCR, in dollars, or 0 for no limit
+	MaxCost float64
+	// PromptPreset is the name of the prompt preset or template file of the OCR client, recorded in each result
+	PromptPreset string
+	// Preprocessor cleans up each image after it is resized, or nil to send the resized image as it is
+	Preprocessor Preprocessor
+	// Tiler splits each image into tiles that are transcribed on their own and stitched back together,
+	// or nil to send each image whole
+	Tiler Tiler
+	// Splitter splits open-book spreads into their left and right pages, which get results of their own,
+	// or nil to process each image as it is
+	Splitter Splitter
 }
 
 // ProcessImageResults contains the results of processing images
 type ProcessImageResults struct {
 	TotalImagesProcessed int
+	TotalImagesResumed   int
+	TotalImagesSkipped   int
 	TotalCost            float64
 	CostPerImage         float64
 	TotalOCRAttempts     int
 	OCRAttemptsPerImage  float64
 	TotalDuration        time.Duration
 	DurationPerImage     time.Duration
+	// BudgetExceeded is set when images were skipped because the cost of the run reached MaxCost
+	BudgetExceeded bool
 }
 
 func (r ProcessImageResults) String() string {
-	return fmt.Sprintf("total images processed: %d\ntotal cost:             $%.3f\ncost per image:         $%.3f\ntotal ocr attempts:     %d\nocr attempts per image: %.2f\ntotal duration:         %s\nduration per image:     %s\n",
-		r.TotalImagesProcessed, r.TotalCost, r.CostPerImage, r.TotalOCRAttempts, r.OCRAttemptsPerImage,
+	var budget string
+	if r.BudgetExceeded {
+		budget = fmt.Sprintf("budget exceeded:        %d images not processed\n", r.TotalImagesSkipped)
+	}
+	return budget + fmt.Sprintf("total images processed: %d\ntotal images resumed:   %d\ntotal cost:             $%.3f\ncost per image:         $%.3f\ntotal ocr attempts:     %d\nocr attempts per image: %.2f\ntotal duration:         %s\nduration per image:     %s\n",
+		r.TotalImagesProcessed, r.TotalImagesResumed, r.TotalCost, r.CostPerImage, r.TotalOCRAttempts, r.OCRAttemptsPerImage,
 		r.TotalDuration.Round(time.Millisecond), r.DurationPerImage.Round(time.Millisecond))
 }
 
@@ -38,16 +78,31 @@ type App struct {
 	repo            Repository
 	resizer         Resizer
 	progressUpdater ProgressUpdater
+	checkpoints     CheckpointStore
+	formatter       OutputFormatter
+	dates           *DateParser
 	config          *AppConfig
 }
 
-// NewApp creates a new App instance with the given configuration
-func NewApp(ocrClient OCRClient, repo Repository, resizer Resizer, progressUpdater ProgressUpdater, config *AppConfig) *App {
+// NewApp creates a new App instance with the given configuration.
+// progressUpdater and checkpoints are optional and may be nil. A nil formatter uses the TextFormatter.
+// ocrClient may be nil when the app is only used for EstimateImages.
+func NewApp(ocrClient OCRClient, repo Repository, resizer Resizer, progressUpdater ProgressUpdater, checkpoints CheckpointStore, formatter OutputFormatter, config *AppConfig) *App {
+	if formatter == nil {
+		formatter = TextFormatter{}
+	}
+	dates := config.DateParser
+	if dates == nil {
+		dates, _ = NewDateParser(DateParserConfig{})
+	}
 	return &App{
 		ocrClient:       ocrClient,
 		repo:            repo,
 		resizer:         resizer,
 		progressUpdater: progressUpdater,
+		checkpoints:     checkpoints,
+		formatter:       formatter,
+		dates:           dates,
 		config:          config,
 	}
 }
@@ -67,48 +122,144 @@ func (a *App) ProcessImages(ctx context.Context) (*ProcessImageResults, error) {
 		return nil, ErrNoImagesFound
 	}
 
-	// Process images in parallel
-	results := a.processImagesParallel(ctx, imageNames)
+	// Load the results saved by previous runs
+	saved := map[string]OCRResult{}
+	if a.checkpoints != nil {
+		if saved, err = a.checkpoints.LoadCheckpoints(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrCheckpointFailed, err)
+		}
+	}
+
+	// Process images in parallel, within the budget of the run
+	results := a.processImagesParallel(ctx, imageNames, saved, newBudget(a.config.MaxCost))
+
+	// Summarize the run
+	summary := summarize(results)
+
+	// Format and save one output per directory, each with the summary of its own images
+	if a.config.SplitByDirectory {
+		dirs, groups := groupByDirectory(results)
+		for _, dir := range dirs {
+			if err := a.saveOutput(dir, groups[dir], summarize(groups[dir])); err != nil {
+				return nil, err
+			}
+		}
+		return summary, nil
+	}
+
+	if err := a.saveOutput(".", results, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// saveOutput formats and saves the output of the results of a directory, "." for the base directory,
+// or one output per entry date when the results are split by date
+func (a *App) saveOutput(dir string, results []OCRResult, summary *ProcessImageResults) error {
+	if a.config.SplitByDate {
+		outputs, err := a.formatOutputs(results, summary)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrProcessingFailed, err)
+		}
+		if dir == "." {
+			err = a.repo.SaveOutputs(outputs)
+		} else {
+			err = a.repo.SaveDirectoryOutputs(dir, outputs)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrProcessingFailed, err)
+		}
+		return nil
+	}
 
 	// Format and concatenate output
-	output := a.formatOutput(results, a.config.StartDate)
+	output, err := a.formatOutput(results, summary)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProcessingFailed, err)
+	}
 
 	// Save output
-	if err := a.repo.SaveOutput(output); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrProcessingFailed, err)
+	if dir == "." {
+		err = a.repo.SaveOutput(output)
+	} else {
+		err = a.repo.SaveDirectoryOutput(dir, output)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProcessingFailed, err)
+	}
+	return nil
+}
+
+// groupByDirectory groups the results, in page order, by the directory of their image, "." for the base directory.
+// The directories are returned in the order of their first image.
+func groupByDirectory(results []OCRResult) ([]string, map[string][]OCRResult) {
+	var dirs []string
+	groups := map[string][]OCRResult{}
+	for _, result := range results {
+		dir := path.Dir(result.ImageName)
+		if _, ok := groups[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		groups[dir] = append(groups[dir], result)
 	}
+	return dirs, groups
+}
 
-	// Calculate total cost, total attempts, and total duration
+// summarize calculates the totals of the run
+func summarize(results []OCRResult) *ProcessImageResults {
+	// Calculate total cost, total attempts, and total duration of this run, leaving out resumed and skipped images
 	var totalCost float64
 	var totalAttempts int
 	var totalDuration time.Duration
+	var totalResumed, totalSkipped int
 	for _, result := range results {
+		if result.Resumed {
+			totalResumed++
+			continue
+		}
+		if errors.Is(result.Error, ErrBudgetExceeded) {
+			totalSkipped++
+			continue
+		}
 		totalCost += result.Cost
 		totalAttempts += result.OCRAttempts
 		totalDuration += result.Duration
 	}
 
 	// Return results
+	processed := len(results) - totalResumed - totalSkipped
 	return &ProcessImageResults{
-		TotalImagesProcessed: len(results),
+		TotalImagesProcessed: len(results) - totalSkipped,
+		TotalImagesResumed:   totalResumed,
+		TotalImagesSkipped:   totalSkipped,
 		TotalCost:            totalCost,
-		CostPerImage:         totalCost / float64(len(results)),
+		CostPerImage:         perImage(totalCost, processed),
 		TotalOCRAttempts:     totalAttempts,
-		OCRAttemptsPerImage:  float64(totalAttempts) / float64(len(results)),
+		OCRAttemptsPerImage:  perImage(float64(totalAttempts), processed),
 		TotalDuration:        totalDuration,
-		DurationPerImage:     totalDuration / time.Duration(len(results)),
-	}, nil
+		DurationPerImage:     time.Duration(perImage(float64(totalDuration), processed)),
+		BudgetExceeded:       totalSkipped > 0,
+	}
+}
+
+// perImage divides total by count, returning 0 when no images were processed
+func perImage(total float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return total / float64(count)
 }
 
-// processImagesParallel processes images in parallel with configurable concurrency
-func (a *App) processImagesParallel(ctx context.Context, imageNames []string) []OCRResult {
+// processImagesParallel processes images in parallel with configurable concurrency.
+// Once the budget is exceeded no more images are sent for OCR, but the images in flight are finished.
+func (a *App) processImagesParallel(ctx context.Context, imageNames []string, saved map[string]OCRResult, budget *budget) []OCRResult {
 	concurrency := a.config.Concurrency
 	if concurrency <= 0 {
 		concurrency = 10
 	}
 
-	// Pre-allocate results slice
-	results := make([]OCRResult, len(imageNames))
+	// Pre-allocate results slice, with the results of the pages of each image
+	results := make([][]OCRResult, len(imageNames))
 	total := len(imageNames)
 	var completed int64
 
@@ -121,14 +272,14 @@ func (a *App) processImagesParallel(ctx context.Context, imageNames []string) []
 		// Check if context is cancelled
 		select {
 		case <-ctx.Done():
-			return results
+			return slices.Concat(results...)
 		default:
 		}
 
 		sem <- struct{}{}
 		go func(idx int, name string) {
 			// Process image and write directly to results at index
-			results[idx] = a.processImage(ctx, name)
+			results[idx] = a.processImage(ctx, PageInfo{Name: name, Index: idx + 1}, saved, budget)
 
 			// Update progress after processing
 			if a.progressUpdater != nil {
@@ -145,110 +296,227 @@ func (a *App) processImagesParallel(ctx context.Context, imageNames []string) []
 		sem <- struct{}{}
 	}
 
+	return slices.Concat(results...)
+}
+
+// processImage processes a single image, or the left and right pages of a spread when spreads are split
+func (a *App) processImage(ctx context.Context, page PageInfo, saved map[string]OCRResult, budget *budget) []OCRResult {
+	startTime := time.Now()
+	names, pages, err := a.loadPages(page.Name)
+	if err != nil {
+		return []OCRResult{{ImageName: page.Name, PromptPreset: a.config.PromptPreset, Error: err, Duration: time.Since(startTime)}}
+	}
+
+	results := make([]OCRResult, 0, len(pages))
+	for i, imageData := range pages {
+		results = append(results, a.processPage(ctx, PageInfo{Name: names[i], Index: page.Index}, imageData, saved, budget))
+	}
 	return results
 }
 
-// processImage processes a single image
-func (a *App) processImage(ctx context.Context, imageName string) OCRResult {
+// processPage processes a single page, reusing its saved result when one matches.
+// Pages that are not saved are skipped with ErrBudgetExceeded when the budget does not allow them.
+func (a *App) processPage(ctx context.Context, page PageInfo, imageData []byte, saved map[string]OCRResult, budget *budget) OCRResult {
 	startTime := time.Now()
 
 	var result OCRResult
-	result.ImageName = imageName
+	result.ImageName = page.Name
+	result.PromptPreset = a.config.PromptPreset
 
-	// Load image (uses repository's base directory)
-	imageData, err := a.repo.LoadImageByName(imageName)
+	// Resize the image, or cut it into tiles when it is tiled
+	tiles, err := a.prepareImage(page.Name, imageData)
 	if err != nil {
 		result.Error = err
 		result.Duration = time.Since(startTime)
 		return result
 	}
 
-	// Resize if needed (max 1500px on longest side)
-	imageData, err = a.resizer.ResizeImage(imageData, 1500)
-	if err != nil {
-		result.Error = err
+	// Skip OCR if a previous run already transcribed this image with the same model and prompt
+	var key string
+	if a.checkpoints != nil {
+		key = a.checkpointKey(tiles, page)
+		if savedResult, ok := saved[key]; ok {
+			savedResult.ImageName = page.Name
+			savedResult.Resumed = true
+			// Find the date again, since the date settings may have changed since it was saved
+			date := a.findDate(savedResult.Date, savedResult.Text)
+			savedResult.Date, savedResult.ParsedDate = date.Text, date.Time
+			return savedResult
+		}
+	}
+
+	// Skip the image without a checkpoint, so the next run sends it for OCR
+	if !budget.start() {
+		result.Error = ErrBudgetExceeded
 		result.Duration = time.Since(startTime)
 		return result
 	}
 
 	// Perform OCR
-	text, cost, attempts, err := a.ocrClient.OCRImage(ctx, imageData)
+	transcription, cost, attempts, err := a.ocrTiles(ctx, tiles, page)
+	budget.finish(cost)
+	result.Attempts = attempts
+	result.OCRAttempts = len(attempts)
+	if len(attempts) > 0 {
+		result.PromptHash = attempts[len(attempts)-1].PromptHash
+	}
 	if err != nil {
+		result.Cost = cost
 		result.Error = err
 		result.Duration = time.Since(startTime)
 		return result
 	}
 
 	// Return the result
-	result.Date = extractDate(text)
-	result.Text = text
+	date := a.findDate(transcription.Date, transcription.Text)
+	result.Date = date.Text
+	result.ParsedDate = date.Time
+	result.Text = transcription.Text
+	result.PageNumber = transcription.PageNumber
+	result.IllegibleSegments = transcription.IllegibleSegments
 	result.Cost = cost
-	result.OCRAttempts = attempts
 	result.Duration = time.Since(startTime)
+
+	// Save the result right away so it survives an interrupted run.
+	// A failed save only means this image is sent for OCR again next time.
+	if a.checkpoints != nil {
+		_ = a.checkpoints.SaveCheckpoint(key, result)
+	}
+
 	return result
 }
 
-// extractDate extracts a date from the beginning of the text
-// Looks for common date patterns at the top of the page
-func extractDate(text string) string {
-	lines := strings.Split(text, "\n")
-	// Check first 5 lines for date
-	for i := 0; i < len(lines) && i < 5; i++ {
-		line := strings.TrimSpace(lines[i])
-		if line == "" {
-			continue
+// ocrTiles transcribes the tiles of an image one after the other and stitches their text together from top to
+// bottom. The date and page number are those of the first tile that has one, and the cost and attempts are those
+// of all tiles. A tile that fails fails the image.
+func (a *App) ocrTiles(ctx context.Context, tiles [][]byte, page PageInfo) (Transcription, float64, []Attempt, error) {
+	if len(tiles) == 1 {
+		return a.ocrClient.OCRImage(ctx, tiles[0], page)
+	}
+
+	var combined Transcription
+	var totalCost float64
+	var allAttempts []Attempt
+	texts := make([]string, 0, len(tiles))
+	for _, tile := range tiles {
+		transcription, cost, attempts, err := a.ocrClient.OCRImage(ctx, tile, page)
+		totalCost += cost
+		allAttempts = append(allAttempts, attempts...)
+		if err != nil {
+			return Transcription{}, totalCost, allAttempts, err
 		}
-		// Try to match common date patterns
-		datePatterns := []*regexp.Regexp{
-			regexp.MustCompile(`(?i)(\w+day,?\s+)?(\w+\s+\d{1,2},?\s+\d{4})`), // "Monday, January 1, 2024" or "January 1, 2024"
-			regexp.MustCompile(`(?i)(\d{1,2}[/-]\d{1,2}[/-]\d{2,4})`),         // "1/1/2024" or "01-01-2024"
-			regexp.MustCompile(`(?i)(\w+\s+\d{1,2},?\s+\d{4})`),               // "January 1, 2024"
+		texts = append(texts, transcription.Text)
+		if combined.Date == "" {
+			combined.Date = transcription.Date
 		}
-		for _, pattern := range datePatterns {
-			if match := pattern.FindString(line); match != "" {
-				return match
-			}
+		if combined.PageNumber == "" {
+			combined.PageNumber = transcription.PageNumber
 		}
+		combined.IllegibleSegments = append(combined.IllegibleSegments, transcription.IllegibleSegments...)
 	}
-	return ""
+	combined.Text = stitch(texts)
+	return combined, totalCost, allAttempts, nil
 }
 
-// formatOutput formats the results into the final output string
-func (a *App) formatOutput(results []OCRResult, startDate string) string {
-	var builder strings.Builder
-	lastDate := startDate
-
-	for _, result := range results {
-		// Horizontal rule
-		builder.WriteString("---\n")
+// loadPages loads an image from the repository and, when spreads are split, cuts a spread into its left and right
+// pages, named after the image and the side, e.g. IMG_0001.jpg#L. Any other image is a single page of its own name.
+func (a *App) loadPages(imageName string) ([]string, [][]byte, error) {
+	imageData, err := a.repo.LoadImageByName(imageName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.config.Splitter == nil {
+		return []string{imageName}, [][]byte{imageData}, nil
+	}
 
-		// Image name
-		builder.WriteString(result.ImageName)
-		builder.WriteString("\n")
+	pages, err := a.config.Splitter.SplitSpread(imageData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(pages) == 2 {
+		return []string{imageName + leftPage, imageName + rightPage}, pages, nil
+	}
+	return []string{imageName}, [][]byte{imageData}, nil
+}
 
-		if result.Error != nil {
-			builder.WriteString("Error: ")
-			builder.WriteString(result.Error.Error())
-			builder.WriteString("\n")
-			continue
-		}
+// prepareImage resizes an image for OCR (max 1500px on longest side by default) and preprocesses it.
+// A tiled image is returned as its bands, and any other image as a single tile.
+func (a *App) prepareImage(imageName string, imageData []byte) ([][]byte, error) {
+	maxDimension := a.config.MaxImageDimension
+	if maxDimension <= 0 {
+		maxDimension = DefaultMaxImageDimension
+	}
+	var tiles [][]byte
+	var err error
+	if a.config.Tiler != nil {
+		tiles, err = a.config.Tiler.TileImage(imageData, maxDimension)
+	} else {
+		imageData, err = a.resizer.ResizeImage(imageData, maxDimension)
+		tiles = [][]byte{imageData}
+	}
+	if err != nil || a.config.Preprocessor == nil {
+		return tiles, err
+	}
 
-		// Date (use extracted date or carry forward)
-		date := result.Date
-		if date == "" {
-			date = lastDate
-		} else {
-			lastDate = date
+	for i, tile := range tiles {
+		// The tiles are named apart, e.g. for the debug images of the preprocessor
+		name := imageName
+		if len(tiles) > 1 {
+			name = fmt.Sprintf("%s#t%d", imageName, i+1)
 		}
-		if date != "" {
-			builder.WriteString(date)
-			builder.WriteString("\n")
+		if tiles[i], err = a.config.Preprocessor.Preprocess(name, tile); err != nil {
+			return nil, err
 		}
+	}
+	return tiles, nil
+}
 
-		// Transcript
-		builder.WriteString(result.Text)
-		builder.WriteString("\n")
+// findDate returns the date reported by the model when it can be parsed, or else the date at the top of the text
+func (a *App) findDate(reported, text string) Date {
+	if date, ok := a.dates.Parse(reported); ok {
+		return date
 	}
+	date, _ := a.dates.Extract(text)
+	return date
+}
 
-	return builder.String()
+// checkpointKey identifies a result by the content of the image, or of its tiles, and the client's model and the
+// prompt of the page
+func (a *App) checkpointKey(tiles [][]byte, page PageInfo) string {
+	hash := sha256.New()
+	for _, tile := range tiles {
+		hash.Write(tile)
+		hash.Write([]byte{0})
+	}
+	hash.Write([]byte(a.ocrClient.Fingerprint(page)))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// formatOutput assigns the entry dates and formats the results into the final output string
+func (a *App) formatOutput(results []OCRResult, summary *ProcessImageResults) (string, error) {
+	assignEntryDates(results, a.config.StartDate, a.dates)
+	return a.formatter.Format(results, summary)
+}
+
+// formatOutputs assigns the entry dates and formats the results of each entry date, in page order,
+// into their own output keyed by the entry date's output name
+func (a *App) formatOutputs(results []OCRResult, summary *ProcessImageResults) (map[string]string, error) {
+	assignEntryDates(results, a.config.StartDate, a.dates)
+
+	// Group the results by entry date, keeping them in page order
+	groups := map[string][]OCRResult{}
+	for _, result := range results {
+		name := entryOutputName(result)
+		groups[name] = append(groups[name], result)
+	}
+
+	outputs := make(map[string]string, len(groups))
+	for name, group := range groups {
+		output, err := a.formatter.Format(group, summary)
+		if err != nil {
+			return nil, err
+		}
+		outputs[name] = output
+	}
+	return outputs, nil
 }
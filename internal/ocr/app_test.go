@@ -2,14 +2,24 @@ package ocr
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+// attempts returns a history of n successful attempts
+func attempts(n int) []Attempt {
+	return make([]Attempt, n)
+}
+
 func TestApp_ProcessImages(t *testing.T) {
 	t.Run("successful processing", func(t *testing.T) {
 		// Create temporary directory with test images
@@ -42,8 +52,8 @@ func TestApp_ProcessImages(t *testing.T) {
 
 		// Setup OCR client mocks
 		mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
-		mockClient.On("OCRImage", mock.Anything, []byte("image1")).Return("Monday, January 1, 2024\nTest text 1", 0.01, 1, nil)
-		mockClient.On("OCRImage", mock.Anything, []byte("image2")).Return("Test text 2", 0.01, 1, nil)
+		mockClient.On("OCRImage", mock.Anything, []byte("image1"), mock.Anything).Return(Transcription{Text: "Monday, January 1, 2024\nTest text 1"}, 0.01, attempts(1), nil)
+		mockClient.On("OCRImage", mock.Anything, []byte("image2"), mock.Anything).Return(Transcription{Text: "Test text 2"}, 0.01, attempts(1), nil)
 
 		// Create app config
 		config := &AppConfig{
@@ -52,7 +62,7 @@ func TestApp_ProcessImages(t *testing.T) {
 		}
 
 		// Create app and process
-		app := NewApp(mockClient, mockRepo, mockResizer, nil, config)
+		app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, config)
 
 		results, err := app.ProcessImages(context.Background())
 		assert.NoError(t, err)
@@ -92,7 +102,7 @@ func TestApp_ProcessImages(t *testing.T) {
 		config := &AppConfig{
 			Concurrency: 2,
 		}
-		app := NewApp(mockClient, mockRepo, mockResizer, nil, config)
+		app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, config)
 
 		_, err = app.ProcessImages(context.Background())
 		assert.Error(t, err)
@@ -113,7 +123,7 @@ func TestApp_ProcessImages(t *testing.T) {
 		config := &AppConfig{
 			Concurrency: 2,
 		}
-		app := NewApp(mockClient, mockRepo, mockResizer, nil, config)
+		app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, config)
 
 		_, err := app.ProcessImages(context.Background())
 		assert.Error(t, err)
@@ -124,7 +134,7 @@ func TestApp_ProcessImages(t *testing.T) {
 
 func TestApp_formatOutput(t *testing.T) {
 	mockResizer := new(MockResizer)
-	app := NewApp(nil, nil, mockResizer, nil, &AppConfig{})
+	app := NewApp(nil, nil, mockResizer, nil, nil, nil, &AppConfig{StartDate: "Sunday, December 31, 2023"})
 
 	results := []OCRResult{
 		{
@@ -144,19 +154,20 @@ func TestApp_formatOutput(t *testing.T) {
 		},
 	}
 
-	output := app.formatOutput(results, "Sunday, December 31, 2023")
+	output, err := app.formatOutput(results, nil)
+	assert.NoError(t, err)
 
 	expected := `---
 Img-0001.jpg
-Monday, January 1, 2024
+2024-01-01
 First page text
 ---
 Img-0002.jpg
-Monday, January 1, 2024
+2024-01-01
 Second page text
 ---
 Img-0003.jpg
-Tuesday, January 2, 2024
+2024-01-02
 Third page text
 `
 	assert.Equal(t, expected, output)
@@ -164,7 +175,7 @@ Third page text
 
 func TestApp_formatOutput_WithStartDate(t *testing.T) {
 	mockResizer := new(MockResizer)
-	app := NewApp(nil, nil, mockResizer, nil, &AppConfig{})
+	app := NewApp(nil, nil, mockResizer, nil, nil, nil, &AppConfig{StartDate: "Sunday, December 31, 2023"})
 
 	results := []OCRResult{
 		{
@@ -174,57 +185,17 @@ func TestApp_formatOutput_WithStartDate(t *testing.T) {
 		},
 	}
 
-	output := app.formatOutput(results, "Sunday, December 31, 2023")
+	output, err := app.formatOutput(results, nil)
+	assert.NoError(t, err)
 
 	expected := `---
 Img-0001.jpg
-Sunday, December 31, 2023
+2023-12-31
 First page text
 `
 	assert.Equal(t, expected, output)
 }
 
-func TestExtractDate(t *testing.T) {
-	tests := []struct {
-		name     string
-		text     string
-		expected string
-	}{
-		{
-			name:     "date at top",
-			text:     "Monday, January 1, 2024\nSome text here",
-			expected: "Monday, January 1, 2024",
-		},
-		{
-			name:     "date without day",
-			text:     "January 1, 2024\nSome text",
-			expected: "January 1, 2024",
-		},
-		{
-			name:     "date with slashes",
-			text:     "01/01/2024\nSome text",
-			expected: "01/01/2024",
-		},
-		{
-			name:     "no date",
-			text:     "Some text without date",
-			expected: "",
-		},
-		{
-			name:     "date in second line",
-			text:     "\nMonday, January 1, 2024\nSome text",
-			expected: "Monday, January 1, 2024",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := extractDate(tt.text)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 func TestApp_ProcessImages_Results(t *testing.T) {
 	// Create temporary directory with test images
 	tmpDir, err := os.MkdirTemp("", "ocr_test_*")
@@ -254,10 +225,10 @@ func TestApp_ProcessImages_Results(t *testing.T) {
 	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("image1"), nil)
 	mockResizer.On("ResizeImage", []byte("image2"), 1500).Return([]byte("image2"), nil)
 
-	// Setup OCR client mocks with different costs
+	// Setup OCR client mocks with different costs, each image is described by its name and position
 	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
-	mockClient.On("OCRImage", mock.Anything, []byte("image1")).Return("Test text 1", 0.10, 1, nil)
-	mockClient.On("OCRImage", mock.Anything, []byte("image2")).Return("Test text 2", 0.20, 2, nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image1"), PageInfo{Name: "Img-0001.jpg", Index: 1}).Return(Transcription{Text: "Test text 1"}, 0.10, attempts(1), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image2"), PageInfo{Name: "Img-0002.jpg", Index: 2}).Return(Transcription{Text: "Test text 2"}, 0.20, attempts(2), nil)
 
 	// Create app config
 	config := &AppConfig{
@@ -266,7 +237,7 @@ func TestApp_ProcessImages_Results(t *testing.T) {
 	}
 
 	// Create app and process
-	app := NewApp(mockClient, mockRepo, mockResizer, nil, config)
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, config)
 
 	results, err := app.ProcessImages(context.Background())
 	assert.NoError(t, err)
@@ -281,3 +252,544 @@ func TestApp_ProcessImages_Results(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 	mockClient.AssertExpectations(t)
 }
+
+func TestApp_processImage_MaxImageDimension(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1024).Return([]byte("resized1"), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("resized1"), mock.Anything).Return(Transcription{Text: "Test text 1"}, 0.01, attempts(1), nil)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{MaxImageDimension: 1024})
+
+	result := app.processImage(context.Background(), PageInfo{Name: "Img-0001.jpg", Index: 1}, nil, nil)[0]
+	assert.NoError(t, result.Error)
+	assert.Equal(t, "Test text 1", result.Text)
+
+	mockResizer.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestApp_processImage_Preprocessor(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+	mockPreprocessor := new(MockPreprocessor)
+
+	// The resized image is preprocessed, and the preprocessed image is sent for OCR
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("resized1"), nil)
+	mockPreprocessor.On("Preprocess", "Img-0001.jpg", []byte("resized1")).Return([]byte("clean1"), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("clean1"), mock.Anything).Return(Transcription{Text: "Test text 1"}, 0.01, attempts(1), nil)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{Preprocessor: mockPreprocessor})
+
+	result := app.processImage(context.Background(), PageInfo{Name: "Img-0001.jpg", Index: 1}, nil, nil)[0]
+	assert.NoError(t, result.Error)
+	assert.Equal(t, "Test text 1", result.Text)
+	mockPreprocessor.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+
+	// A failed preprocessing is the error of the image
+	mockRepo.On("LoadImageByName", "Img-0002.jpg").Return([]byte("image2"), nil)
+	mockResizer.On("ResizeImage", []byte("image2"), 1500).Return([]byte("resized2"), nil)
+	mockPreprocessor.On("Preprocess", "Img-0002.jpg", []byte("resized2")).Return(nil, errors.New("preprocessing failed"))
+
+	result = app.processImage(context.Background(), PageInfo{Name: "Img-0002.jpg", Index: 2}, nil, nil)[0]
+	assert.EqualError(t, result.Error, "preprocessing failed")
+	mockClient.AssertNumberOfCalls(t, "OCRImage", 1)
+}
+
+func TestApp_processImage_Tiler(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+	mockTiler := new(MockTiler)
+
+	// Each band is sent for OCR and their transcriptions are stitched together at the lines they share
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockTiler.On("TileImage", []byte("image1"), 1500).Return([][]byte{[]byte("top1"), []byte("bottom1")}, nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("top1"), mock.Anything).Return(Transcription{
+		Text:              "Paid the grocer 4s 6d\nCoal for the week 2s 1d",
+		PageNumber:        "12",
+		IllegibleSegments: []string{"grocer"},
+	}, 0.01, attempts(1), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("bottom1"), mock.Anything).Return(Transcription{
+		Text:              "Coal for the week 2s 1d\nStamps and paper 9d",
+		IllegibleSegments: []string{"paper"},
+	}, 0.02, attempts(2), nil)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{Tiler: mockTiler})
+
+	result := app.processImage(context.Background(), PageInfo{Name: "Img-0001.jpg", Index: 1}, nil, nil)[0]
+	assert.NoError(t, result.Error)
+	assert.Equal(t, "Paid the grocer 4s 6d\nCoal for the week 2s 1d\nStamps and paper 9d", result.Text)
+	assert.Equal(t, "12", result.PageNumber)
+	assert.Equal(t, []string{"grocer", "paper"}, result.IllegibleSegments)
+	assert.InDelta(t, 0.03, result.Cost, 0.0001)
+	assert.Equal(t, 3, result.OCRAttempts)
+	mockResizer.AssertNotCalled(t, "ResizeImage", mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+
+	// A band that fails fails the image, and the bands after it are not sent
+	mockRepo.On("LoadImageByName", "Img-0002.jpg").Return([]byte("image2"), nil)
+	mockTiler.On("TileImage", []byte("image2"), 1500).Return([][]byte{[]byte("top2"), []byte("bottom2")}, nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("top2"), mock.Anything).Return(Transcription{}, 0.01, attempts(1), errors.New("max retries exceeded"))
+
+	result = app.processImage(context.Background(), PageInfo{Name: "Img-0002.jpg", Index: 2}, nil, nil)[0]
+	assert.EqualError(t, result.Error, "max retries exceeded")
+	assert.InDelta(t, 0.01, result.Cost, 0.0001)
+	mockClient.AssertNotCalled(t, "OCRImage", mock.Anything, []byte("bottom2"), mock.Anything)
+}
+
+func TestApp_processImage_Splitter(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+	mockSplitter := new(MockSplitter)
+
+	// The pages of a spread get results of their own, left before right, named after the image and the side
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("spread"), nil)
+	mockSplitter.On("SplitSpread", []byte("spread")).Return([][]byte{[]byte("left"), []byte("right")}, nil)
+	for _, page := range []string{"left", "right"} {
+		mockResizer.On("ResizeImage", []byte(page), 1500).Return([]byte(page), nil)
+		mockClient.On("OCRImage", mock.Anything, []byte(page), PageInfo{Name: "Img-0001.jpg#" + strings.ToUpper(page[:1]), Index: 1}).
+			Return(Transcription{Text: "Dear diary, " + page}, 0.01, attempts(1), nil)
+	}
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{Splitter: mockSplitter})
+
+	results := app.processImage(context.Background(), PageInfo{Name: "Img-0001.jpg", Index: 1}, nil, nil)
+	require.Len(t, results, 2)
+	assert.Equal(t, "Img-0001.jpg#L", results[0].ImageName)
+	assert.Equal(t, "Dear diary, left", results[0].Text)
+	assert.Equal(t, "Img-0001.jpg#R", results[1].ImageName)
+	assert.Equal(t, "Dear diary, right", results[1].Text)
+	// Both pages share the index of their image
+	mockClient.AssertCalled(t, "OCRImage", mock.Anything, []byte("left"), PageInfo{Name: "Img-0001.jpg#L", Index: 1})
+	mockClient.AssertCalled(t, "OCRImage", mock.Anything, []byte("right"), PageInfo{Name: "Img-0001.jpg#R", Index: 1})
+	mockClient.AssertExpectations(t)
+
+	// A single page keeps the name of the image
+	mockRepo.On("LoadImageByName", "Img-0002.jpg").Return([]byte("page"), nil)
+	mockSplitter.On("SplitSpread", []byte("page")).Return([][]byte{[]byte("page")}, nil)
+	mockResizer.On("ResizeImage", []byte("page"), 1500).Return([]byte("page"), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("page"), mock.Anything).Return(Transcription{Text: "Dear diary"}, 0.01, attempts(1), nil)
+
+	results = app.processImage(context.Background(), PageInfo{Name: "Img-0002.jpg", Index: 2}, nil, nil)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Img-0002.jpg", results[0].ImageName)
+
+	// An image that can not be split fails without being sent for OCR
+	mockRepo.On("LoadImageByName", "Img-0003.jpg").Return([]byte("broken"), nil)
+	mockSplitter.On("SplitSpread", []byte("broken")).Return(nil, errors.New("failed to decode image"))
+
+	results = app.processImage(context.Background(), PageInfo{Name: "Img-0003.jpg", Index: 3}, nil, nil)
+	require.Len(t, results, 1)
+	assert.Equal(t, "Img-0003.jpg", results[0].ImageName)
+	assert.EqualError(t, results[0].Error, "failed to decode image")
+	mockClient.AssertNumberOfCalls(t, "OCRImage", 3)
+}
+
+func TestApp_processImage_Attempts(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+
+	// Both attempts failed, and their history and cost are kept with the error
+	history := []Attempt{
+		{Duration: time.Second, Cost: 0.01, Error: errors.New("model refused to process image")},
+		{Delay: 300 * time.Millisecond, Duration: time.Second, Cost: 0.01, Error: errors.New("model refused to process image")},
+	}
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("image1"), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image1"), mock.Anything).Return(Transcription{}, 0.02, history, errors.New("max retries exceeded"))
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{})
+
+	result := app.processImage(context.Background(), PageInfo{Name: "Img-0001.jpg", Index: 1}, nil, nil)[0]
+	assert.EqualError(t, result.Error, "max retries exceeded")
+	assert.Equal(t, history, result.Attempts)
+	assert.Equal(t, 2, result.OCRAttempts)
+	assert.InDelta(t, 0.02, result.Cost, 0.0001)
+}
+
+func TestApp_ProcessImages_Checkpoints(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+	mockCheckpoints := new(MockCheckpointStore)
+
+	mockRepo.On("GetImageNames").Return([]string{"Img-0001.jpg", "Img-0002.jpg"}, nil)
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockRepo.On("LoadImageByName", "Img-0002.jpg").Return([]byte("image2"), nil)
+	mockRepo.On("SaveOutput", mock.Anything).Return(nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image2"), 1500).Return([]byte("image2"), nil)
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+	// The prompt of each page is part of its fingerprint, e.g. when the template uses {{.PageIndex}}
+	mockClient.On("Fingerprint", mock.Anything).Return(func(page PageInfo) string {
+		return fmt.Sprintf("gpt-4o:prompt%d", page.Index)
+	})
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, mockCheckpoints, nil, &AppConfig{Concurrency: 2})
+
+	// The first image was transcribed by a previous run, so only the second one is sent for OCR
+	saved := map[string]OCRResult{
+		app.checkpointKey([][]byte{[]byte("image1")}, PageInfo{Name: "Img-0001.jpg", Index: 1}): {ImageName: "Img-0001.jpg", Date: "January 1, 2024", Text: "Saved text 1", Cost: 0.10, OCRAttempts: 1},
+	}
+	mockCheckpoints.On("LoadCheckpoints").Return(saved, nil)
+	mockCheckpoints.On("SaveCheckpoint", app.checkpointKey([][]byte{[]byte("image2")}, PageInfo{Name: "Img-0002.jpg", Index: 2}), mock.MatchedBy(func(result OCRResult) bool {
+		return result.ImageName == "Img-0002.jpg" && result.Text == "Test text 2"
+	})).Return(nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image2"), mock.Anything).Return(Transcription{Text: "Test text 2"}, 0.20, attempts(2), nil)
+
+	results, err := app.ProcessImages(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, results.TotalImagesProcessed)
+	assert.Equal(t, 1, results.TotalImagesResumed)
+	assert.InDelta(t, 0.20, results.TotalCost, 0.0001)
+	assert.InDelta(t, 0.20, results.CostPerImage, 0.0001)
+	assert.Equal(t, 2, results.TotalOCRAttempts)
+
+	mockRepo.AssertCalled(t, "SaveOutput", mock.MatchedBy(func(content string) bool {
+		return assert.Contains(t, content, "Saved text 1") && assert.Contains(t, content, "Test text 2")
+	}))
+	mockClient.AssertNotCalled(t, "OCRImage", mock.Anything, []byte("image1"), mock.Anything)
+	mockCheckpoints.AssertExpectations(t)
+	mockClient.AssertExpectations(t)
+}
+
+func TestApp_ProcessImages_MaxCost(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+
+	imageNames := []string{"Img-0001.jpg", "Img-0002.jpg", "Img-0003.jpg", "Img-0004.jpg"}
+	mockRepo.On("GetImageNames").Return(imageNames, nil)
+	for i, name := range imageNames {
+		data := []byte(fmt.Sprintf("image%d", i+1))
+		mockRepo.On("LoadImageByName", name).Return(data, nil)
+		mockResizer.On("ResizeImage", data, 1500).Return(data, nil)
+	}
+	mockRepo.On("SaveOutput", mock.Anything).Return(nil)
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image1"), mock.Anything).Return(Transcription{Text: "Test text 1"}, 0.01, attempts(1), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image2"), mock.Anything).Return(Transcription{Text: "Test text 2"}, 0.01, attempts(1), nil)
+
+	// A third image would cost about $0.03, which is over the budget
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{Concurrency: 1, MaxCost: 0.025})
+
+	results, err := app.ProcessImages(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, results.BudgetExceeded)
+	assert.Equal(t, 2, results.TotalImagesProcessed)
+	assert.Equal(t, 2, results.TotalImagesSkipped)
+	assert.InDelta(t, 0.02, results.TotalCost, 0.0001)
+	assert.InDelta(t, 0.01, results.CostPerImage, 0.0001)
+	assert.Contains(t, results.String(), "budget exceeded:        2 images not processed")
+
+	mockRepo.AssertCalled(t, "SaveOutput", mock.MatchedBy(func(content string) bool {
+		return strings.Contains(content, "Test text 2") && strings.Count(content, "[NOT PROCESSED: cost budget reached]") == 2
+	}))
+	mockClient.AssertNumberOfCalls(t, "OCRImage", 2)
+}
+
+func TestBudget(t *testing.T) {
+	var unlimited *budget
+	assert.True(t, unlimited.start())
+	unlimited.finish(100)
+	assert.Nil(t, newBudget(0))
+
+	b := newBudget(1)
+	// The cost is unknown until the first image finishes, so the second one waits for it
+	assert.True(t, b.start())
+	started := make(chan bool)
+	go func() { started <- b.start() }()
+	select {
+	case <-started:
+		t.Fatal("Expected the second image to wait for the cost of the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.finish(0.4)
+	assert.True(t, <-started)
+	// One image is in flight, so the next one would bring the total to 0.4 * 3
+	assert.False(t, b.start())
+	b.finish(0.4)
+	assert.False(t, b.start())
+}
+
+func TestApp_ProcessImages_MaxCostConcurrent(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+
+	imageNames := []string{"Img-0001.jpg", "Img-0002.jpg", "Img-0003.jpg", "Img-0004.jpg"}
+	mockRepo.On("GetImageNames").Return(imageNames, nil)
+	for i, name := range imageNames {
+		data := []byte(fmt.Sprintf("image%d", i+1))
+		mockRepo.On("LoadImageByName", name).Return(data, nil)
+		mockResizer.On("ResizeImage", data, 1500).Return(data, nil)
+	}
+	mockRepo.On("SaveOutput", mock.Anything).Return(nil)
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+	mockClient.On("OCRImage", mock.Anything, mock.Anything, mock.Anything).Return(Transcription{Text: "Test text"}, 0.01, attempts(1), nil)
+
+	// Every worker could start an image at once, but the limit is less than the cost of two images,
+	// so the others wait for the cost of the first one and are then skipped
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{Concurrency: 4, MaxCost: 0.015})
+
+	results, err := app.ProcessImages(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, results.BudgetExceeded)
+	assert.Equal(t, 1, results.TotalImagesProcessed)
+	assert.Equal(t, 3, results.TotalImagesSkipped)
+	assert.InDelta(t, 0.01, results.TotalCost, 0.0001)
+	mockClient.AssertNumberOfCalls(t, "OCRImage", 1)
+}
+
+func TestApp_ProcessImages_CheckpointLoadError(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockCheckpoints := new(MockCheckpointStore)
+
+	mockRepo.On("GetImageNames").Return([]string{"Img-0001.jpg"}, nil)
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+	mockCheckpoints.On("LoadCheckpoints").Return(nil, os.ErrPermission)
+
+	app := NewApp(mockClient, mockRepo, new(MockResizer), nil, mockCheckpoints, nil, &AppConfig{})
+
+	_, err := app.ProcessImages(context.Background())
+	assert.ErrorIs(t, err, ErrCheckpointFailed)
+}
+
+func TestApp_ProcessImages_OutputFormatter(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+	mockFormatter := new(MockOutputFormatter)
+
+	mockRepo.On("GetImageNames").Return([]string{"Img-0001.jpg", "Img-0002.jpg"}, nil)
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockRepo.On("LoadImageByName", "Img-0002.jpg").Return([]byte("image2"), nil)
+	mockRepo.On("SaveOutput", "formatted").Return(nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image2"), 1500).Return([]byte("image2"), nil)
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image1"), mock.Anything).Return(Transcription{Text: "January 1, 2024\nTest text 1"}, 0.10, attempts(1), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image2"), mock.Anything).Return(Transcription{Text: "Test text 2"}, 0.20, attempts(1), nil)
+
+	// The formatter receives the results in page order with their entry dates and the summary
+	mockFormatter.On("Format", mock.MatchedBy(func(results []OCRResult) bool {
+		return len(results) == 2 &&
+			results[0].ImageName == "Img-0001.jpg" && results[0].EntryDate == "2024-01-01" &&
+			results[1].ImageName == "Img-0002.jpg" && results[1].EntryDate == "2024-01-01"
+	}), mock.MatchedBy(func(summary *ProcessImageResults) bool {
+		return summary.TotalImagesProcessed == 2
+	})).Return("formatted", nil)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, mockFormatter, &AppConfig{Concurrency: 2})
+
+	_, err := app.ProcessImages(context.Background())
+	assert.NoError(t, err)
+
+	mockFormatter.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestApp_ProcessImages_OutputFormatterError(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+	mockFormatter := new(MockOutputFormatter)
+
+	mockRepo.On("GetImageNames").Return([]string{"Img-0001.jpg"}, nil)
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("image1"), nil)
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image1"), mock.Anything).Return(Transcription{Text: "Test text 1"}, 0.10, attempts(1), nil)
+	mockFormatter.On("Format", mock.Anything, mock.Anything).Return("", os.ErrInvalid)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, mockFormatter, &AppConfig{})
+
+	_, err := app.ProcessImages(context.Background())
+	assert.ErrorIs(t, err, ErrProcessingFailed)
+	mockRepo.AssertNotCalled(t, "SaveOutput", mock.Anything)
+}
+
+func TestAssignEntryDates(t *testing.T) {
+	dates, err := NewDateParser(DateParserConfig{})
+	assert.NoError(t, err)
+
+	results := []OCRResult{
+		{ImageName: "Img-0001.jpg"},
+		{ImageName: "Img-0002.jpg", Date: "January 2, 2024"},
+		{ImageName: "Img-0003.jpg", Error: os.ErrNotExist},
+		{ImageName: "Img-0004.jpg"},
+	}
+
+	assignEntryDates(results, "January 1, 2024", dates)
+
+	january1 := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	january2 := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-01-01", results[0].EntryDate)
+	assert.Equal(t, january1, results[0].ParsedEntryDate)
+	assert.Equal(t, "2024-01-02", results[1].EntryDate)
+	assert.Equal(t, january2, results[1].ParsedEntryDate)
+	assert.Equal(t, "2024-01-02", results[2].EntryDate)
+	assert.Equal(t, "2024-01-02", results[3].EntryDate)
+
+	// A start date that is not a date is carried forward as written
+	results = []OCRResult{{ImageName: "Img-0001.jpg"}}
+	assignEntryDates(results, "Spring of '24", dates)
+	assert.Equal(t, "Spring of '24", results[0].EntryDate)
+	assert.True(t, results[0].ParsedEntryDate.IsZero())
+}
+
+func TestApp_ProcessImages_SplitByDate(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+
+	imageNames := []string{"Img-0001.jpg", "Img-0002.jpg", "Img-0003.jpg", "Img-0004.jpg"}
+	texts := []string{"First page text", "Monday, January 1, 2024\nSecond page text", "Third page text", "01/02/2024\nFourth page text"}
+	mockRepo.On("GetImageNames").Return(imageNames, nil)
+	for i, name := range imageNames {
+		image := []byte(name)
+		mockRepo.On("LoadImageByName", name).Return(image, nil)
+		mockResizer.On("ResizeImage", image, 1500).Return(image, nil)
+		mockClient.On("OCRImage", mock.Anything, image, mock.Anything).Return(Transcription{Text: texts[i]}, 0.01, attempts(1), nil)
+	}
+	mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+
+	// Pages before the first date are undated, and pages with a carried forward date join the file of that date
+	mockRepo.On("SaveOutputs", map[string]string{
+		UndatedOutputName: "---\nImg-0001.jpg\nFirst page text\n",
+		"2024-01-01":      "---\nImg-0002.jpg\n2024-01-01\nMonday, January 1, 2024\nSecond page text\n---\nImg-0003.jpg\n2024-01-01\nThird page text\n",
+		"2024-01-02":      "---\nImg-0004.jpg\n2024-01-02\n01/02/2024\nFourth page text\n",
+	}).Return(nil)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{Concurrency: 2, SplitByDate: true})
+
+	_, err := app.ProcessImages(context.Background())
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "SaveOutput", mock.Anything)
+}
+
+func TestApp_ProcessImages_SplitByDirectory(t *testing.T) {
+	imageNames := []string{"Img-0001.jpg", "vol1/Img-0001.jpg", "vol1/Img-0002.jpg", "vol2/Img-0001.jpg"}
+	texts := []string{"Cover text", "Monday, January 1, 2024\nFirst page text", "Second page text", "Third page text"}
+	newApp := func(config *AppConfig) (*App, *MockRepository) {
+		mockRepo := new(MockRepository)
+		mockClient := new(MockOCRClient)
+		mockResizer := new(MockResizer)
+		mockRepo.On("GetImageNames").Return(imageNames, nil)
+		for i, name := range imageNames {
+			image := []byte(name)
+			mockRepo.On("LoadImageByName", name).Return(image, nil)
+			mockResizer.On("ResizeImage", image, 1500).Return(image, nil)
+			mockClient.On("OCRImage", mock.Anything, image, mock.Anything).Return(Transcription{Text: texts[i]}, 0.01, attempts(1), nil)
+		}
+		mockClient.On("ValidateAPIKey", mock.Anything).Return(nil)
+		return NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, config), mockRepo
+	}
+
+	t.Run("one output per directory", func(t *testing.T) {
+		app, mockRepo := newApp(&AppConfig{Concurrency: 2, SplitByDirectory: true})
+
+		// The images of the base directory are saved to the usual output, and dates are not carried to the next directory
+		mockRepo.On("SaveOutput", "---\nImg-0001.jpg\nCover text\n").Return(nil)
+		mockRepo.On("SaveDirectoryOutput", "vol1", "---\nvol1/Img-0001.jpg\n2024-01-01\nMonday, January 1, 2024\nFirst page text\n---\nvol1/Img-0002.jpg\n2024-01-01\nSecond page text\n").Return(nil)
+		mockRepo.On("SaveDirectoryOutput", "vol2", "---\nvol2/Img-0001.jpg\nThird page text\n").Return(nil)
+
+		summary, err := app.ProcessImages(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 4, summary.TotalImagesProcessed)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("one output per directory and date", func(t *testing.T) {
+		app, mockRepo := newApp(&AppConfig{Concurrency: 2, SplitByDirectory: true, SplitByDate: true})
+
+		mockRepo.On("SaveOutputs", map[string]string{UndatedOutputName: "---\nImg-0001.jpg\nCover text\n"}).Return(nil)
+		mockRepo.On("SaveDirectoryOutputs", "vol1", map[string]string{
+			"2024-01-01": "---\nvol1/Img-0001.jpg\n2024-01-01\nMonday, January 1, 2024\nFirst page text\n---\nvol1/Img-0002.jpg\n2024-01-01\nSecond page text\n",
+		}).Return(nil)
+		mockRepo.On("SaveDirectoryOutputs", "vol2", map[string]string{UndatedOutputName: "---\nvol2/Img-0001.jpg\nThird page text\n"}).Return(nil)
+
+		_, err := app.ProcessImages(context.Background())
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		app, mockRepo := newApp(&AppConfig{Concurrency: 2, SplitByDirectory: true})
+		mockRepo.On("SaveOutput", mock.Anything).Return(nil)
+		mockRepo.On("SaveDirectoryOutput", "vol1", mock.Anything).Return(errors.New("disk full"))
+
+		_, err := app.ProcessImages(context.Background())
+		assert.ErrorIs(t, err, ErrProcessingFailed)
+		mockRepo.AssertNotCalled(t, "SaveDirectoryOutput", "vol2", mock.Anything)
+	})
+}
+
+func TestEntryOutputName(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   OCRResult
+		expected string
+	}{
+		{name: "parsed date", result: OCRResult{EntryDate: "01/01/2024", ParsedEntryDate: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)}, expected: "2024-01-01"},
+		{name: "unparsed date", result: OCRResult{EntryDate: "Spring of '24"}, expected: "spring-of-24"},
+		{name: "no date", result: OCRResult{}, expected: UndatedOutputName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, entryOutputName(tt.result))
+		})
+	}
+}
+
+func TestApp_processImage_Transcription(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockClient := new(MockOCRClient)
+	mockResizer := new(MockResizer)
+
+	mockRepo.On("LoadImageByName", "Img-0001.jpg").Return([]byte("image1"), nil)
+	mockResizer.On("ResizeImage", []byte("image1"), 1500).Return([]byte("image1"), nil)
+	mockClient.On("OCRImage", mock.Anything, []byte("image1"), mock.Anything).Return(Transcription{
+		Text:              "Jan 1 '24\nDear diary, [illegible]",
+		Date:              "January 1, 2024",
+		PageNumber:        "12",
+		IllegibleSegments: []string{"[illegible]"},
+	}, 0.01, []Attempt{{PromptHash: "0123456789abcdef"}}, nil)
+
+	app := NewApp(mockClient, mockRepo, mockResizer, nil, nil, nil, &AppConfig{PromptPreset: "journal"})
+
+	// The date reported by the model is used even though it can not be found in the text
+	result := app.processImage(context.Background(), PageInfo{Name: "Img-0001.jpg", Index: 1}, nil, nil)[0]
+	assert.NoError(t, result.Error)
+	assert.Equal(t, "January 1, 2024", result.Date)
+	assert.Equal(t, time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC), result.ParsedDate)
+	assert.Equal(t, "12", result.PageNumber)
+	assert.Equal(t, []string{"[illegible]"}, result.IllegibleSegments)
+	assert.Equal(t, "journal", result.PromptPreset)
+	assert.Equal(t, "0123456789abcdef", result.PromptHash)
+}
+
+func TestApp_findDate(t *testing.T) {
+	app := NewApp(nil, nil, nil, nil, nil, nil, &AppConfig{})
+
+	// A reported date that can not be parsed falls back to the date at the top of the text
+	date := app.findDate("the first of the year", "January 1, 2024\nDear diary")
+	assert.Equal(t, "January 1, 2024", date.Text)
+
+	date = app.findDate("", "Dear diary")
+	assert.Empty(t, date.Text)
+	assert.True(t, date.Time.IsZero())
+}
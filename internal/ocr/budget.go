@@ -0,0 +1,69 @@
+package ocr
+
+import "sync"
+
+// budget keeps the cost of a run under a limit. Before an image is sent for OCR, the cost of the images
+// in flight and of the next one is projected from the average cost of the images that finished,
+// and no more images are started once the projection is over the limit. Until the first image finishes
+// there is no cost to project from, so only one image is in flight until then.
+// A nil budget has no limit.
+type budget struct {
+	mu sync.Mutex
+	// finished is signalled when an image finishes, so the images waiting for the first cost can start
+	finished  *sync.Cond
+	limit     float64
+	spent     float64
+	completed int
+	inFlight  int
+	exceeded  bool
+}
+
+// newBudget returns a budget with the limit, or nil when the limit is 0 or less
+func newBudget(limit float64) *budget {
+	if limit <= 0 {
+		return nil
+	}
+	b := &budget{limit: limit}
+	b.finished = sync.NewCond(&b.mu)
+	return b
+}
+
+// start reports whether another image may be sent for OCR, and counts it as in flight when it may.
+// While the first image is in flight its cost is unknown, so start waits for it to finish.
+func (b *budget) start() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.completed == 0 && b.inFlight > 0 {
+		b.finished.Wait()
+	}
+	if !b.exceeded {
+		projected := b.spent
+		if b.completed > 0 {
+			projected += b.spent / float64(b.completed) * float64(b.inFlight+1)
+		}
+		b.exceeded = projected > b.limit
+	}
+	if b.exceeded {
+		return false
+	}
+	b.inFlight++
+	return true
+}
+
+// finish records the cost of an image started with start
+func (b *budget) finish(cost float64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inFlight--
+	b.completed++
+	b.spent += cost
+	b.finished.Broadcast()
+}
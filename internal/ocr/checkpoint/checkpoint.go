@@ -0,0 +1,156 @@
+package checkpoint
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+// FileSuffix is appended to the output path to name the checkpoint file
+const FileSuffix = ".checkpoint.jsonl"
+
+// Store implements the ocr.CheckpointStore interface with an append-only JSON lines file
+type Store struct {
+	path string
+	mu   sync.Mutex
+	// truncated is set when the file ends in a partial line that the next save must terminate
+	truncated bool
+}
+
+var _ ocr.CheckpointStore = (*Store)(nil)
+
+var (
+	// ErrFailedToLoad is returned when the checkpoint file can not be read
+	ErrFailedToLoad = fmt.Errorf("failed to load checkpoints")
+	// ErrFailedToSave is returned when a checkpoint can not be written
+	ErrFailedToSave = fmt.Errorf("failed to save checkpoint")
+)
+
+// record is a single line of the checkpoint file
+type record struct {
+	Key         string        `json:"key"`
+	ImageName   string        `json:"image_name"`
+	Date        string        `json:"date"`
+	Text        string        `json:"text"`
+	Cost        float64       `json:"cost"`
+	OCRAttempts int           `json:"ocr_attempts"`
+	Duration    time.Duration `json:"duration"`
+
+	PageNumber        string   `json:"page_number,omitempty"`
+	IllegibleSegments []string `json:"illegible_segments,omitempty"`
+	PromptPreset      string   `json:"prompt_preset,omitempty"`
+	PromptHash        string   `json:"prompt_hash,omitempty"`
+}
+
+// New creates a new Store that reads and writes the checkpoint file at path
+func New(path string) *Store {
+	return &Store{path: path}
+}
+
+// Reset deletes the checkpoint file so the next run starts fresh
+func (s *Store) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %v", ErrFailedToSave, err)
+	}
+	s.truncated = false
+	return nil
+}
+
+// LoadCheckpoints returns the saved results keyed by their checkpoint key.
+// A missing file has no checkpoints, and lines cut short by a crash are skipped.
+func (s *Store) LoadCheckpoints() (map[string]ocr.OCRResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := map[string]ocr.OCRResult{}
+
+	file, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return results, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFailedToLoad, err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			s.truncated = len(line) > 0
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrFailedToLoad, err)
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil || rec.Key == "" {
+			continue
+		}
+		results[rec.Key] = ocr.OCRResult{
+			ImageName:   rec.ImageName,
+			Date:        rec.Date,
+			Text:        rec.Text,
+			Cost:        rec.Cost,
+			OCRAttempts: rec.OCRAttempts,
+			Duration:    rec.Duration,
+
+			PageNumber:        rec.PageNumber,
+			IllegibleSegments: rec.IllegibleSegments,
+			PromptPreset:      rec.PromptPreset,
+			PromptHash:        rec.PromptHash,
+		}
+	}
+
+	return results, nil
+}
+
+// SaveCheckpoint appends a finished result to the checkpoint file
+func (s *Store) SaveCheckpoint(key string, result ocr.OCRResult) error {
+	line, err := json.Marshal(record{
+		Key:         key,
+		ImageName:   result.ImageName,
+		Date:        result.Date,
+		Text:        result.Text,
+		Cost:        result.Cost,
+		OCRAttempts: result.OCRAttempts,
+		Duration:    result.Duration,
+
+		PageNumber:        result.PageNumber,
+		IllegibleSegments: result.IllegibleSegments,
+		PromptPreset:      result.PromptPreset,
+		PromptHash:        result.PromptHash,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToSave, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToSave, err)
+	}
+	defer file.Close()
+
+	line = append(line, '\n')
+	if s.truncated {
+		line = append([]byte{'\n'}, line...)
+	}
+	if _, err := file.Write(line); err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToSave, err)
+	}
+	s.truncated = false
+	return nil
+}
@@ -0,0 +1,81 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.txt"+FileSuffix)
+	store := New(path)
+
+	// A missing file has no checkpoints
+	results, err := store.LoadCheckpoints()
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	first := ocr.OCRResult{ImageName: "Img-0001.jpg", Date: "January 1, 2024", Text: "First", PageNumber: "1", IllegibleSegments: []string{"[?]"}, PromptPreset: "journal", PromptHash: "0123456789abcdef", Cost: 0.01, OCRAttempts: 1, Duration: time.Second}
+	second := ocr.OCRResult{ImageName: "Img-0002.jpg", Text: "Second", Cost: 0.02, OCRAttempts: 2, Duration: 2 * time.Second}
+	assert.NoError(t, store.SaveCheckpoint("key1", first))
+	assert.NoError(t, store.SaveCheckpoint("key2", second))
+
+	results, err = New(path).LoadCheckpoints()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]ocr.OCRResult{"key1": first, "key2": second}, results)
+}
+
+func TestStore_TruncatedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.txt"+FileSuffix)
+	store := New(path)
+	assert.NoError(t, store.SaveCheckpoint("key1", ocr.OCRResult{ImageName: "Img-0001.jpg", Text: "First"}))
+
+	// Simulate a crash in the middle of writing a line
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"key":"key2","image_na`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	store = New(path)
+	results, err := store.LoadCheckpoints()
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Contains(t, results, "key1")
+
+	// The next save must not be glued onto the partial line
+	assert.NoError(t, store.SaveCheckpoint("key3", ocr.OCRResult{ImageName: "Img-0003.jpg", Text: "Third"}))
+	results, err = New(path).LoadCheckpoints()
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	assert.Contains(t, results, "key3")
+}
+
+func TestStore_Reset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output.txt"+FileSuffix)
+	store := New(path)
+
+	// Resetting without a file is not an error
+	assert.NoError(t, store.Reset())
+
+	assert.NoError(t, store.SaveCheckpoint("key1", ocr.OCRResult{ImageName: "Img-0001.jpg"}))
+	assert.NoError(t, store.Reset())
+
+	results, err := store.LoadCheckpoints()
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestStore_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	// The checkpoint path is a directory, so it can be neither read nor written
+	store := New(dir)
+	_, err := store.LoadCheckpoints()
+	assert.ErrorIs(t, err, ErrFailedToLoad)
+	assert.ErrorIs(t, store.SaveCheckpoint("key", ocr.OCRResult{}), ErrFailedToSave)
+}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/pricing"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
+)
+
+// AnthropicClient implements the ocr.OCRClient interface for the Anthropic Messages API
+type AnthropicClient struct {
+	config     Config
+	httpClient *http.Client
+	limiter    *rateLimiter
+}
+
+var _ ocr.OCRClient = (*AnthropicClient)(nil)
+
+var (
+	// DefaultAnthropicModel is the Anthropic model used when none is configured
+	DefaultAnthropicModel = "claude-sonnet-4-5"
+	// DefaultAnthropicBaseURL is the Anthropic API used when no base URL is configured
+	DefaultAnthropicBaseURL = "https://api.anthropic.com/v1"
+	// AnthropicVersion is sent as the anthropic-version header of each request
+	AnthropicVersion = "2023-06-01"
+)
+
+// NewAnthropic creates a new AnthropicClient instance. Zero values in config are replaced with the defaults.
+// The OpenAI specific APIType, APIVersion and AzureDeployment settings are ignored.
+func NewAnthropic(config Config) *AnthropicClient {
+	if config.Model == "" {
+		config.Model = DefaultAnthropicModel
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = DefaultMaxTokens
+	}
+	if config.Retry.Retryable == nil {
+		config.Retry.Retryable = isAnthropicRetryable
+	}
+	config.Retry = config.Retry.withDefaults()
+	config.Refusal = config.Refusal.withDefaults()
+	if config.Prompt == nil {
+		config.Prompt = prompt.Default()
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultAnthropicBaseURL
+	}
+	if config.Prices == nil {
+		config.Prices = pricing.Default()
+	}
+
+	limiter := newRateLimiter(config.RequestsPerMinute, config.TokensPerMinute)
+	return &AnthropicClient{
+		config:     config,
+		httpClient: &http.Client{Transport: &rateLimitTransport{limiter: limiter, base: http.DefaultTransport}},
+		limiter:    limiter,
+	}
+}
+
+// header returns the headers sent with each request
+func (c *AnthropicClient) header() http.Header {
+	header := http.Header{}
+	header.Set("anthropic-version", AnthropicVersion)
+	if c.config.AuthHeader != AuthHeaderNone {
+		header.Set("x-api-key", c.config.APIKey)
+	}
+	return header
+}
+
+// ValidateAPIKey validates the API key using the models endpoint of the same API used for OCR
+func (c *AnthropicClient) ValidateAPIKey(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := doJSON(ctx, c.httpClient, http.MethodGet, c.config.BaseURL+"/models", c.header(), nil, nil)
+	if err == nil {
+		return nil
+	}
+
+	if apiErr, ok := err.(*APIError); ok {
+		if apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden {
+			return ErrInvalidAPIKey
+		}
+		return apiErr
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+}
+
+// OCRImage processes an image and returns the transcription, total cost from all attempts, and every attempt made
+func (c *AnthropicClient) OCRImage(ctx context.Context, imageData []byte, page ocr.PageInfo) (transcription ocr.Transcription, totalCost float64, attempts []ocr.Attempt, err error) {
+	req, err := newOCRRequest(c.config, imageData, page)
+	if err != nil {
+		return ocr.Transcription{}, 0, nil, err
+	}
+	tokens := requestTokens(c.config.Model, imageData, c.config.MaxTokens)
+	return retryOCR(ctx, c.limiter, tokens, c.config.Retry, c.config.Refusal, req, c.ocrImageOnce)
+}
+
+// Fingerprint identifies the provider, model, prompts rendered for the page and response schema used for OCR
+func (c *AnthropicClient) Fingerprint(page ocr.PageInfo) string {
+	hash := sha256.Sum256([]byte(string(ProviderAnthropic) + "\x00" + c.config.Model + "\x00" + promptFingerprint(c.config, transcriptionSchema, page)))
+	return fmt.Sprintf("%s:%x", c.config.Model, hash[:8])
+}
+
+// anthropicRequest is the body of a Messages API request
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float64              `json:"temperature"`
+	System      string               `json:"system"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// anthropicMessage is a message of a Messages API request
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicContentBlock is a text, image or tool use block of a message
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+	Name   string                `json:"name,omitempty"`
+	Input  json.RawMessage       `json:"input,omitempty"`
+}
+
+// anthropicImageSource is the base64 encoded data of an image block
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// anthropicTool is a tool the model can call, which is used to receive structured responses
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema any    `json:"input_schema"`
+}
+
+// anthropicToolChoice forces the model to call a tool
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// anthropicResponse is the body of a Messages API response
+type anthropicResponse struct {
+	Content    []anthropicContentBlock `json:"content"`
+	StopReason string                  `json:"stop_reason"`
+	Usage      struct {
+		InputTokens              int `json:"input_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// ocrImageOnce performs a single OCR request
+func (c *AnthropicClient) ocrImageOnce(ctx context.Context, ocrReq ocrRequest) (transcription ocr.Transcription, cost float64, err error) {
+	// The Messages API has no JSON schema response format,
+	// so structured responses are requested by forcing a call of a tool with the schema as input
+	req := anthropicRequest{
+		Model:       ocrReq.model,
+		MaxTokens:   c.config.MaxTokens,
+		Temperature: 0.1, // Lower temperature for more consistent, literal transcription
+		System:      ocrReq.systemPrompt,
+	}
+	if !c.config.PlainText {
+		req.Tools = []anthropicTool{{
+			Name:        transcriptionSchemaName,
+			Description: "Record the transcription of the page",
+			InputSchema: transcriptionSchema,
+		}}
+		req.ToolChoice = &anthropicToolChoice{Type: "tool", Name: transcriptionSchemaName}
+	}
+	req.Messages = []anthropicMessage{{
+		Role: "user",
+		Content: []anthropicContentBlock{
+			{
+				Type: "image",
+				Source: &anthropicImageSource{
+					Type:      "base64",
+					MediaType: imageMediaType(ocrReq.imageData),
+					Data:      base64.StdEncoding.EncodeToString(ocrReq.imageData),
+				},
+			},
+			{Type: "text", Text: ocrReq.userPrompt},
+		},
+	}}
+
+	var resp anthropicResponse
+	if err := doJSON(ctx, c.httpClient, http.MethodPost, c.config.BaseURL+"/messages", c.header(), req, &resp); err != nil {
+		return ocr.Transcription{}, 0, err
+	}
+
+	// The input tokens leave out the tokens written to and read from the prompt cache
+	cost = c.config.Prices.Cost(ocrReq.model, pricing.Usage{
+		InputTokens:       resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens,
+		CachedInputTokens: resp.Usage.CacheReadInputTokens,
+		OutputTokens:      resp.Usage.OutputTokens,
+	})
+
+	// The model reports refusals with a dedicated stop reason
+	var text string
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text += block.Text
+		}
+	}
+	if resp.StopReason == "refusal" {
+		return ocr.Transcription{}, cost, &RefusalError{Text: text}
+	}
+
+	transcription.Text = text
+	if !c.config.PlainText {
+		var input json.RawMessage
+		for _, block := range resp.Content {
+			if block.Type == "tool_use" && block.Name == transcriptionSchemaName {
+				input = block.Input
+			}
+		}
+		if input == nil {
+			// A text answer instead of the forced tool call is most likely a refusal
+			if isRefusalResponse(text) {
+				return ocr.Transcription{}, cost, &RefusalError{Text: text}
+			}
+			return ocr.Transcription{}, cost, fmt.Errorf("%w: no %s tool call in response", ErrInvalidResponse, transcriptionSchemaName)
+		}
+		var structured structuredResponse
+		if err := json.Unmarshal(input, &structured); err != nil {
+			return ocr.Transcription{}, cost, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		transcription = structured.toTranscription()
+	} else if isRefusalResponse(transcription.Text) {
+		// Refusals without the refusal stop reason are recognized by their wording
+		return ocr.Transcription{}, cost, &RefusalError{Text: transcription.Text}
+	}
+
+	return transcription, cost, nil
+}
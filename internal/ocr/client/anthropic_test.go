@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// anthropicHandler answers the models and messages endpoints with the given status and messages response
+func anthropicHandler(t *testing.T, status int, response string, bodies *[]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"data": []}`))
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		*bodies = append(*bodies, body)
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}
+}
+
+func TestAnthropicClient_OCRImage(t *testing.T) {
+	var bodies []map[string]any
+	server, requests := fakeServer(t, anthropicHandler(t, http.StatusOK, `{
+		"content": [{"type": "tool_use", "id": "toolu_1", "name": "page_transcription", "input": {"transcription": "1. Januar 2024\nLiebes Tagebuch", "date": "1. Januar 2024", "page_number": "7", "illegible_segments": []}}],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 1000000, "output_tokens": 100000}
+	}`, &bodies))
+	c := NewAnthropic(Config{APIKey: "key", BaseURL: server.URL, Model: "claude-sonnet-4-5"})
+
+	transcription, cost, attempts, err := c.OCRImage(context.Background(), []byte("\x89PNG\r\n\x1a\n"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "1. Januar 2024\nLiebes Tagebuch" || transcription.Date != "1. Januar 2024" || transcription.PageNumber != "7" {
+		t.Errorf("Unexpected transcription: %+v", transcription)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("Expected 1 attempt, got %d", len(attempts))
+	}
+	// 1M input tokens at $3 and 100K output tokens at $15 per million
+	if cost < 4.4999 || cost > 4.5001 {
+		t.Errorf("Expected a cost of $4.50, got: %f", cost)
+	}
+
+	r := (*requests)[0]
+	if r.URL.Path != "/messages" || r.Header.Get("x-api-key") != "key" || r.Header.Get("anthropic-version") != AnthropicVersion {
+		t.Errorf("Unexpected request: %s %v", r.URL.Path, r.Header)
+	}
+
+	// The structured response is requested by forcing the tool call
+	choice, _ := bodies[0]["tool_choice"].(map[string]any)
+	if choice["type"] != "tool" || choice["name"] != transcriptionSchemaName {
+		t.Errorf("Unexpected tool choice: %v", bodies[0]["tool_choice"])
+	}
+	content := bodies[0]["messages"].([]any)[0].(map[string]any)["content"].([]any)
+	source := content[0].(map[string]any)["source"].(map[string]any)
+	if source["media_type"] != "image/png" {
+		t.Errorf("Expected the PNG media type, got: %v", source["media_type"])
+	}
+}
+
+func TestAnthropicClient_OCRImage_PlainText(t *testing.T) {
+	var bodies []map[string]any
+	server, _ := fakeServer(t, anthropicHandler(t, http.StatusOK, `{
+		"content": [{"type": "text", "text": "Monday, January 1, 2024\nDear diary"}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 1000, "output_tokens": 100}
+	}`, &bodies))
+	c := NewAnthropic(Config{APIKey: "key", BaseURL: server.URL, PlainText: true})
+
+	transcription, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "Monday, January 1, 2024\nDear diary" {
+		t.Errorf("Unexpected text: %q", transcription.Text)
+	}
+	if _, ok := bodies[0]["tools"]; ok {
+		t.Errorf("Expected no tools, got: %v", bodies[0]["tools"])
+	}
+}
+
+func TestAnthropicClient_OCRImage_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		response     string
+		wantErr      string
+		wantAttempts int
+	}{
+		{
+			name:         "refusal stop reason",
+			status:       http.StatusOK,
+			response:     `{"content": [], "stop_reason": "refusal", "usage": {"input_tokens": 1000, "output_tokens": 0}}`,
+			wantErr:      "model refused",
+			wantAttempts: 2,
+		},
+		{
+			name:         "refusal text instead of tool call",
+			status:       http.StatusOK,
+			response:     `{"content": [{"type": "text", "text": "I'm sorry, I can't transcribe this image."}], "stop_reason": "end_turn", "usage": {}}`,
+			wantErr:      "model refused",
+			wantAttempts: 2,
+		},
+		{
+			name:         "overloaded is retried",
+			status:       529,
+			response:     `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`,
+			wantErr:      "max retries exceeded",
+			wantAttempts: 2,
+		},
+		{
+			name:         "bad request is not retried",
+			status:       http.StatusBadRequest,
+			response:     `{"type": "error", "error": {"type": "invalid_request_error", "message": "Image too large"}}`,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []map[string]any
+			server, _ := fakeServer(t, anthropicHandler(t, tt.status, tt.response, &bodies))
+			c := NewAnthropic(Config{APIKey: "key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+
+			_, _, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %v, got: %v", tt.wantErr, err)
+			}
+			if len(attempts) != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, len(attempts))
+			}
+		})
+	}
+
+	t.Run("error message", func(t *testing.T) {
+		var bodies []map[string]any
+		server, _ := fakeServer(t, anthropicHandler(t, http.StatusBadRequest, `{"type": "error", "error": {"type": "invalid_request_error", "message": "Image too large"}}`, &bodies))
+		c := NewAnthropic(Config{APIKey: "key", BaseURL: server.URL})
+
+		_, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+		want := &APIError{Status: http.StatusBadRequest, Message: "Image too large", Type: "invalid_request_error"}
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("Expected %v, got: %v", want, err)
+		}
+	})
+}
+
+func TestAnthropicClient_ValidateAPIKey(t *testing.T) {
+	var bodies []map[string]any
+	server, _ := fakeServer(t, anthropicHandler(t, http.StatusUnauthorized, "", &bodies))
+	c := NewAnthropic(Config{APIKey: "bad", BaseURL: server.URL})
+
+	if err := c.ValidateAPIKey(context.Background()); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey, got: %v", err)
+	}
+}
@@ -2,26 +2,115 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/pricing"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
 	"github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
 // Client implements the ocr.OCRClient interface for OpenAI API operations
 type Client struct {
-	apiKey       string
+	config       Config
 	openAIClient *openai.Client
+	limiter      *rateLimiter
 }
 
+var _ ocr.OCRClient = (*Client)(nil)
+
+// Config contains the configuration parameters needed by the client
+type Config struct {
+	APIKey    string
+	Model     string
+	MaxTokens int
+	// Retry decides which failed requests are sent again and how long to wait before each retry
+	Retry RetryPolicy
+	// Refusal decides how the request of an image changes after the model refuses to transcribe it
+	Refusal RefusalPolicy
+
+	// BaseURL is the root of the OpenAI compatible API, e.g. http://localhost:11434/v1 for Ollama
+	// or https://my-resource.openai.azure.com for Azure OpenAI
+	BaseURL string
+	// APIType selects the URL layout of the API
+	APIType APIType
+	// APIVersion is sent as the api-version query parameter, which Azure OpenAI requires
+	APIVersion string
+	// AzureDeployment is the Azure OpenAI deployment used for requests. Defaults to the model name.
+	AzureDeployment string
+	// AuthHeader selects how the API key is sent to the server
+	AuthHeader AuthHeader
+
+	// PlainText requests a free text transcription instead of a JSON schema response,
+	// for servers that do not support structured outputs
+	PlainText bool
+
+	// Prompt is the set of templates the prompts of each image are rendered from (default: prompt.Default())
+	Prompt *prompt.Prompt
+	// Language is the language of the pages, available to the prompt templates as {{.Language}}
+	Language string
+
+	// Prices is the price table used to calculate the cost of each request (default: pricing.Default()).
+	// Models without a price cost nothing.
+	Prices pricing.Table
+
+	// RequestsPerMinute and TokensPerMinute limit the requests sent by all workers sharing the client, or 0 for no limit.
+	// The rate limits and retry delays reported by the server are honored either way.
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// APIType is the URL layout of an OpenAI compatible API
+type APIType string
+
+const (
+	// APITypeOpenAI is the OpenAI layout, also used by proxies and self-hosted servers such as vLLM and Ollama
+	APITypeOpenAI APIType = "openai"
+	// APITypeAzure is the Azure OpenAI layout, which routes requests through deployments
+	APITypeAzure APIType = "azure"
+)
+
+// AuthHeader is the style of header used to send the API key
+type AuthHeader string
+
+const (
+	// AuthHeaderBearer sends the key as "Authorization: Bearer <key>"
+	AuthHeaderBearer AuthHeader = "bearer"
+	// AuthHeaderAPIKey sends the key as "api-key: <key>", as Azure OpenAI expects
+	AuthHeaderAPIKey AuthHeader = "api-key"
+	// AuthHeaderNone sends no key, for local servers without authentication
+	AuthHeaderNone AuthHeader = "none"
+)
+
+var (
+	// DefaultModel is the model used when none is configured
+	DefaultModel = "gpt-4o"
+	// DefaultMaxTokens is the maximum number of completion tokens used when none is configured
+	DefaultMaxTokens = 4096
+	// DefaultMaxRetyAttempts is the maximum number of OCR attempts of an image used when none is configured
+	DefaultMaxRetyAttempts = 5
+	// DefaultBaseURL is the OpenAI API used when no base URL is configured
+	DefaultBaseURL = "https://api.openai.com/v1"
+	// DefaultAzureAPIVersion is the Azure OpenAI API version used when none is configured
+	DefaultAzureAPIVersion = "2024-10-21"
+)
+
 // APIError represents an error from the API with status code
 type APIError struct {
 	Status  int
 	Message string
+	// Type is the error type or status of the vendor, e.g. insufficient_quota, overloaded_error or RESOURCE_EXHAUSTED
+	Type string
+	// Reason is the reason in the details of Gemini errors, e.g. API_KEY_INVALID
+	Reason string
 }
 
 func (e *APIError) Error() string {
@@ -35,168 +124,295 @@ var (
 	ErrAPIRequestFailed = fmt.Errorf("API request failed")
 	// ErrMaxRetriesExceeded is returned when max retries are exceeded
 	ErrMaxRetriesExceeded = fmt.Errorf("max retries exceeded")
-	// ErrRefusalResponse is returned when GPT refuses to process an image
-	ErrRefusalResponse = fmt.Errorf("GPT refused to process image")
+	// ErrRefusalResponse is returned when the model refuses to process an image
+	ErrRefusalResponse = fmt.Errorf("model refused to process image")
+	// ErrInvalidResponse is returned when a structured response does not match the schema
+	ErrInvalidResponse = fmt.Errorf("invalid structured response")
 )
 
-// New creates a new Client instance
-func New(apiKey string) *Client {
-	openAIClient := openai.NewClient(apiKey)
-	return &Client{
-		apiKey:       apiKey,
-		openAIClient: openAIClient,
+// New creates a new Client instance. Zero values in config are replaced with the defaults.
+func New(config Config) *Client {
+	if config.Model == "" {
+		config.Model = DefaultModel
 	}
-}
-
-// ValidateAPIKey validates the OpenAI API key using the usage endpoint
-func (c *Client) ValidateAPIKey(ctx context.Context) error {
-	// Use the models endpoint to validate the key
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.openai.com/v1/models", nil)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = DefaultMaxTokens
 	}
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+	if config.Retry.Retryable == nil {
+		config.Retry.Retryable = isOpenAIRetryable
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return ErrInvalidAPIKey
+	config.Retry = config.Retry.withDefaults()
+	config.Refusal = config.Refusal.withDefaults()
+	if config.Prompt == nil {
+		config.Prompt = prompt.Default()
 	}
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return &APIError{
-			Status:  resp.StatusCode,
-			Message: string(body),
+	if config.APIType == "" {
+		config.APIType = APITypeOpenAI
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultBaseURL
+	}
+	if config.APIType == APITypeAzure && config.APIVersion == "" {
+		config.APIVersion = DefaultAzureAPIVersion
+	}
+	if config.Prices == nil {
+		config.Prices = pricing.Default()
+	}
+	if config.AuthHeader == "" {
+		config.AuthHeader = AuthHeaderBearer
+		if config.APIType == APITypeAzure {
+			config.AuthHeader = AuthHeaderAPIKey
 		}
 	}
 
-	return nil
+	var openAIConfig openai.ClientConfig
+	if config.APIType == APITypeAzure {
+		openAIConfig = openai.DefaultAzureConfig(config.APIKey, config.BaseURL)
+		if config.AzureDeployment != "" {
+			openAIConfig.AzureModelMapperFunc = func(string) string {
+				return config.AzureDeployment
+			}
+		}
+	} else {
+		openAIConfig = openai.DefaultConfig(config.APIKey)
+		openAIConfig.BaseURL = config.BaseURL
+	}
+	openAIConfig.APIVersion = config.APIVersion
+	limiter := newRateLimiter(config.RequestsPerMinute, config.TokensPerMinute)
+	openAIConfig.HTTPClient = &http.Client{
+		Transport: &authTransport{
+			header: config.AuthHeader,
+			apiKey: config.APIKey,
+			base:   &rateLimitTransport{limiter: limiter, base: http.DefaultTransport},
+		},
+	}
+
+	return &Client{
+		config:       config,
+		openAIClient: openai.NewClientWithConfig(openAIConfig),
+		limiter:      limiter,
+	}
 }
 
-var DefaultMaxRetyAttempts = 5
+// authTransport sends the API key using the configured header style
+type authTransport struct {
+	header AuthHeader
+	apiKey string
+	base   http.RoundTripper
+}
 
-// OCRImage processes an image and returns the transcribed text, total cost from all attempts, and the number of attempts made
-func (c *Client) OCRImage(ctx context.Context, imageData []byte) (text string, totalCost float64, attempts int, err error) {
-	totalCost = 0
-	var lastErr error
+// RoundTrip replaces the auth headers set by the openai library with the configured one
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Del("Authorization")
+	req.Header.Del(openai.AzureAPIKeyHeader)
+
+	switch t.header {
+	case AuthHeaderAPIKey:
+		req.Header.Set(openai.AzureAPIKeyHeader, t.apiKey)
+	case AuthHeaderNone:
+	default:
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
 
-	for attempts < DefaultMaxRetyAttempts {
-		attempts++
+	return t.base.RoundTrip(req)
+}
 
-		if attempts > 1 {
-			// Exponential backoff capped at 50ms
-			backoff := max(time.Duration(1<<uint(attempts-1))*time.Millisecond, 10*time.Millisecond)
-			select {
-			case <-ctx.Done():
-				return "", totalCost, attempts, ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
+// ValidateAPIKey validates the API key using the models endpoint of the same API used for OCR
+func (c *Client) ValidateAPIKey(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	_, err := c.openAIClient.ListModels(ctx)
+	if err == nil {
+		return nil
+	}
 
-		text, cost, err := c.ocrImageOnce(ctx, imageData)
-		totalCost += cost
-		if err == nil {
-			return text, totalCost, attempts, nil
+	if apiErr, ok := toAPIError(err); ok {
+		if apiErr.Status == http.StatusUnauthorized {
+			return ErrInvalidAPIKey
 		}
+		return apiErr
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+}
 
-		lastErr = err
-		// Don't retry on authentication errors
-		if apiErr, ok := err.(*APIError); ok && apiErr.Status == http.StatusUnauthorized {
-			return "", totalCost, attempts, err
+// toAPIError converts an error returned by the openai library into an APIError
+func toAPIError(err error) (*APIError, bool) {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		errType := apiErr.Type
+		if code, ok := apiErr.Code.(string); ok && code != "" {
+			errType = code
 		}
+		return &APIError{
+			Status:  apiErr.HTTPStatusCode,
+			Message: apiErr.Message,
+			Type:    errType,
+		}, true
 	}
 
-	return "", totalCost, attempts, fmt.Errorf("%w: %v", ErrMaxRetriesExceeded, lastErr)
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return &APIError{
+			Status:  reqErr.HTTPStatusCode,
+			Message: string(reqErr.Body),
+		}, true
+	}
+
+	return nil, false
+}
+
+// OCRImage processes an image and returns the transcription, total cost from all attempts, and every attempt made
+func (c *Client) OCRImage(ctx context.Context, imageData []byte, page ocr.PageInfo) (transcription ocr.Transcription, totalCost float64, attempts []ocr.Attempt, err error) {
+	req, err := newOCRRequest(c.config, imageData, page)
+	if err != nil {
+		return ocr.Transcription{}, 0, nil, err
+	}
+	tokens := requestTokens(c.config.Model, imageData, c.config.MaxTokens)
+	return retryOCR(ctx, c.limiter, tokens, c.config.Retry, c.config.Refusal, req, c.ocrImageOnce)
+}
+
+// transcriptionSchemaName is the name of the JSON schema sent with structured requests. It names no kind of
+// document, since the same schema is sent with every prompt preset.
+const transcriptionSchemaName = "page_transcription"
+
+// transcriptionSchema is the JSON schema of structured responses.
+// Strict schemas require every property, so missing values are empty instead of absent.
+var transcriptionSchema = jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"transcription": {
+			Type:        jsonschema.String,
+			Description: "All text visible on the page, exactly as it appears",
+		},
+		"date": {
+			Type:        jsonschema.String,
+			Description: "The date written at the top of the page exactly as written, or an empty string",
+		},
+		"page_number": {
+			Type:        jsonschema.String,
+			Description: "The page number written on the page, or an empty string",
+		},
+		"illegible_segments": {
+			Type:        jsonschema.Array,
+			Items:       &jsonschema.Definition{Type: jsonschema.String},
+			Description: "Words or passages that could not be read",
+		},
+	},
+	Required:             []string{"transcription", "date", "page_number", "illegible_segments"},
+	AdditionalProperties: false,
+}
+
+// structuredResponse is the content of a structured response
+type structuredResponse struct {
+	Transcription     string   `json:"transcription"`
+	Date              string   `json:"date"`
+	PageNumber        string   `json:"page_number"`
+	IllegibleSegments []string `json:"illegible_segments"`
+}
+
+// Fingerprint identifies the model, the prompts rendered for the page and the response schema used for OCR
+func (c *Client) Fingerprint(page ocr.PageInfo) string {
+	hash := sha256.Sum256([]byte(c.config.Model + "\x00" + promptFingerprint(c.config, transcriptionSchema, page)))
+	return fmt.Sprintf("%s:%x", c.config.Model, hash[:8])
 }
 
 // ocrImageOnce performs a single OCR request
-func (c *Client) ocrImageOnce(ctx context.Context, imageData []byte) (text string, cost float64, err error) {
+func (c *Client) ocrImageOnce(ctx context.Context, ocrReq ocrRequest) (transcription ocr.Transcription, cost float64, err error) {
 	// Encode image to base64
-	base64Image := base64.StdEncoding.EncodeToString(imageData)
+	base64Image := base64.StdEncoding.EncodeToString(ocrReq.imageData)
+
+	// Ask for a JSON schema response unless the server only supports free text
+	var responseFormat *openai.ChatCompletionResponseFormat
+	if !c.config.PlainText {
+		responseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   transcriptionSchemaName,
+				Schema: &transcriptionSchema,
+				Strict: true,
+			},
+		}
+	}
 
 	// Create the request
 	req := openai.ChatCompletionRequest{
-		Model: "gpt-4o", // Using gpt-4o which supports vision
+		Model: ocrReq.model,
 		Messages: []openai.ChatCompletionMessage{
 			{
-				Role: openai.ChatMessageRoleSystem,
-				Content: `
-You are a professional OCR (Optical Character Recognition) transcription service. 
-Your sole purpose is to convert images of text into machine-readable text format. 
-You are processing personal documents, journals, notes, and written materials for archival and digitization purposes. 
-
-Your task is to transcribe ALL visible text exactly as it appears, including:
-- Handwritten text
-- Printed text
-- Dates and timestamps
-- Any visible characters or symbols
-- Preserving line breaks, spacing and Punctuation
-
-Do not summarize, interpret, or modify the text, simply transcribe what you see.
-The user owns all content in these images and has authorized this transcription. 
-Please, do not refuse to transcribe the image.
-`,
+				Role:    openai.ChatMessageRoleSystem,
+				Content: ocrReq.systemPrompt,
 			},
 			{
 				Role: openai.ChatMessageRoleUser,
 				MultiContent: []openai.ChatMessagePart{
 					{
 						Type: openai.ChatMessagePartTypeText,
-						Text: "This is an image of a journal page. Please transcribe all text visible in this image exactly as it appears, preserving all line breaks, punctuation, spacing, and wording. Do not include any other text in your response.",
+						Text: ocrReq.userPrompt,
 					},
 					{
 						Type: openai.ChatMessagePartTypeImageURL,
 						ImageURL: &openai.ChatMessageImageURL{
-							URL: fmt.Sprintf("data:image/jpeg;base64,%s", base64Image),
+							URL: fmt.Sprintf("data:%s;base64,%s", imageMediaType(ocrReq.imageData), base64Image),
 						},
 					},
 				},
 			},
 		},
-		MaxTokens:   4096,
-		Temperature: 0.1, // Lower temperature for more consistent, literal transcription
+		MaxTokens:      c.config.MaxTokens,
+		Temperature:    0.1, // Lower temperature for more consistent, literal transcription
+		ResponseFormat: responseFormat,
 	}
 
 	resp, err := c.openAIClient.CreateChatCompletion(ctx, req)
 	if err != nil {
 		// Try to extract API error details
-		if apiErr, ok := err.(*openai.APIError); ok {
-			return "", 0, &APIError{
-				Status:  apiErr.HTTPStatusCode,
-				Message: apiErr.Message,
-			}
+		if apiErr, ok := toAPIError(err); ok {
+			return ocr.Transcription{}, 0, apiErr
 		}
-		return "", 0, fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
+		return ocr.Transcription{}, 0, fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
 	}
 
 	if len(resp.Choices) == 0 {
-		return "", 0, fmt.Errorf("%w: no choices in response", ErrAPIRequestFailed)
+		return ocr.Transcription{}, 0, fmt.Errorf("%w: no choices in response", ErrAPIRequestFailed)
+	}
+
+	// Calculate the cost from the price of the model, the prompt tokens include the cached ones
+	usage := pricing.Usage{
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+	}
+	if details := resp.Usage.PromptTokensDetails; details != nil {
+		usage.InputTokens -= details.CachedTokens
+		usage.CachedInputTokens = details.CachedTokens
 	}
+	cost = c.config.Prices.Cost(ocrReq.model, usage)
 
-	text = resp.Choices[0].Message.Content
+	message := resp.Choices[0].Message
 
-	// Check if GPT refused to process the image
-	if c.isRefusalResponse(text) {
-		return "", cost, fmt.Errorf("%w: %s", ErrRefusalResponse, text)
+	// Structured outputs report refusals separately from the content
+	if message.Refusal != "" {
+		return ocr.Transcription{}, cost, &RefusalError{Text: message.Refusal}
 	}
 
-	// Calculate cost based on GPT-4 Vision pricing
-	// Input: $0.01 per 1K tokens, Output: $0.03 per 1K tokens
-	// For simplicity, we'll use a rough estimate based on response tokens
-	inputTokens := float64(resp.Usage.PromptTokens)
-	outputTokens := float64(resp.Usage.CompletionTokens)
-	cost = (inputTokens/1000.0)*0.01 + (outputTokens/1000.0)*0.03
+	transcription.Text = message.Content
+	if !c.config.PlainText {
+		var structured structuredResponse
+		if err := json.Unmarshal([]byte(message.Content), &structured); err != nil {
+			return ocr.Transcription{}, cost, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		transcription = structured.toTranscription()
+	} else if isRefusalResponse(transcription.Text) {
+		// Free text responses have no refusal field, so refusals are recognized by their wording
+		return ocr.Transcription{}, cost, &RefusalError{Text: transcription.Text}
+	}
 
-	return text, cost, nil
+	return transcription, cost, nil
 }
 
-// isRefusalResponse checks if the response indicates GPT refused to process the image
-func (c *Client) isRefusalResponse(text string) bool {
+// isRefusalResponse checks if the response text indicates the model refused to process the image
+func isRefusalResponse(text string) bool {
 	if text == "" {
 		return false
 	}
@@ -20,7 +20,7 @@ func TestAPIError_Error(t *testing.T) {
 }
 
 func TestClient_ValidateAPIKey(t *testing.T) {
-	c := New(testKey)
+	c := New(Config{APIKey: testKey})
 	ctx := context.Background()
 
 	err := c.ValidateAPIKey(ctx)
@@ -30,7 +30,7 @@ func TestClient_ValidateAPIKey(t *testing.T) {
 }
 
 func TestClient_OCRImage_ErrorCase(t *testing.T) {
-	c := New(testKey)
+	c := New(Config{APIKey: testKey})
 	ctx := context.Background()
 
 	// Create a minimal test image (1x1 pixel PNG)
@@ -47,7 +47,7 @@ func TestClient_OCRImage_ErrorCase(t *testing.T) {
 		0x44, 0xAE, 0x42, 0x60, 0x82,
 	}
 
-	text, cost, attempts, err := c.OCRImage(ctx, testImageData)
+	transcription, cost, attempts, err := c.OCRImage(ctx, testImageData, testPage)
 
 	// The test key doesn't have permission for vision API, so we expect an error
 	if err == nil {
@@ -55,8 +55,8 @@ func TestClient_OCRImage_ErrorCase(t *testing.T) {
 	}
 
 	// Text should be empty on error
-	if text != "" {
-		t.Errorf("Expected empty text on error, got: %s", text)
+	if transcription.Text != "" {
+		t.Errorf("Expected empty text on error, got: %s", transcription.Text)
 	}
 
 	// Cost should be 0 or positive (may have attempted retries)
@@ -65,8 +65,8 @@ func TestClient_OCRImage_ErrorCase(t *testing.T) {
 	}
 
 	// Should have made at least one attempt
-	if attempts < 1 {
-		t.Errorf("Expected at least 1 attempt, got: %d", attempts)
+	if len(attempts) < 1 {
+		t.Errorf("Expected at least 1 attempt, got: %d", len(attempts))
 	}
 
 	// Verify it's an API error
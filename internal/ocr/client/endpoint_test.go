@@ -0,0 +1,177 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+// chatCompletionResponse is a minimal successful chat completion body
+const chatCompletionResponse = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"model": "gpt-4o",
+	"choices": [{"index": 0, "message": {"role": "assistant", "content": "{\"transcription\": \"Monday, January 1, 2024\\nDear diary\", \"date\": \"Monday, January 1, 2024\", \"page_number\": \"\", \"illegible_segments\": []}"}, "finish_reason": "stop"}],
+	"usage": {"prompt_tokens": 1000, "completion_tokens": 100, "total_tokens": 1100}
+}`
+
+// testPage describes the image of the OCR requests of the tests
+var testPage = ocr.PageInfo{Name: "Img-0001.jpg", Index: 1}
+
+// fakeServer starts an httptest server that records each request and answers with handler
+func fakeServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *[]*http.Request) {
+	t.Helper()
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Clone(context.Background()))
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// okHandler answers the models and chat completion endpoints
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodGet {
+		w.Write([]byte(`{"object": "list", "data": []}`))
+		return
+	}
+	w.Write([]byte(chatCompletionResponse))
+}
+
+func TestClient_Endpoints(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		modelsPath     string
+		completionPath string
+		apiVersion     string
+		checkAuth      func(r *http.Request) bool
+	}{
+		{
+			name:           "openai compatible",
+			config:         Config{APIKey: "key"},
+			modelsPath:     "/v1/models",
+			completionPath: "/v1/chat/completions",
+			checkAuth: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer key" && r.Header.Get("api-key") == ""
+			},
+		},
+		{
+			name:           "azure",
+			config:         Config{APIKey: "key", APIType: APITypeAzure, AzureDeployment: "journal-ocr"},
+			modelsPath:     "/openai/models",
+			completionPath: "/openai/deployments/journal-ocr/chat/completions",
+			apiVersion:     DefaultAzureAPIVersion,
+			checkAuth: func(r *http.Request) bool {
+				return r.Header.Get("api-key") == "key" && r.Header.Get("Authorization") == ""
+			},
+		},
+		{
+			name:           "azure with explicit version and bearer auth",
+			config:         Config{APIKey: "key", APIType: APITypeAzure, APIVersion: "2025-01-01", AuthHeader: AuthHeaderBearer},
+			modelsPath:     "/openai/models",
+			completionPath: "/openai/deployments/gpt-4o/chat/completions",
+			apiVersion:     "2025-01-01",
+			checkAuth: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "Bearer key" && r.Header.Get("api-key") == ""
+			},
+		},
+		{
+			name:           "local server without auth",
+			config:         Config{AuthHeader: AuthHeaderNone},
+			modelsPath:     "/v1/models",
+			completionPath: "/v1/chat/completions",
+			checkAuth: func(r *http.Request) bool {
+				return r.Header.Get("Authorization") == "" && r.Header.Get("api-key") == ""
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := fakeServer(t, okHandler)
+			tt.config.BaseURL = server.URL
+			if tt.config.APIType != APITypeAzure {
+				tt.config.BaseURL += "/v1"
+			}
+			c := New(tt.config)
+
+			if err := c.ValidateAPIKey(context.Background()); err != nil {
+				t.Fatalf("Expected validation to succeed, got: %v", err)
+			}
+			transcription, _, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+			if err != nil {
+				t.Fatalf("Expected OCR to succeed, got: %v", err)
+			}
+			if transcription.Text != "Monday, January 1, 2024\nDear diary" {
+				t.Errorf("Unexpected text: %q", transcription.Text)
+			}
+			if len(attempts) != 1 {
+				t.Errorf("Expected 1 attempt, got %d", len(attempts))
+			}
+
+			if len(*requests) != 2 {
+				t.Fatalf("Expected 2 requests, got %d", len(*requests))
+			}
+			for i, path := range []string{tt.modelsPath, tt.completionPath} {
+				r := (*requests)[i]
+				if r.URL.Path != path {
+					t.Errorf("Expected path %s, got %s", path, r.URL.Path)
+				}
+				if got := r.URL.Query().Get("api-version"); got != tt.apiVersion {
+					t.Errorf("Expected api-version %q, got %q", tt.apiVersion, got)
+				}
+				if !tt.checkAuth(r) {
+					t.Errorf("Unexpected auth headers on %s: %v", path, r.Header)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_ValidateAPIKey_Errors(t *testing.T) {
+	t.Run("unauthorized", func(t *testing.T) {
+		server, _ := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`))
+		})
+		c := New(Config{APIKey: "bad", BaseURL: server.URL})
+
+		err := c.ValidateAPIKey(context.Background())
+		if !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Expected ErrInvalidAPIKey, got: %v", err)
+		}
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		server, _ := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("bad gateway"))
+		})
+		c := New(Config{APIKey: "key", BaseURL: server.URL})
+
+		err := c.ValidateAPIKey(context.Background())
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway {
+			t.Errorf("Expected APIError with status 502, got: %v", err)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		server, _ := fakeServer(t, okHandler)
+		server.Close()
+		c := New(Config{APIKey: "key", BaseURL: server.URL})
+
+		err := c.ValidateAPIKey(context.Background())
+		if !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("Expected ErrInvalidAPIKey, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/pricing"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
+)
+
+// GeminiClient implements the ocr.OCRClient interface for the Google Gemini generateContent API
+type GeminiClient struct {
+	config     Config
+	httpClient *http.Client
+	limiter    *rateLimiter
+}
+
+var _ ocr.OCRClient = (*GeminiClient)(nil)
+
+var (
+	// DefaultGeminiModel is the Gemini model used when none is configured
+	DefaultGeminiModel = "gemini-2.5-flash"
+	// DefaultGeminiBaseURL is the Gemini API used when no base URL is configured
+	DefaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
+)
+
+// geminiBlockedFinishReasons are the finish reasons of candidates that were blocked instead of answered
+var geminiBlockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// geminiTranscriptionSchema is the response schema of structured responses.
+// Gemini accepts a subset of OpenAPI schemas, which is why transcriptionSchema can not be reused.
+var geminiTranscriptionSchema = map[string]any{
+	"type": "OBJECT",
+	"properties": map[string]any{
+		"transcription":      map[string]any{"type": "STRING", "description": "All text visible on the page, exactly as it appears"},
+		"date":               map[string]any{"type": "STRING", "description": "The date written at the top of the page exactly as written, or an empty string"},
+		"page_number":        map[string]any{"type": "STRING", "description": "The page number written on the page, or an empty string"},
+		"illegible_segments": map[string]any{"type": "ARRAY", "items": map[string]any{"type": "STRING"}, "description": "Words or passages that could not be read"},
+	},
+	"required":         []string{"transcription", "date", "page_number", "illegible_segments"},
+	"propertyOrdering": []string{"transcription", "date", "page_number", "illegible_segments"},
+}
+
+// NewGemini creates a new GeminiClient instance. Zero values in config are replaced with the defaults.
+// The OpenAI specific APIType, APIVersion and AzureDeployment settings are ignored.
+func NewGemini(config Config) *GeminiClient {
+	if config.Model == "" {
+		config.Model = DefaultGeminiModel
+	}
+	if config.MaxTokens <= 0 {
+		config.MaxTokens = DefaultMaxTokens
+	}
+	if config.Retry.Retryable == nil {
+		config.Retry.Retryable = isGeminiRetryable
+	}
+	config.Retry = config.Retry.withDefaults()
+	config.Refusal = config.Refusal.withDefaults()
+	if config.Prompt == nil {
+		config.Prompt = prompt.Default()
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultGeminiBaseURL
+	}
+	if config.Prices == nil {
+		config.Prices = pricing.Default()
+	}
+
+	limiter := newRateLimiter(config.RequestsPerMinute, config.TokensPerMinute)
+	return &GeminiClient{
+		config:     config,
+		httpClient: &http.Client{Transport: &rateLimitTransport{limiter: limiter, base: http.DefaultTransport}},
+		limiter:    limiter,
+	}
+}
+
+// header returns the headers sent with each request
+func (c *GeminiClient) header() http.Header {
+	header := http.Header{}
+	if c.config.AuthHeader != AuthHeaderNone {
+		header.Set("x-goog-api-key", c.config.APIKey)
+	}
+	return header
+}
+
+// ValidateAPIKey validates the API key using the models endpoint of the same API used for OCR
+func (c *GeminiClient) ValidateAPIKey(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err := doJSON(ctx, c.httpClient, http.MethodGet, c.config.BaseURL+"/models", c.header(), nil, nil)
+	if err == nil {
+		return nil
+	}
+
+	if apiErr, ok := err.(*APIError); ok {
+		// Gemini answers requests with an invalid key with 400 INVALID_ARGUMENT and the reason API_KEY_INVALID,
+		// while other bad requests are errors of their own
+		invalidKey := apiErr.Status == http.StatusBadRequest && apiErr.Type == "INVALID_ARGUMENT" && apiErr.Reason == "API_KEY_INVALID"
+		if invalidKey || apiErr.Status == http.StatusUnauthorized || apiErr.Status == http.StatusForbidden {
+			return ErrInvalidAPIKey
+		}
+		return apiErr
+	}
+	return fmt.Errorf("%w: %v", ErrInvalidAPIKey, err)
+}
+
+// OCRImage processes an image and returns the transcription, total cost from all attempts, and every attempt made
+func (c *GeminiClient) OCRImage(ctx context.Context, imageData []byte, page ocr.PageInfo) (transcription ocr.Transcription, totalCost float64, attempts []ocr.Attempt, err error) {
+	req, err := newOCRRequest(c.config, imageData, page)
+	if err != nil {
+		return ocr.Transcription{}, 0, nil, err
+	}
+	tokens := requestTokens(c.config.Model, imageData, c.config.MaxTokens)
+	return retryOCR(ctx, c.limiter, tokens, c.config.Retry, c.config.Refusal, req, c.ocrImageOnce)
+}
+
+// Fingerprint identifies the provider, model, prompts rendered for the page and response schema used for OCR
+func (c *GeminiClient) Fingerprint(page ocr.PageInfo) string {
+	hash := sha256.Sum256([]byte(string(ProviderGemini) + "\x00" + c.config.Model + "\x00" + promptFingerprint(c.config, geminiTranscriptionSchema, page)))
+	return fmt.Sprintf("%s:%x", c.config.Model, hash[:8])
+}
+
+// geminiRequest is the body of a generateContent request
+type geminiRequest struct {
+	SystemInstruction geminiContent          `json:"systemInstruction"`
+	Contents          []geminiContent        `json:"contents"`
+	GenerationConfig  geminiGenerationConfig `json:"generationConfig"`
+}
+
+// geminiContent is a message made of parts
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiPart is a text or inline image part of a message
+type geminiPart struct {
+	Text       string            `json:"text,omitempty"`
+	InlineData *geminiInlineData `json:"inlineData,omitempty"`
+}
+
+// geminiInlineData is the base64 encoded data of an image part
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// geminiGenerationConfig configures the response of a generateContent request
+type geminiGenerationConfig struct {
+	Temperature      float64 `json:"temperature"`
+	MaxOutputTokens  int     `json:"maxOutputTokens"`
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+	ResponseSchema   any     `json:"responseSchema,omitempty"`
+}
+
+// geminiResponse is the body of a generateContent response
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// ocrImageOnce performs a single OCR request
+func (c *GeminiClient) ocrImageOnce(ctx context.Context, ocrReq ocrRequest) (transcription ocr.Transcription, cost float64, err error) {
+	generationConfig := geminiGenerationConfig{
+		Temperature:     0.1, // Lower temperature for more consistent, literal transcription
+		MaxOutputTokens: c.config.MaxTokens,
+	}
+	if !c.config.PlainText {
+		generationConfig.ResponseMimeType = "application/json"
+		generationConfig.ResponseSchema = geminiTranscriptionSchema
+	}
+
+	req := geminiRequest{
+		SystemInstruction: geminiContent{Parts: []geminiPart{{Text: ocrReq.systemPrompt}}},
+		Contents: []geminiContent{{
+			Role: "user",
+			Parts: []geminiPart{
+				{InlineData: &geminiInlineData{
+					MimeType: imageMediaType(ocrReq.imageData),
+					Data:     base64.StdEncoding.EncodeToString(ocrReq.imageData),
+				}},
+				{Text: ocrReq.userPrompt},
+			},
+		}},
+		GenerationConfig: generationConfig,
+	}
+
+	endpoint := c.config.BaseURL + "/models/" + url.PathEscape(ocrReq.model) + ":generateContent"
+	var resp geminiResponse
+	if err := doJSON(ctx, c.httpClient, http.MethodPost, endpoint, c.header(), req, &resp); err != nil {
+		return ocr.Transcription{}, 0, err
+	}
+
+	// The prompt tokens include the cached ones and thinking tokens are billed as output tokens
+	usage := resp.UsageMetadata
+	cost = c.config.Prices.Cost(ocrReq.model, pricing.Usage{
+		InputTokens:       usage.PromptTokenCount - usage.CachedContentTokenCount,
+		CachedInputTokens: usage.CachedContentTokenCount,
+		OutputTokens:      usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+	})
+
+	// Blocked prompts have no candidates and blocked answers have a safety finish reason
+	if resp.PromptFeedback.BlockReason != "" {
+		return ocr.Transcription{}, cost, &RefusalError{Text: fmt.Sprintf("prompt blocked (%s)", resp.PromptFeedback.BlockReason)}
+	}
+	if len(resp.Candidates) == 0 {
+		return ocr.Transcription{}, cost, fmt.Errorf("%w: no candidates in response", ErrAPIRequestFailed)
+	}
+	candidate := resp.Candidates[0]
+	if geminiBlockedFinishReasons[candidate.FinishReason] {
+		return ocr.Transcription{}, cost, &RefusalError{Text: fmt.Sprintf("response blocked (%s)", candidate.FinishReason)}
+	}
+
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+
+	transcription.Text = text.String()
+	if !c.config.PlainText {
+		var structured structuredResponse
+		if err := json.Unmarshal([]byte(transcription.Text), &structured); err != nil {
+			// A text answer instead of the requested JSON is most likely a refusal
+			if isRefusalResponse(transcription.Text) {
+				return ocr.Transcription{}, cost, &RefusalError{Text: transcription.Text}
+			}
+			return ocr.Transcription{}, cost, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		transcription = structured.toTranscription()
+	} else if isRefusalResponse(transcription.Text) {
+		// Refusals that were not blocked are recognized by their wording, Gemini has no refusal field
+		return ocr.Transcription{}, cost, &RefusalError{Text: transcription.Text}
+	}
+
+	return transcription, cost, nil
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// geminiHandler answers the models and generateContent endpoints with the given status and generateContent response
+func geminiHandler(t *testing.T, status int, response string, bodies *[]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"models": []}`))
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		*bodies = append(*bodies, body)
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}
+}
+
+func TestGeminiClient_OCRImage(t *testing.T) {
+	var bodies []map[string]any
+	server, requests := fakeServer(t, geminiHandler(t, http.StatusOK, `{
+		"candidates": [{"content": {"role": "model", "parts": [{"text": "{\"transcription\": \"Monday, January 1, 2024\\nDear diary\", \"date\": \"Monday, January 1, 2024\", \"page_number\": \"\", \"illegible_segments\": [\"[?]\"]}"}]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 1000000, "candidatesTokenCount": 100000, "thoughtsTokenCount": 100000}
+	}`, &bodies))
+	c := NewGemini(Config{APIKey: "key", BaseURL: server.URL, Model: "gemini-2.5-flash-lite"})
+
+	transcription, cost, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "Monday, January 1, 2024\nDear diary" || transcription.Date != "Monday, January 1, 2024" || len(transcription.IllegibleSegments) != 1 {
+		t.Errorf("Unexpected transcription: %+v", transcription)
+	}
+	if len(attempts) != 1 {
+		t.Errorf("Expected 1 attempt, got %d", len(attempts))
+	}
+	// 1M input tokens at $0.10 and 200K output and thinking tokens at $0.40 per million,
+	// the flash-lite price is used rather than the flash price
+	if cost < 0.1799 || cost > 0.1801 {
+		t.Errorf("Expected a cost of $0.18, got: %f", cost)
+	}
+
+	r := (*requests)[0]
+	if r.URL.Path != "/models/gemini-2.5-flash-lite:generateContent" || r.Header.Get("x-goog-api-key") != "key" {
+		t.Errorf("Unexpected request: %s %v", r.URL.Path, r.Header)
+	}
+
+	config, _ := bodies[0]["generationConfig"].(map[string]any)
+	if config["responseMimeType"] != "application/json" || config["responseSchema"] == nil {
+		t.Errorf("Unexpected generation config: %v", config)
+	}
+}
+
+func TestGeminiClient_OCRImage_PlainText(t *testing.T) {
+	var bodies []map[string]any
+	server, _ := fakeServer(t, geminiHandler(t, http.StatusOK, `{
+		"candidates": [{"content": {"parts": [{"text": "Monday, January 1, 2024\n"}, {"text": "Dear diary"}]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 1000, "candidatesTokenCount": 100}
+	}`, &bodies))
+	c := NewGemini(Config{APIKey: "key", BaseURL: server.URL, PlainText: true})
+
+	transcription, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "Monday, January 1, 2024\nDear diary" {
+		t.Errorf("Unexpected text: %q", transcription.Text)
+	}
+	config, _ := bodies[0]["generationConfig"].(map[string]any)
+	if _, ok := config["responseSchema"]; ok {
+		t.Errorf("Expected no response schema, got: %v", config["responseSchema"])
+	}
+}
+
+func TestGeminiClient_OCRImage_StructuredRefusalWording(t *testing.T) {
+	// The wording of a page is not mistaken for a refusal when the response is structured
+	var bodies []map[string]any
+	server, _ := fakeServer(t, geminiHandler(t, http.StatusOK, `{
+		"candidates": [{"content": {"parts": [{"text": "{\"transcription\": \"I'm sorry, I can't help myself.\", \"date\": \"\", \"page_number\": \"\", \"illegible_segments\": []}"}]}, "finishReason": "STOP"}]
+	}`, &bodies))
+	c := NewGemini(Config{APIKey: "key", BaseURL: server.URL})
+
+	transcription, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil || transcription.Text != "I'm sorry, I can't help myself." {
+		t.Errorf("Expected the page to be transcribed, got %q and: %v", transcription.Text, err)
+	}
+}
+
+func TestGeminiClient_OCRImage_Errors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		response     string
+		wantErr      string
+		wantAttempts int
+	}{
+		{
+			name:         "blocked prompt",
+			status:       http.StatusOK,
+			response:     `{"promptFeedback": {"blockReason": "PROHIBITED_CONTENT"}, "usageMetadata": {"promptTokenCount": 1000}}`,
+			wantErr:      "model refused",
+			wantAttempts: 2,
+		},
+		{
+			name:         "blocked answer",
+			status:       http.StatusOK,
+			response:     `{"candidates": [{"content": {"parts": []}, "finishReason": "SAFETY"}]}`,
+			wantErr:      "model refused",
+			wantAttempts: 2,
+		},
+		{
+			name:         "invalid json",
+			status:       http.StatusOK,
+			response:     `{"candidates": [{"content": {"parts": [{"text": "Dear diary"}]}, "finishReason": "STOP"}]}`,
+			wantErr:      "invalid structured response",
+			wantAttempts: 2,
+		},
+		{
+			name:         "refusal text instead of json",
+			status:       http.StatusOK,
+			response:     `{"candidates": [{"content": {"parts": [{"text": "I'm sorry, I can't transcribe this image."}]}, "finishReason": "STOP"}]}`,
+			wantErr:      "model refused",
+			wantAttempts: 2,
+		},
+		{
+			name:         "resource exhausted is retried",
+			status:       http.StatusTooManyRequests,
+			response:     `{"error": {"code": 429, "message": "Quota exceeded", "status": "RESOURCE_EXHAUSTED"}}`,
+			wantErr:      "max retries exceeded",
+			wantAttempts: 2,
+		},
+		{
+			name:         "unavailable is retried",
+			status:       http.StatusServiceUnavailable,
+			response:     `{"error": {"code": 503, "message": "The model is overloaded", "status": "UNAVAILABLE"}}`,
+			wantErr:      "max retries exceeded",
+			wantAttempts: 2,
+		},
+		{
+			name:         "not found is not retried",
+			status:       http.StatusNotFound,
+			response:     `{"error": {"code": 404, "message": "models/unknown is not found", "status": "NOT_FOUND"}}`,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies []map[string]any
+			server, _ := fakeServer(t, geminiHandler(t, tt.status, tt.response, &bodies))
+			c := NewGemini(Config{APIKey: "key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+
+			_, _, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected %v, got: %v", tt.wantErr, err)
+			}
+			if len(attempts) != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, len(attempts))
+			}
+		})
+	}
+}
+
+func TestGeminiClient_ValidateAPIKey(t *testing.T) {
+	respond := func(status int, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}
+	}
+
+	// An invalid key is reported as such
+	server, _ := fakeServer(t, respond(http.StatusBadRequest, `{"error": {"code": 400, "message": "API key not valid. Please pass a valid API key.", "status": "INVALID_ARGUMENT",
+		"details": [{"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "API_KEY_INVALID", "domain": "googleapis.com"}]}}`))
+	c := NewGemini(Config{APIKey: "bad", BaseURL: server.URL})
+	if err := c.ValidateAPIKey(context.Background()); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey, got: %v", err)
+	}
+
+	// Any other bad request is returned as it is
+	server, _ = fakeServer(t, respond(http.StatusBadRequest, `{"error": {"code": 400, "message": "User location is not supported for the API use.", "status": "FAILED_PRECONDITION"}}`))
+	c = NewGemini(Config{APIKey: "key", BaseURL: server.URL})
+	err := c.ValidateAPIKey(context.Background())
+	var apiErr *APIError
+	if errors.Is(err, ErrInvalidAPIKey) || !errors.As(err, &apiErr) {
+		t.Fatalf("Expected the API error, got: %v", err)
+	}
+	if apiErr.Type != "FAILED_PRECONDITION" || apiErr.Message != "User location is not supported for the API use." {
+		t.Errorf("Unexpected API error: %+v", apiErr)
+	}
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
+)
+
+// Provider is the vendor API used for OCR
+type Provider string
+
+const (
+	// ProviderOpenAI uses the OpenAI chat completions API, as well as compatible servers and Azure OpenAI
+	ProviderOpenAI Provider = "openai"
+	// ProviderAnthropic uses the Anthropic Messages API
+	ProviderAnthropic Provider = "anthropic"
+	// ProviderGemini uses the Google Gemini generateContent API
+	ProviderGemini Provider = "gemini"
+)
+
+// Factory creates the OCR client of a provider. Zero values in config are replaced with the provider's defaults.
+type Factory func(config Config) ocr.OCRClient
+
+// registry maps each provider to the factory of its client. It is fixed, so the providers are known up front.
+var registry = map[Provider]Factory{
+	ProviderOpenAI:    func(config Config) ocr.OCRClient { return New(config) },
+	ProviderAnthropic: func(config Config) ocr.OCRClient { return NewAnthropic(config) },
+	ProviderGemini:    func(config Config) ocr.OCRClient { return NewGemini(config) },
+}
+
+// DefaultModels is the model used by each provider when none is configured
+var DefaultModels = map[Provider]string{
+	ProviderOpenAI:    DefaultModel,
+	ProviderAnthropic: DefaultAnthropicModel,
+	ProviderGemini:    DefaultGeminiModel,
+}
+
+// ErrUnknownProvider is returned when no client is registered for a provider
+var ErrUnknownProvider = fmt.Errorf("unknown provider")
+
+// Providers returns the registered providers in alphabetical order
+func Providers() []Provider {
+	providers := make([]Provider, 0, len(registry))
+	for provider := range registry {
+		providers = append(providers, provider)
+	}
+	slices.Sort(providers)
+	return providers
+}
+
+// NewProvider creates the OCR client of the provider. An empty provider selects OpenAI.
+func NewProvider(provider Provider, config Config) (ocr.OCRClient, error) {
+	if provider == "" {
+		provider = ProviderOpenAI
+	}
+	factory, ok := registry[provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, provider)
+	}
+	return factory(config), nil
+}
+
+// toTranscription converts a structured response into a transcription
+func (r structuredResponse) toTranscription() ocr.Transcription {
+	return ocr.Transcription{
+		Text:              r.Transcription,
+		Date:              r.Date,
+		PageNumber:        r.PageNumber,
+		IllegibleSegments: r.IllegibleSegments,
+	}
+}
+
+// newOCRRequest renders the prompts of the page into the first request of an image
+func newOCRRequest(config Config, imageData []byte, page ocr.PageInfo) (ocrRequest, error) {
+	rendered, err := config.Prompt.Render(prompt.Vars{ImageName: page.Name, PageIndex: page.Index, Language: config.Language})
+	if err != nil {
+		return ocrRequest{}, err
+	}
+	req := ocrRequest{imageData: imageData, model: config.Model, systemPrompt: rendered.System, userPrompt: rendered.Structured}
+	if config.PlainText {
+		req.userPrompt = rendered.User
+	}
+	return req, nil
+}
+
+// promptFingerprint identifies the prompt templates, the prompts rendered for the page, the language and the
+// response schema, if any, of the config. Templates that use the page, e.g. {{.PageIndex}}, differ from page to page.
+func promptFingerprint(config Config, schema any, page ocr.PageInfo) string {
+	fingerprint := config.Prompt.Hash() + "\x00" + config.Language
+	if req, err := newOCRRequest(config, nil, page); err == nil {
+		fingerprint += "\x00" + req.systemPrompt + "\x00" + req.userPrompt
+	}
+	if !config.PlainText {
+		data, _ := json.Marshal(schema)
+		fingerprint += "\x00" + string(data)
+	}
+	return fingerprint
+}
+
+// imageMediaType returns the MIME type of the image, falling back to JPEG for unknown content
+func imageMediaType(imageData []byte) string {
+	mediaType := http.DetectContentType(imageData)
+	if !strings.HasPrefix(mediaType, "image/") {
+		return "image/jpeg"
+	}
+	return mediaType
+}
+
+// doJSON sends body, if any, as JSON and decodes the JSON response into out.
+// Responses with an error status are returned as an APIError holding the error message of the body.
+func doJSON(ctx context.Context, httpClient *http.Client, method, url string, header http.Header, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
+	}
+	req.Header = header.Clone()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Anthropic and Gemini both describe errors as {"error": {"message": "..."}}, with the kind of error
+		// in the type of Anthropic errors and the status of Gemini errors, and its reason in the details of Gemini errors
+		var errBody struct {
+			Error struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+				Status  string `json:"status"`
+				Details []struct {
+					Reason string `json:"reason"`
+				} `json:"details"`
+			} `json:"error"`
+		}
+		apiErr := &APIError{Status: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		if json.Unmarshal(data, &errBody) == nil && errBody.Error.Message != "" {
+			apiErr.Message = errBody.Error.Message
+			apiErr.Type = cmp.Or(errBody.Error.Type, errBody.Error.Status)
+			for _, detail := range errBody.Error.Details {
+				apiErr.Reason = cmp.Or(apiErr.Reason, detail.Reason)
+			}
+		}
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%w: %v", ErrAPIRequestFailed, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		want     any
+	}{
+		{provider: "", want: &Client{}},
+		{provider: ProviderOpenAI, want: &Client{}},
+		{provider: ProviderAnthropic, want: &AnthropicClient{}},
+		{provider: ProviderGemini, want: &GeminiClient{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.provider), func(t *testing.T) {
+			c, err := NewProvider(tt.provider, Config{APIKey: "key"})
+			if err != nil {
+				t.Fatalf("Expected provider to be created, got: %v", err)
+			}
+			if reflect.TypeOf(c) != reflect.TypeOf(tt.want) {
+				t.Errorf("Expected %T, got %T", tt.want, c)
+			}
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		if _, err := NewProvider("mistral", Config{}); !errors.Is(err, ErrUnknownProvider) {
+			t.Errorf("Expected ErrUnknownProvider, got: %v", err)
+		}
+	})
+}
+
+func TestProviders(t *testing.T) {
+	want := []Provider{ProviderAnthropic, ProviderGemini, ProviderOpenAI}
+	if got := Providers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestFingerprint_Providers(t *testing.T) {
+	// Checkpoints of one provider must not be resumed by another, even for the same model name
+	config := Config{Model: "shared-model"}
+	fingerprints := map[string]bool{
+		New(config).Fingerprint(testPage):          true,
+		NewAnthropic(config).Fingerprint(testPage): true,
+		NewGemini(config).Fingerprint(testPage):    true,
+	}
+	if len(fingerprints) != 3 {
+		t.Errorf("Expected 3 distinct fingerprints, got %d", len(fingerprints))
+	}
+}
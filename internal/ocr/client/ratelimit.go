@@ -0,0 +1,199 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/pricing"
+)
+
+// DefaultRateLimitPause is how long all requests are paused after a 429 response that does not say when to retry
+var DefaultRateLimitPause = time.Second
+
+// rateLimitHeaders are the remaining and reset headers of the request and token limits of each provider.
+// OpenAI reports resets as durations, e.g. "6m0s", and Anthropic as RFC 3339 times.
+var rateLimitHeaders = [][2]string{
+	{"x-ratelimit-remaining-requests", "x-ratelimit-reset-requests"},
+	{"x-ratelimit-remaining-tokens", "x-ratelimit-reset-tokens"},
+	{"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-requests-reset"},
+	{"anthropic-ratelimit-tokens-remaining", "anthropic-ratelimit-tokens-reset"},
+}
+
+// rateLimiter paces the requests of every worker sharing a client. It enforces the configured requests and
+// tokens per minute, and pauses all requests when the server reports that a limit is used up or asks to retry later.
+type rateLimiter struct {
+	mu          sync.Mutex
+	requests    *bucket
+	tokens      *bucket
+	pausedUntil time.Time
+}
+
+// newRateLimiter creates a rate limiter. Limits of 0 or less are not enforced,
+// but the limits reported by the server always are.
+func newRateLimiter(requestsPerMinute, tokensPerMinute int) *rateLimiter {
+	return &rateLimiter{
+		requests: newBucket(requestsPerMinute),
+		tokens:   newBucket(tokensPerMinute),
+	}
+}
+
+// wait blocks until a request of the given number of tokens may be sent, or the context is done
+func (l *rateLimiter) wait(ctx context.Context, tokens int) error {
+	for {
+		delay := l.reserve(tokens, time.Now())
+		if delay <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// reserve takes a request and its tokens from the limits and returns 0,
+// or returns how long to wait before trying again when they are not available yet
+func (l *rateLimiter) reserve(tokens int, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delay := max(l.pausedUntil.Sub(now), l.requests.delay(1, now), l.tokens.delay(float64(tokens), now))
+	if delay > 0 {
+		return delay
+	}
+	l.requests.take(1)
+	l.tokens.take(float64(tokens))
+	return 0
+}
+
+// observe pauses all requests when the response asks to retry later or reports that a limit is used up
+func (l *rateLimiter) observe(status int, header http.Header, now time.Time) {
+	var until time.Time
+	if delay, ok := retryAfter(header, now); ok {
+		until = now.Add(delay)
+	}
+	for _, names := range rateLimitHeaders {
+		if header.Get(names[0]) != "0" {
+			continue
+		}
+		if reset, ok := parseReset(header.Get(names[1]), now); ok && reset.After(until) {
+			until = reset
+		}
+	}
+	if status == http.StatusTooManyRequests && until.IsZero() {
+		until = now.Add(DefaultRateLimitPause)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// retryAfter returns the delay of the retry-after-ms header sent by OpenAI,
+// or of the standard Retry-After header in seconds or as an HTTP date
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if ms, err := strconv.ParseFloat(header.Get("retry-after-ms"), 64); err == nil && ms >= 0 {
+		return time.Duration(ms * float64(time.Millisecond)), true
+	}
+	value := header.Get("Retry-After")
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// parseReset returns the time a rate limit resets from a duration, an RFC 3339 time or a number of seconds
+func parseReset(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return now.Add(time.Duration(seconds * float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
+
+// bucket is a token bucket that refills its capacity once per minute. A nil bucket has no limit.
+type bucket struct {
+	capacity  float64
+	available float64
+	updated   time.Time
+}
+
+// newBucket returns a full bucket of perMinute, or nil when perMinute is 0 or less
+func newBucket(perMinute int) *bucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &bucket{capacity: float64(perMinute), available: float64(perMinute)}
+}
+
+// delay returns how long until n is available. More than the capacity is treated as the whole capacity,
+// so a single large request is never blocked forever.
+func (b *bucket) delay(n float64, now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+	if !b.updated.IsZero() {
+		b.available = min(b.capacity, b.available+now.Sub(b.updated).Minutes()*b.capacity)
+	}
+	b.updated = now
+
+	n = min(n, b.capacity)
+	if b.available >= n {
+		return 0
+	}
+	return time.Duration((n - b.available) / b.capacity * float64(time.Minute))
+}
+
+// take removes n from the bucket, as far as the capacity
+func (b *bucket) take(n float64) {
+	if b == nil {
+		return
+	}
+	b.available -= min(n, b.capacity)
+}
+
+// rateLimitTransport reports the status and headers of every response to the rate limiter
+type rateLimitTransport struct {
+	limiter *rateLimiter
+	base    http.RoundTripper
+}
+
+// RoundTrip sends the request with the base transport and observes the response
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.limiter.observe(resp.StatusCode, resp.Header, time.Now())
+	}
+	return resp, err
+}
+
+// requestTokens estimates the tokens a request counts against the tokens per minute limit: the image,
+// the prompts and the maximum number of output tokens, which is how OpenAI counts requests before they run.
+// Images that can not be decoded are counted as if they were as large as images are resized to.
+func requestTokens(model string, imageData []byte, maxTokens int) int {
+	width, height := ocr.DefaultMaxImageDimension, ocr.DefaultMaxImageDimension
+	if config, _, err := image.DecodeConfig(bytes.NewReader(imageData)); err == nil {
+		width, height = config.Width, config.Height
+	}
+	return pricing.ImageTokens(model, width, height) + pricing.PromptTokens + maxTokens
+}
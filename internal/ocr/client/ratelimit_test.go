@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rateLimitedHandler answers the first chat completion with 429 and the given headers, and every other one with success.
+// It records the time of each request.
+func rateLimitedHandler(header map[string]string, times *[]time.Time) http.HandlerFunc {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*times = append(*times, time.Now())
+		first := len(*times) == 1
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if first {
+			for name, value := range header {
+				w.Header().Set(name, value)
+			}
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "Rate limit reached", "type": "requests"}}`))
+			return
+		}
+		w.Write([]byte(chatCompletionResponse))
+	}
+}
+
+func TestClient_OCRImage_RateLimitPause(t *testing.T) {
+	tests := []struct {
+		name   string
+		header map[string]string
+		pause  time.Duration
+	}{
+		{name: "retry-after-ms", header: map[string]string{"retry-after-ms": "200"}, pause: 200 * time.Millisecond},
+		{name: "retry-after", header: map[string]string{"Retry-After": "1"}, pause: time.Second},
+		{name: "requests used up", header: map[string]string{"x-ratelimit-remaining-requests": "0", "x-ratelimit-reset-requests": "150ms"}, pause: 150 * time.Millisecond},
+		{name: "tokens used up", header: map[string]string{"x-ratelimit-remaining-tokens": "0", "x-ratelimit-reset-tokens": "250ms"}, pause: 250 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var times []time.Time
+			server, _ := fakeServer(t, rateLimitedHandler(tt.header, &times))
+			c := New(Config{APIKey: "key", BaseURL: server.URL, PlainText: true, Retry: RetryPolicy{MaxAttempts: 1, BaseDelay: time.Millisecond}})
+
+			// The first worker is rate limited and gives up, which pauses the other workers as well
+			if _, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage); err == nil {
+				t.Fatal("Expected the rate limited request to fail")
+			}
+			if _, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage); err != nil {
+				t.Fatalf("Expected OCR to succeed after the pause, got: %v", err)
+			}
+
+			if len(times) != 2 {
+				t.Fatalf("Expected 2 requests, got %d", len(times))
+			}
+			if waited := times[1].Sub(times[0]); waited < tt.pause-10*time.Millisecond {
+				t.Errorf("Expected a pause of %s, got %s", tt.pause, waited)
+			}
+		})
+	}
+}
+
+func TestClient_OCRImage_RateLimitRetry(t *testing.T) {
+	var times []time.Time
+	server, _ := fakeServer(t, rateLimitedHandler(map[string]string{"retry-after-ms": "100"}, &times))
+	c := New(Config{APIKey: "key", BaseURL: server.URL})
+
+	transcription, _, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "Monday, January 1, 2024\nDear diary" || len(attempts) != 2 {
+		t.Errorf("Expected the transcription after 2 attempts, got %q after %d", transcription.Text, len(attempts))
+	}
+	if waited := times[1].Sub(times[0]); waited < 90*time.Millisecond {
+		t.Errorf("Expected the retry to wait for the Retry-After delay, waited %s", waited)
+	}
+}
+
+func TestClient_OCRImage_RateLimitCancel(t *testing.T) {
+	var times []time.Time
+	server, _ := fakeServer(t, rateLimitedHandler(map[string]string{"Retry-After": "60"}, &times))
+	c := New(Config{APIKey: "key", BaseURL: server.URL, PlainText: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, _, _, err := c.OCRImage(ctx, []byte("image"), testPage); err != context.DeadlineExceeded {
+		t.Errorf("Expected the pause to end with the context, got: %v", err)
+	}
+	if len(times) != 1 {
+		t.Errorf("Expected no request during the pause, got %d requests", len(times))
+	}
+}
+
+func TestRateLimiter_Limits(t *testing.T) {
+	now := time.Now()
+
+	t.Run("requests per minute", func(t *testing.T) {
+		l := newRateLimiter(2, 0)
+		if l.reserve(1000, now) != 0 || l.reserve(1000, now) != 0 {
+			t.Fatal("Expected the first 2 requests to be sent right away")
+		}
+		if delay := l.reserve(1000, now); delay != 30*time.Second {
+			t.Errorf("Expected the third request to wait 30s, got %s", delay)
+		}
+		if delay := l.reserve(1000, now.Add(30*time.Second)); delay != 0 {
+			t.Errorf("Expected a request to be available after 30s, got %s", delay)
+		}
+	})
+
+	t.Run("tokens per minute", func(t *testing.T) {
+		l := newRateLimiter(0, 1000)
+		if l.reserve(600, now) != 0 {
+			t.Fatal("Expected the first request to be sent right away")
+		}
+		if delay := l.reserve(600, now); delay != 12*time.Second {
+			t.Errorf("Expected the second request to wait 12s, got %s", delay)
+		}
+		// A request over the limit waits for the whole minute instead of forever
+		if delay := l.reserve(5000, now); delay != 36*time.Second {
+			t.Errorf("Expected a request over the limit to wait 36s, got %s", delay)
+		}
+	})
+
+	t.Run("no limits", func(t *testing.T) {
+		l := newRateLimiter(0, 0)
+		for range 100 {
+			if delay := l.reserve(100000, now); delay != 0 {
+				t.Fatalf("Expected no delay without limits, got %s", delay)
+			}
+		}
+	})
+}
+
+func TestRateLimiter_Observe(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{name: "success", status: http.StatusOK, header: http.Header{}, want: 0},
+		{name: "429 without headers", status: http.StatusTooManyRequests, header: http.Header{}, want: DefaultRateLimitPause},
+		{name: "retry-after date", status: http.StatusServiceUnavailable, header: http.Header{"Retry-After": {now.Add(5 * time.Second).Format(http.TimeFormat)}}, want: 5 * time.Second},
+		{name: "requests remaining", status: http.StatusOK, header: http.Header{"X-Ratelimit-Remaining-Requests": {"3"}, "X-Ratelimit-Reset-Requests": {"6m0s"}}, want: 0},
+		{name: "anthropic reset", status: http.StatusOK, header: http.Header{"Anthropic-Ratelimit-Tokens-Remaining": {"0"}, "Anthropic-Ratelimit-Tokens-Reset": {now.Add(20 * time.Second).Format(time.RFC3339)}}, want: 20 * time.Second},
+		{name: "longest pause wins", status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"2"}, "X-Ratelimit-Remaining-Tokens": {"0"}, "X-Ratelimit-Reset-Tokens": {"7s"}}, want: 7 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(0, 0)
+			l.observe(tt.status, tt.header, now)
+			if delay := l.reserve(0, now); delay != tt.want {
+				t.Errorf("Expected a pause of %s, got %s", tt.want, delay)
+			}
+		})
+	}
+}
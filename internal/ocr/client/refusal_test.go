@@ -1,7 +1,19 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestIsRefusalResponse(t *testing.T) {
@@ -57,13 +69,128 @@ func TestIsRefusalResponse(t *testing.T) {
 		},
 	}
 
-	c := New("test-key")
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := c.isRefusalResponse(tt.text)
+			result := isRefusalResponse(tt.text)
 			if result != tt.expected {
 				t.Errorf("isRefusalResponse(%q) = %v, want %v", tt.text, result, tt.expected)
 			}
 		})
 	}
 }
+
+// refusingHandler refuses the first chat completions and answers the ones after them with a numbered transcription.
+// It records the request bodies.
+func refusingHandler(t *testing.T, refusals int, bodies *[]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		*bodies = append(*bodies, body)
+
+		message := map[string]string{"role": "assistant", "refusal": "I'm sorry, I can't help with that."}
+		if n := len(*bodies) - refusals; n > 0 {
+			content, _ := json.Marshal(structuredResponse{Transcription: fmt.Sprintf("part %d", n), PageNumber: fmt.Sprint(n), IllegibleSegments: []string{}})
+			message = map[string]string{"role": "assistant", "content": string(content)}
+		}
+		resp, _ := json.Marshal(map[string]any{
+			"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 1000, "completion_tokens": 100, "total_tokens": 1100},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}
+}
+
+// systemMessage returns the system prompt of a chat completion request body
+func systemMessage(body map[string]any) string {
+	messages, _ := body["messages"].([]any)
+	if len(messages) == 0 {
+		return ""
+	}
+	message, _ := messages[0].(map[string]any)
+	content, _ := message["content"].(string)
+	return content
+}
+
+func TestClient_OCRImage_RefusalStrategies(t *testing.T) {
+	var page bytes.Buffer
+	if err := png.Encode(&page, image.NewGray(image.Rect(0, 0, 60, 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	var bodies []map[string]any
+	server, _ := fakeServer(t, refusingHandler(t, 3, &bodies))
+	c := New(Config{
+		APIKey:  "key",
+		BaseURL: server.URL,
+		Retry:   RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond},
+		Refusal: RefusalPolicy{Strategies: DefaultRefusalStrategies, FallbackModel: "gpt-4.1"},
+	})
+
+	transcription, _, attempts, err := c.OCRImage(context.Background(), page.Bytes(), testPage)
+	if err != nil {
+		t.Fatalf("Expected the split image to be transcribed, got: %v", err)
+	}
+	if transcription.Text != "part 1\npart 2" || transcription.PageNumber != "1" {
+		t.Errorf("Expected the transcriptions of both halves, got: %+v", transcription)
+	}
+
+	// Each refusal escalates the request with the next strategy
+	var strategies, refusals []string
+	for _, attempt := range attempts {
+		strategies = append(strategies, attempt.Strategy)
+		refusals = append(refusals, attempt.Refusal)
+	}
+	if want := []string{"", "context", "model", "split"}; !reflect.DeepEqual(strategies, want) {
+		t.Errorf("Expected the strategies %q, got %q", want, strategies)
+	}
+	if want := "I'm sorry, I can't help with that."; refusals[0] != want || refusals[2] != want || refusals[3] != "" {
+		t.Errorf("Expected the refusal text of the refused attempts, got %q", refusals)
+	}
+
+	if len(bodies) != 5 {
+		t.Fatalf("Expected 3 refused requests and 2 for the halves, got %d", len(bodies))
+	}
+	if strings.Contains(systemMessage(bodies[0]), refusalContext) || !strings.Contains(systemMessage(bodies[1]), refusalContext) {
+		t.Error("Expected the context to be added to the system prompt after the first refusal")
+	}
+	var models []any
+	for _, body := range bodies {
+		models = append(models, body["model"])
+	}
+	if want := []any{"gpt-4o", "gpt-4o", "gpt-4.1", "gpt-4.1", "gpt-4.1"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("Expected the fallback model after the second refusal, got %v", models)
+	}
+}
+
+func TestClient_OCRImage_RefusalSkippedStrategies(t *testing.T) {
+	// Neither a fallback model nor an image that can be decoded, so only the context is added
+	var bodies []map[string]any
+	server, _ := fakeServer(t, refusingHandler(t, 10, &bodies))
+	c := New(Config{APIKey: "key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}})
+
+	_, _, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if !errors.Is(err, ErrMaxRetriesExceeded) || !strings.Contains(err.Error(), "I'm sorry") {
+		t.Errorf("Expected max retries exceeded after refusals, got: %v", err)
+	}
+	if len(attempts) != 3 || attempts[1].Strategy != "context" || attempts[2].Strategy != "retry" {
+		t.Errorf("Expected a context attempt followed by a retry, got %+v", attempts)
+	}
+}
+
+func TestClient_OCRImage_StructuredRefusalWording(t *testing.T) {
+	// The wording of a page is not mistaken for a refusal when the response has a refusal field
+	var bodies []map[string]any
+	server, _ := fakeServer(t, messageHandler(t, map[string]string{
+		"content": `{"transcription": "I'm sorry, I can't help myself.", "date": "", "page_number": "", "illegible_segments": []}`,
+	}, &bodies))
+	c := New(Config{APIKey: "key", BaseURL: server.URL})
+
+	transcription, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil || transcription.Text != "I'm sorry, I can't help myself." {
+		t.Errorf("Expected the page to be transcribed, got %q and: %v", transcription.Text, err)
+	}
+}
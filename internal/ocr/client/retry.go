@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+var (
+	// DefaultRetryBaseDelay is the longest wait before the first retry when none is configured
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay is the longest wait before any retry when none is configured
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+// ErrImageTimeout is returned when the attempts of an image run past the image timeout of the retry policy
+var ErrImageTimeout = fmt.Errorf("image timeout exceeded")
+
+// RetryPolicy decides which failed OCR requests are sent again, and how long to wait before each retry.
+// The wait grows exponentially with full jitter: a random delay between 0 and BaseDelay * 2^(retry-1), capped at MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the most requests sent for an image (default: DefaultMaxRetyAttempts)
+	MaxAttempts int
+	// BaseDelay is the longest wait before the first retry (default: DefaultRetryBaseDelay)
+	BaseDelay time.Duration
+	// MaxDelay is the longest wait before any retry (default: DefaultRetryMaxDelay)
+	MaxDelay time.Duration
+	// ImageTimeout is the deadline of all attempts of an image together, including the waits, or 0 for no deadline
+	ImageTimeout time.Duration
+	// Retryable reports whether a failed attempt may succeed when it is sent again
+	// (default: the classification of the provider, which falls back to IsRetryable)
+	Retryable func(err error) bool
+}
+
+// withDefaults returns the policy with its zero values replaced by the defaults
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultMaxRetyAttempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryBaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryMaxDelay
+	}
+	if p.Retryable == nil {
+		p.Retryable = IsRetryable
+	}
+	return p
+}
+
+// delay returns a random wait before the given retry, starting at 1 for the second attempt
+func (p RetryPolicy) delay(retry int) time.Duration {
+	ceiling := p.MaxDelay
+	// Shifting by more than 62 bits would overflow, and the delay is capped long before that
+	if shift := retry - 1; shift < 62 && p.BaseDelay < p.MaxDelay>>shift {
+		ceiling = p.BaseDelay << shift
+	}
+	return rand.N(ceiling + 1)
+}
+
+// IsRetryable classifies the errors of OCR requests. Rate limits (429), timeouts (408), conflicts (409),
+// server errors, network errors, refusals and invalid responses are worth another attempt.
+// Other client errors, such as a bad request (400), an invalid key (401), a forbidden model (403)
+// or an unknown model (404), fail the same way every time, and cancelled requests are not retried either.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case apiErr.Status == http.StatusRequestTimeout, apiErr.Status == http.StatusConflict, apiErr.Status == http.StatusTooManyRequests:
+			return true
+		case apiErr.Status >= 500:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// isOpenAIRetryable classifies OpenAI errors. An exhausted quota is reported as a rate limit (429),
+// but it fails the same way until the account is topped up.
+func isOpenAIRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Type == "insufficient_quota" {
+		return false
+	}
+	return IsRetryable(err)
+}
+
+// isAnthropicRetryable classifies Anthropic errors by their type, which holds even when a proxy changes the status.
+// Overloaded (529), rate limit, timeout and internal errors are retried, invalid requests, authentication,
+// permission, not found, billing and too large errors are not.
+func isAnthropicRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case "overloaded_error", "rate_limit_error", "timeout_error", "api_error":
+			return true
+		case "invalid_request_error", "authentication_error", "permission_error", "not_found_error", "billing_error", "request_too_large":
+			return false
+		}
+	}
+	return IsRetryable(err)
+}
+
+// isGeminiRetryable classifies Gemini errors by their status, which holds even when a proxy changes the HTTP status.
+// Exhausted resources (rate limits), unavailable or internal servers, deadlines and aborted requests are retried,
+// invalid arguments, failed preconditions such as an unsupported region, denied permissions and unknown models are not.
+func isGeminiRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Type {
+		case "RESOURCE_EXHAUSTED", "UNAVAILABLE", "INTERNAL", "DEADLINE_EXCEEDED", "ABORTED":
+			return true
+		case "INVALID_ARGUMENT", "FAILED_PRECONDITION", "PERMISSION_DENIED", "UNAUTHENTICATED", "NOT_FOUND":
+			return false
+		}
+	}
+	return IsRetryable(err)
+}
+
+// retryOCR sends req with once until it succeeds or the retry policy gives up, and returns the total cost and the history of all attempts.
+// Each attempt waits for the rate limiter to allow a request of the given number of tokens,
+// and each refusal changes the request with the next strategy of the refusal policy.
+func retryOCR(ctx context.Context, limiter *rateLimiter, tokens int, policy RetryPolicy, refusals RefusalPolicy, req ocrRequest, once func(ctx context.Context, req ocrRequest) (ocr.Transcription, float64, error)) (transcription ocr.Transcription, totalCost float64, attempts []ocr.Attempt, err error) {
+	policy = policy.withDefaults()
+	refusals = refusals.withDefaults()
+
+	imageCtx := ctx
+	if policy.ImageTimeout > 0 {
+		var cancel context.CancelFunc
+		imageCtx, cancel = context.WithTimeout(ctx, policy.ImageTimeout)
+		defer cancel()
+	}
+
+	// stopped returns the error of a run that ended because its context is done
+	var lastErr error
+	var strategy RefusalStrategy
+	var nextStrategy int
+	stopped := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if lastErr != nil {
+			return fmt.Errorf("%w: %v", ErrImageTimeout, lastErr)
+		}
+		return ErrImageTimeout
+	}
+
+	for len(attempts) < policy.MaxAttempts {
+		waitStart := time.Now()
+		if len(attempts) > 0 {
+			select {
+			case <-imageCtx.Done():
+				return ocr.Transcription{}, totalCost, attempts, stopped()
+			case <-time.After(policy.delay(len(attempts))):
+			}
+		}
+		if err := limiter.wait(imageCtx, tokens); err != nil {
+			return ocr.Transcription{}, totalCost, attempts, stopped()
+		}
+
+		sent := time.Now()
+		transcription, cost, err := req.send(imageCtx, limiter, tokens, once)
+		totalCost += cost
+		attempt := ocr.Attempt{
+			Delay:    sent.Sub(waitStart),
+			Duration: time.Since(sent),
+			Cost:     cost,
+			Error:    err,
+			Strategy: string(strategy),
+
+			PromptHash: req.promptHash(),
+		}
+		var refusal *RefusalError
+		if errors.As(err, &refusal) {
+			attempt.Refusal = refusal.Text
+		}
+		attempts = append(attempts, attempt)
+		if err == nil {
+			return transcription, totalCost, attempts, nil
+		}
+
+		if imageCtx.Err() != nil {
+			lastErr = err
+			return ocr.Transcription{}, totalCost, attempts, stopped()
+		}
+		if !policy.Retryable(err) {
+			return ocr.Transcription{}, totalCost, attempts, err
+		}
+		lastErr = err
+
+		strategy = ""
+		if errors.Is(err, ErrRefusalResponse) {
+			req, strategy, nextStrategy = refusals.escalate(req, nextStrategy)
+		}
+	}
+
+	return ocr.Transcription{}, totalCost, attempts, fmt.Errorf("%w: %v", ErrMaxRetriesExceeded, lastErr)
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: &APIError{Status: http.StatusTooManyRequests}, want: true},
+		{err: &APIError{Status: http.StatusInternalServerError}, want: true},
+		{err: &APIError{Status: 529}, want: true},
+		{err: &APIError{Status: http.StatusRequestTimeout}, want: true},
+		{err: fmt.Errorf("%w: connection reset by peer", ErrAPIRequestFailed), want: true},
+		{err: fmt.Errorf("%w: I'm sorry", ErrRefusalResponse), want: true},
+		{err: fmt.Errorf("%w: unexpected end of JSON input", ErrInvalidResponse), want: true},
+		{err: &APIError{Status: http.StatusBadRequest}, want: false},
+		{err: &APIError{Status: http.StatusUnauthorized}, want: false},
+		{err: &APIError{Status: http.StatusForbidden}, want: false},
+		{err: &APIError{Status: http.StatusNotFound}, want: false},
+		{err: context.Canceled, want: false},
+		{err: fmt.Errorf("request: %w", context.DeadlineExceeded), want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestProviderRetryable(t *testing.T) {
+	tests := []struct {
+		name      string
+		retryable func(error) bool
+		err       error
+		want      bool
+	}{
+		{name: "openai rate limit", retryable: isOpenAIRetryable, err: &APIError{Status: http.StatusTooManyRequests, Type: "rate_limit_exceeded"}, want: true},
+		{name: "openai insufficient quota", retryable: isOpenAIRetryable, err: &APIError{Status: http.StatusTooManyRequests, Type: "insufficient_quota"}, want: false},
+		{name: "anthropic overloaded", retryable: isAnthropicRetryable, err: &APIError{Status: 529, Type: "overloaded_error"}, want: true},
+		{name: "anthropic overloaded behind a proxy", retryable: isAnthropicRetryable, err: &APIError{Status: http.StatusBadGateway, Type: "overloaded_error"}, want: true},
+		{name: "anthropic too large", retryable: isAnthropicRetryable, err: &APIError{Status: http.StatusRequestEntityTooLarge, Type: "request_too_large"}, want: false},
+		{name: "anthropic without a type", retryable: isAnthropicRetryable, err: &APIError{Status: http.StatusServiceUnavailable}, want: true},
+		{name: "gemini resource exhausted", retryable: isGeminiRetryable, err: &APIError{Status: http.StatusTooManyRequests, Type: "RESOURCE_EXHAUSTED"}, want: true},
+		{name: "gemini failed precondition", retryable: isGeminiRetryable, err: &APIError{Status: http.StatusBadRequest, Type: "FAILED_PRECONDITION"}, want: false},
+		{name: "gemini internal", retryable: isGeminiRetryable, err: &APIError{Status: http.StatusInternalServerError, Type: "INTERNAL"}, want: true},
+		{name: "gemini cancelled", retryable: isGeminiRetryable, err: context.Canceled, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.retryable(tt.err); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}.withDefaults()
+
+	ceilings := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second, 100: time.Second}
+	for retry, ceiling := range ceilings {
+		for range 100 {
+			if delay := policy.delay(retry); delay < 0 || delay > ceiling {
+				t.Fatalf("Expected the delay of retry %d to be between 0 and %s, got %s", retry, ceiling, delay)
+			}
+		}
+	}
+}
+
+func TestRetryOCR(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	limiter := newRateLimiter(0, 0)
+	refusals := RefusalPolicy{Strategies: []RefusalStrategy{RefusalStrategyRetry}}
+	req := ocrRequest{imageData: []byte("image"), model: DefaultModel, systemPrompt: "system", userPrompt: "user"}
+
+	// fails returns a once func that fails with the given errors before it succeeds
+	fails := func(errs ...error) func(context.Context, ocrRequest) (ocr.Transcription, float64, error) {
+		return func(context.Context, ocrRequest) (ocr.Transcription, float64, error) {
+			if len(errs) == 0 {
+				return ocr.Transcription{Text: "Dear diary"}, 0.01, nil
+			}
+			err := errs[0]
+			errs = errs[1:]
+			return ocr.Transcription{}, 0.01, err
+		}
+	}
+
+	t.Run("history of retried attempts", func(t *testing.T) {
+		transcription, cost, attempts, err := retryOCR(context.Background(), limiter, 0, policy, refusals, req, fails(&APIError{Status: 503}, ErrRefusalResponse))
+		if err != nil || transcription.Text != "Dear diary" {
+			t.Fatalf("Expected the third attempt to succeed, got: %v", err)
+		}
+		if len(attempts) != 3 || cost < 0.0299 {
+			t.Fatalf("Expected 3 attempts costing $0.03, got %d costing %f", len(attempts), cost)
+		}
+		if !isServiceUnavailable(attempts[0].Error) || !errors.Is(attempts[1].Error, ErrRefusalResponse) || attempts[2].Error != nil {
+			t.Errorf("Expected the errors of each attempt, got: %v, %v, %v", attempts[0].Error, attempts[1].Error, attempts[2].Error)
+		}
+	})
+
+	t.Run("fatal errors are not retried", func(t *testing.T) {
+		_, _, attempts, err := retryOCR(context.Background(), limiter, 0, policy, refusals, req, fails(&APIError{Status: http.StatusBadRequest}))
+		if len(attempts) != 1 {
+			t.Errorf("Expected a single attempt, got %d", len(attempts))
+		}
+		if _, ok := err.(*APIError); !ok {
+			t.Errorf("Expected the API error, got: %v", err)
+		}
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		_, _, attempts, err := retryOCR(context.Background(), limiter, 0, policy, refusals, req, fails(ErrRefusalResponse, ErrRefusalResponse, ErrRefusalResponse))
+		if !errors.Is(err, ErrMaxRetriesExceeded) || len(attempts) != 3 {
+			t.Errorf("Expected max retries exceeded after 3 attempts, got %d and: %v", len(attempts), err)
+		}
+	})
+
+	t.Run("image timeout", func(t *testing.T) {
+		slow := RetryPolicy{MaxAttempts: 10, BaseDelay: 50 * time.Millisecond, MaxDelay: 50 * time.Millisecond, ImageTimeout: 30 * time.Millisecond,
+			Retryable: func(error) bool { return true }}
+		_, _, attempts, err := retryOCR(context.Background(), limiter, 0, slow, refusals, req, func(ctx context.Context, _ ocrRequest) (ocr.Transcription, float64, error) {
+			<-ctx.Done()
+			return ocr.Transcription{}, 0, fmt.Errorf("%w: %v", ErrAPIRequestFailed, ctx.Err())
+		})
+		if !errors.Is(err, ErrImageTimeout) || len(attempts) != 1 {
+			t.Errorf("Expected the image timeout after 1 attempt, got %d and: %v", len(attempts), err)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, _, attempts, err := retryOCR(ctx, limiter, 0, policy, refusals, req, func(ctx context.Context, _ ocrRequest) (ocr.Transcription, float64, error) {
+			return ocr.Transcription{}, 0, fmt.Errorf("%w: %v", ErrAPIRequestFailed, ctx.Err())
+		})
+		if !errors.Is(err, context.Canceled) || len(attempts) != 1 {
+			t.Errorf("Expected the cancellation after 1 attempt, got %d and: %v", len(attempts), err)
+		}
+	})
+}
+
+// isServiceUnavailable reports whether err is a 503 API error
+func isServiceUnavailable(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.Status == http.StatusServiceUnavailable
+}
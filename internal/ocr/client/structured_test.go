@@ -0,0 +1,205 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/pricing"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
+)
+
+// messageHandler answers chat completions with the given assistant message and records the request bodies
+func messageHandler(t *testing.T, message map[string]string, bodies *[]map[string]any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		*bodies = append(*bodies, body)
+
+		message["role"] = "assistant"
+		resp, _ := json.Marshal(map[string]any{
+			"id":      "chatcmpl-1",
+			"object":  "chat.completion",
+			"model":   "gpt-4o",
+			"choices": []any{map[string]any{"index": 0, "message": message, "finish_reason": "stop"}},
+			"usage":   map[string]int{"prompt_tokens": 1000, "completion_tokens": 100, "total_tokens": 1100},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(resp)
+	}
+}
+
+func TestClient_OCRImage_Structured(t *testing.T) {
+	var bodies []map[string]any
+	server, _ := fakeServer(t, messageHandler(t, map[string]string{
+		"content": `{"transcription": "3. März 2024\nLiebes Tagebuch, [?]", "date": "3. März 2024", "page_number": "12", "illegible_segments": ["[?]"]}`,
+	}, &bodies))
+	c := New(Config{APIKey: "key", BaseURL: server.URL})
+
+	transcription, _, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "3. März 2024\nLiebes Tagebuch, [?]" {
+		t.Errorf("Unexpected text: %q", transcription.Text)
+	}
+	if transcription.Date != "3. März 2024" {
+		t.Errorf("Unexpected date: %q", transcription.Date)
+	}
+	if transcription.PageNumber != "12" {
+		t.Errorf("Unexpected page number: %q", transcription.PageNumber)
+	}
+	if !reflect.DeepEqual(transcription.IllegibleSegments, []string{"[?]"}) {
+		t.Errorf("Unexpected illegible segments: %v", transcription.IllegibleSegments)
+	}
+
+	// The request asks for a strict JSON schema response
+	format, _ := bodies[0]["response_format"].(map[string]any)
+	schema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || schema["name"] != transcriptionSchemaName || schema["strict"] != true {
+		t.Errorf("Unexpected response format: %v", bodies[0]["response_format"])
+	}
+}
+
+func TestClient_OCRImage_PlainText(t *testing.T) {
+	var bodies []map[string]any
+	server, _ := fakeServer(t, messageHandler(t, map[string]string{
+		"content": "Monday, January 1, 2024\nDear diary",
+	}, &bodies))
+	c := New(Config{APIKey: "key", BaseURL: server.URL, PlainText: true})
+
+	transcription, _, _, err := c.OCRImage(context.Background(), []byte("\x89PNG\r\n\x1a\n"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if transcription.Text != "Monday, January 1, 2024\nDear diary" || transcription.Date != "" {
+		t.Errorf("Unexpected transcription: %+v", transcription)
+	}
+	if _, ok := bodies[0]["response_format"]; ok {
+		t.Errorf("Expected no response format, got: %v", bodies[0]["response_format"])
+	}
+
+	// The data URL names the type of the image that is sent
+	content := bodies[0]["messages"].([]any)[1].(map[string]any)["content"].([]any)
+	url := content[1].(map[string]any)["image_url"].(map[string]any)["url"].(string)
+	if !strings.HasPrefix(url, "data:image/png;base64,") {
+		t.Errorf("Expected a PNG data URL, got: %s", url)
+	}
+}
+
+func TestClient_OCRImage_StructuredErrors(t *testing.T) {
+	t.Run("refusal", func(t *testing.T) {
+		var bodies []map[string]any
+		server, _ := fakeServer(t, messageHandler(t, map[string]string{
+			"content": "",
+			"refusal": "I'm sorry, I can't help with that.",
+		}, &bodies))
+		c := New(Config{APIKey: "key", BaseURL: server.URL, Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}})
+
+		_, cost, attempts, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+		if !errors.Is(err, ErrMaxRetriesExceeded) || len(attempts) != 2 {
+			t.Errorf("Expected max retries exceeded after 2 attempts, got %d attempts and: %v", len(attempts), err)
+		}
+		if cost <= 0 {
+			t.Errorf("Expected refused attempts to be charged, got: %f", cost)
+		}
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		var bodies []map[string]any
+		server, _ := fakeServer(t, messageHandler(t, map[string]string{
+			"content": "Monday, January 1, 2024\nDear diary",
+		}, &bodies))
+		c := New(Config{APIKey: "key", BaseURL: server.URL})
+
+		_, _, err := c.ocrImageOnce(context.Background(), ocrRequest{imageData: []byte("image"), model: c.config.Model, systemPrompt: "system", userPrompt: "user"})
+		if !errors.Is(err, ErrInvalidResponse) {
+			t.Errorf("Expected ErrInvalidResponse, got: %v", err)
+		}
+	})
+}
+
+func TestClient_OCRImage_Cost(t *testing.T) {
+	server, _ := fakeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{
+			"choices": [{"index": 0, "message": {"role": "assistant", "content": "Dear diary"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 1000000, "completion_tokens": 100000, "prompt_tokens_details": {"cached_tokens": 400000}}
+		}`))
+	})
+	prices := pricing.Table{"journal-model": {Input: 2, CachedInput: 1, Output: 10}}
+	c := New(Config{APIKey: "key", BaseURL: server.URL, Model: "journal-model", PlainText: true, Prices: prices})
+
+	// 600K uncached input tokens at $2, 400K cached input tokens at $1 and 100K output tokens at $10 per million
+	_, cost, _, err := c.OCRImage(context.Background(), []byte("image"), testPage)
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+	if cost < 2.5999 || cost > 2.6001 {
+		t.Errorf("Expected a cost of $2.60, got: %f", cost)
+	}
+}
+
+func TestClient_OCRImage_Prompt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "letters.tmpl")
+	template := `{{define "system"}}You transcribe letters written in {{.Language}}.{{end}}{{define "structured"}}Transcribe page {{.PageIndex}}, {{.ImageName}}.{{end}}`
+	if err := os.WriteFile(path, []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+	letters, err := prompt.Load(path, prompt.Default())
+	if err != nil {
+		t.Fatalf("Expected the template to load, got: %v", err)
+	}
+
+	var bodies []map[string]any
+	server, _ := fakeServer(t, messageHandler(t, map[string]string{
+		"content": `{"transcription": "Chère Marie", "date": "", "page_number": "", "illegible_segments": []}`,
+	}, &bodies))
+	c := New(Config{APIKey: "key", BaseURL: server.URL, Prompt: letters, Language: "French"})
+
+	_, _, attempts, err := c.OCRImage(context.Background(), []byte("image"), ocr.PageInfo{Name: "letter-03.jpg", Index: 3})
+	if err != nil {
+		t.Fatalf("Expected OCR to succeed, got: %v", err)
+	}
+
+	messages := bodies[0]["messages"].([]any)
+	system := messages[0].(map[string]any)["content"]
+	user := messages[1].(map[string]any)["content"].([]any)[0].(map[string]any)["text"]
+	if system != "You transcribe letters written in French." || user != "Transcribe page 3, letter-03.jpg." {
+		t.Errorf("Expected the rendered prompts, got %q and %q", system, user)
+	}
+	if len(attempts) != 1 || attempts[0].PromptHash == "" {
+		t.Errorf("Expected the prompt hash in the attempt, got: %+v", attempts)
+	}
+	if New(Config{Prompt: letters}).Fingerprint(testPage) == New(Config{}).Fingerprint(testPage) {
+		t.Error("Expected the prompt template to change the fingerprint")
+	}
+	// The template renders the page index, so the same image at another position is sent with another prompt
+	page := ocr.PageInfo{Name: "letter-03.jpg", Index: 3}
+	if c.Fingerprint(page) == c.Fingerprint(ocr.PageInfo{Name: "letter-03.jpg", Index: 4}) {
+		t.Error("Expected the rendered prompt to change the fingerprint")
+	}
+	if c.Fingerprint(page) != c.Fingerprint(page) {
+		t.Error("Expected the same page to have the same fingerprint")
+	}
+}
+
+func TestClient_Fingerprint(t *testing.T) {
+	structured := New(Config{})
+	plain := New(Config{PlainText: true})
+	if structured.Fingerprint(testPage) == plain.Fingerprint(testPage) {
+		t.Error("Expected structured and plain text requests to have different fingerprints")
+	}
+}
@@ -2,15 +2,26 @@ package command
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 
 	"github.com/charmbracelet/log"
 	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/checkpoint"
 	"github.com/marksalpeter/ocr/internal/ocr/client"
+	"github.com/marksalpeter/ocr/internal/ocr/formatter"
+	"github.com/marksalpeter/ocr/internal/ocr/preprocess"
+	"github.com/marksalpeter/ocr/internal/ocr/pricing"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
 	"github.com/marksalpeter/ocr/internal/ocr/repository"
 	"github.com/marksalpeter/ocr/internal/ocr/resizer"
 )
 
+// EstimateCommand is the subcommand that predicts the tokens and cost of a run without sending any request
+const EstimateCommand = "estimate"
+
 // Command represents the command adapter that orchestrates the OCR workflow
 type Command struct {
 	configCollector *configCollector
@@ -31,35 +42,139 @@ func New() *Command {
 	}
 }
 
-// Run executes the OCR workflow: collects configuration, processes images, and displays results
-func (c *Command) Run(ctx context.Context) error {
+// Run executes the OCR workflow: collects configuration, processes images, and displays results.
+// When the first argument is the estimate subcommand, the cost of the run is estimated instead.
+func (c *Command) Run(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == EstimateCommand {
+		return c.estimate(ctx, args[1:])
+	}
+
 	// Collect configuration
-	cfg, err := c.configCollector.Collect()
-	if err != nil {
+	cfg, err := c.collectConfig(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err != nil {
 		c.logger.Error("Error collecting configuration", "error", err)
 		return err
 	}
 
 	// Create repository with the input directory and output file from config
-	repo, err := repository.New(cfg.InputDir, cfg.OutputFile)
+	repo, err := repository.New(cfg.InputDir, cfg.OutputFile, cfg.repositoryConfig())
 	if err != nil {
 		c.logger.Error("Error creating repository", "error", err)
 		return err
 	}
 
-	// Create the OCR client with the API key from config
-	ocrClient := client.New(cfg.APIKey)
+	// Load the price table of the model
+	prices, err := c.loadPrices(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Load the prompt templates of the document type
+	ocrPrompt, err := c.loadPrompt(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Create the OCR client of the provider with the API key, model and endpoint settings from config
+	ocrClient, err := client.NewProvider(cfg.Provider, client.Config{
+		APIKey:    cfg.APIKey,
+		Model:     cfg.Model,
+		MaxTokens: cfg.MaxTokens,
+		Prompt:    ocrPrompt,
+		Language:  cfg.Language,
+		Retry: client.RetryPolicy{
+			MaxAttempts:  cfg.MaxRetries,
+			BaseDelay:    cfg.RetryBaseDelay,
+			MaxDelay:     cfg.RetryMaxDelay,
+			ImageTimeout: cfg.ImageTimeout,
+		},
+		Refusal: client.RefusalPolicy{
+			Strategies:    cfg.RefusalStrategies,
+			FallbackModel: cfg.FallbackModel,
+		},
+		BaseURL:         cfg.BaseURL,
+		APIType:         cfg.APIType,
+		APIVersion:      cfg.APIVersion,
+		AzureDeployment: cfg.AzureDeployment,
+		AuthHeader:      cfg.AuthHeader,
+		PlainText:       cfg.PlainText,
+		Prices:          prices,
+
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		TokensPerMinute:   cfg.TokensPerMinute,
+	})
+	if err != nil {
+		c.logger.Error("Error creating OCR client", "error", err)
+		return err
+	}
 
 	// Create resizer instance
-	imgResizer := resizer.New()
+	imgResizer := resizer.New(resizer.Config{
+		DetectRotation:     cfg.DetectRotation,
+		CorrectPerspective: cfg.CorrectPerspective,
+		Tiles:              cfg.Tiles,
+	})
+
+	// Create the checkpoint store next to the output file, starting over if requested
+	checkpoints := checkpoint.New(repo.OutputPath() + checkpoint.FileSuffix)
+	if cfg.Fresh {
+		if err := checkpoints.Reset(); err != nil {
+			c.logger.Error("Error resetting checkpoints", "error", err)
+			return err
+		}
+	}
+
+	// Select the output formatter, the app uses the text layout when none is given
+	var outputFormatter ocr.OutputFormatter
+	switch {
+	case cfg.Template != "":
+		tmpl, err := formatter.NewTemplate(cfg.Template)
+		if err != nil {
+			c.logger.Error("Error loading template", "error", err)
+			return err
+		}
+		outputFormatter = tmpl
+	case cfg.Format == FormatJSON:
+		outputFormatter = formatter.JSON{}
+	case cfg.Format == FormatJSONL:
+		outputFormatter = formatter.JSONL{}
+	}
+
+	// Create the date parser with the locales, order and format from config
+	dateParser, err := ocr.NewDateParser(ocr.DateParserConfig{
+		Locales: cfg.DateLocales,
+		Order:   cfg.DateOrder,
+		Layout:  cfg.DateLayout(),
+	})
+	if err != nil {
+		c.logger.Error("Error creating date parser", "error", err)
+		return err
+	}
+
+	// Create the preprocessing pipeline of the steps from config
+	preprocessor, err := c.newPreprocessor(cfg)
+	if err != nil {
+		return err
+	}
 
 	// Start the loading spinner
 	c.spinner.Start("Processing images...")
 
 	// Create application instance (spinner implements ProgressUpdater)
-	app := ocr.NewApp(ocrClient, repo, imgResizer, c.spinner, &ocr.AppConfig{
-		Concurrency: cfg.Concurrency,
-		StartDate:   cfg.StartDate,
+	app := ocr.NewApp(ocrClient, repo, imgResizer, c.spinner, checkpoints, outputFormatter, &ocr.AppConfig{
+		Concurrency:       cfg.Concurrency,
+		StartDate:         cfg.StartDate,
+		MaxImageDimension: cfg.MaxImageDimension,
+		DateParser:        dateParser,
+		SplitByDate:       cfg.SplitByDate,
+		SplitByDirectory:  cfg.SplitByDirectory,
+		MaxCost:           cfg.MaxCost,
+		PromptPreset:      ocrPrompt.Name(),
+		Preprocessor:      preprocessor,
+		Tiler:             newTiler(cfg, imgResizer),
+		Splitter:          newSplitter(cfg, imgResizer),
 	})
 
 	// Process images
@@ -74,7 +189,161 @@ func (c *Command) Run(ctx context.Context) error {
 	c.spinner.Stop()
 
 	// Display results
+	if results.BudgetExceeded {
+		c.logger.Warn("The cost budget was reached, run again with a higher --max-cost to process the remaining images", "max-cost", cfg.MaxCost, "skipped", results.TotalImagesSkipped)
+	}
 	c.logger.Info("✅ Processing completed", "results", results)
 
 	return nil
 }
+
+// estimate predicts the image tokens and cost of processing the images in the input directory.
+// It works offline: the API key is neither required nor validated, and nothing is sent to the OCR client.
+func (c *Command) estimate(ctx context.Context, args []string) error {
+	cfg, err := loadConfig(args, os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	} else if err == nil {
+		err = cfg.validate(false)
+	}
+	if err != nil {
+		c.logger.Error("Error collecting configuration", "error", err)
+		return err
+	}
+
+	repo, err := repository.New(cfg.InputDir, cfg.OutputFile, cfg.repositoryConfig())
+	if err != nil {
+		c.logger.Error("Error creating repository", "error", err)
+		return err
+	}
+
+	prices, err := c.loadPrices(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Preprocessing can crop the images, so it is part of the estimate. It writes no debug images,
+	// since an estimate only reads the input directory.
+	preprocessCfg := *cfg
+	preprocessCfg.PreprocessDebugDir = ""
+	preprocessor, err := c.newPreprocessor(&preprocessCfg)
+	if err != nil {
+		return err
+	}
+
+	// The app has no OCR client, so no request can be sent. There is no spinner either,
+	// so the estimate can be piped to other tools.
+	imgResizer := resizer.New(resizer.Config{
+		DetectRotation:     cfg.DetectRotation,
+		CorrectPerspective: cfg.CorrectPerspective,
+		Tiles:              cfg.Tiles,
+	})
+	app := ocr.NewApp(nil, repo, imgResizer, nil, nil, nil, &ocr.AppConfig{
+		MaxImageDimension: cfg.MaxImageDimension,
+		Preprocessor:      preprocessor,
+		Tiler:             newTiler(cfg, imgResizer),
+		Splitter:          newSplitter(cfg, imgResizer),
+	})
+	results, err := app.EstimateImages(ctx, pricing.Estimator{
+		Model:     cfg.Model,
+		Prices:    prices,
+		MaxTokens: cfg.MaxTokens,
+	})
+	if err != nil {
+		c.logger.Error("Failed to estimate images", "error", err)
+		return err
+	}
+
+	fmt.Print(results)
+	c.logger.Info("Estimate assumes a single attempt per image", "provider", cfg.Provider, "model", cfg.Model)
+
+	return nil
+}
+
+// loadPrices returns the built-in price table with the prices of the pricing file on top,
+// and warns when the model has no price
+func (c *Command) loadPrices(cfg *Config) (pricing.Table, error) {
+	prices := pricing.Default()
+	if cfg.PricingFile != "" {
+		var err error
+		if prices, err = pricing.Load(cfg.PricingFile); err != nil {
+			c.logger.Error("Error loading pricing file", "error", err)
+			return nil, err
+		}
+	}
+	if _, ok := prices.Lookup(cfg.Model); !ok {
+		c.logger.Warn("The model has no price, its cost will be reported as $0. Add it to a pricing file with --pricing-file.", "model", cfg.Model)
+	}
+	return prices, nil
+}
+
+// loadPrompt returns the prompt preset, with the templates of the prompt template file in place of its own
+func (c *Command) loadPrompt(cfg *Config) (*prompt.Prompt, error) {
+	p, err := prompt.Preset(cfg.PromptPreset)
+	if err == nil && cfg.PromptTemplate != "" {
+		p, err = prompt.Load(cfg.PromptTemplate, p)
+	}
+	if err != nil {
+		c.logger.Error("Error loading prompt", "error", err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// newPreprocessor returns the preprocessing pipeline of the configured steps, or nil when there are none
+func (c *Command) newPreprocessor(cfg *Config) (ocr.Preprocessor, error) {
+	if len(cfg.Preprocess) == 0 {
+		return nil, nil
+	}
+	pipeline, err := preprocess.New(preprocess.Config{
+		Steps:    cfg.Preprocess,
+		DebugDir: cfg.PreprocessDebugDir,
+	})
+	if err != nil {
+		c.logger.Error("Error creating preprocessor", "error", err)
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// newTiler returns the resizer when images are split into bands, or nil when they are sent whole
+func newTiler(cfg *Config, imgResizer *resizer.Resizer) ocr.Tiler {
+	if cfg.Tiles <= 1 {
+		return nil
+	}
+	return imgResizer
+}
+
+// newSplitter returns the resizer when open-book spreads are split into their pages, or nil when they are not
+func newSplitter(cfg *Config, imgResizer *resizer.Resizer) ocr.Splitter {
+	if !cfg.SplitSpreads {
+		return nil
+	}
+	return imgResizer
+}
+
+// collectConfig loads the configuration from flags and environment variables and
+// only prompts for missing values when stdin is an interactive terminal
+func (c *Command) collectConfig(args []string) (*Config, error) {
+	cfg, err := loadConfig(args, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing is missing, so there is no reason to show the form
+	err = cfg.Validate()
+	if err == nil {
+		return cfg, nil
+	}
+
+	// Prompting is disabled or impossible, so fail with the validation error
+	if cfg.NoInput || !isTerminal(os.Stdin) {
+		return nil, err
+	}
+
+	cfg, err = c.configCollector.Collect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return cfg, cfg.Validate()
+}
@@ -4,10 +4,36 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/client"
+	"github.com/marksalpeter/ocr/internal/ocr/preprocess"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
+	"github.com/marksalpeter/ocr/internal/ocr/repository"
 )
 
+// Output formats
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+// Date formats, any other value is used as a Go time layout
+const (
+	DateFormatISO  = "iso"
+	DateFormatLong = "long"
+)
+
+// dateLayouts are the time layouts of the named date formats
+var dateLayouts = map[string]string{
+	DateFormatISO:  ocr.DefaultDateLayout,
+	DateFormatLong: "Monday, January 2, 2006",
+}
+
 // Config contains all configuration parameters
 type Config struct {
 	InputDir    string
@@ -15,6 +41,127 @@ type Config struct {
 	APIKey      string
 	Concurrency int
 	StartDate   string
+	DateLocales []string
+	DateOrder   ocr.DateOrder
+	DateFormat  string
+	Format      string
+	Template    string
+	SplitByDate bool
+	NoInput     bool
+	Fresh       bool
+
+	Include          []string
+	Exclude          []string
+	MaxDepth         int
+	SplitByDirectory bool
+
+	Provider           client.Provider
+	Model              string
+	MaxTokens          int
+	PromptPreset       string
+	PromptTemplate     string
+	Language           string
+	MaxRetries         int
+	RetryBaseDelay     time.Duration
+	RetryMaxDelay      time.Duration
+	ImageTimeout       time.Duration
+	RefusalStrategies  []client.RefusalStrategy
+	FallbackModel      string
+	RequestsPerMinute  int
+	TokensPerMinute    int
+	MaxImageDimension  int
+	DetectRotation     bool
+	CorrectPerspective bool
+	SplitSpreads       bool
+	Tiles              int
+	Preprocess         []preprocess.Step
+	PreprocessDebugDir string
+	MaxCost            float64
+	PricingFile        string
+
+	BaseURL         string
+	APIType         client.APIType
+	APIVersion      string
+	AzureDeployment string
+	AuthHeader      client.AuthHeader
+	PlainText       bool
+
+	ConfigFile string
+	Profile    string
+}
+
+// defaultConfig returns the configuration used when no value is provided
+func defaultConfig() *Config {
+	wd, _ := os.Getwd()
+	return &Config{
+		InputDir:    wd,
+		OutputFile:  "output.txt",
+		Concurrency: 10,
+		DateLocales: []string{ocr.DefaultDateLocale},
+		DateOrder:   ocr.DefaultDateOrder,
+		DateFormat:  DateFormatISO,
+		Format:      FormatText,
+
+		Provider:          client.ProviderOpenAI,
+		MaxTokens:         client.DefaultMaxTokens,
+		PromptPreset:      prompt.DefaultPreset,
+		MaxRetries:        client.DefaultMaxRetyAttempts,
+		RetryBaseDelay:    client.DefaultRetryBaseDelay,
+		RetryMaxDelay:     client.DefaultRetryMaxDelay,
+		RefusalStrategies: client.DefaultRefusalStrategies,
+		MaxImageDimension: ocr.DefaultMaxImageDimension,
+		Tiles:             1,
+	}
+}
+
+// Validate checks that all required values are present and valid
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// validate checks that all required values are present and valid.
+// The API key is only required when requests are sent.
+func (c *Config) validate(requireAPIKey bool) error {
+	var missing []string
+	if c.InputDir == "" {
+		missing = append(missing, "input directory (--input or OCR_INPUT_DIR)")
+	}
+	if c.OutputFile == "" {
+		missing = append(missing, "output file (--output or OCR_OUTPUT_FILE)")
+	}
+	if requireAPIKey && c.APIKey == "" && c.AuthHeader != client.AuthHeaderNone {
+		missing = append(missing, fmt.Sprintf("API key (--api-key or %s)", providerAPIKeyEnvs[c.Provider]))
+	}
+	if c.APIType == client.APITypeAzure && c.BaseURL == "" {
+		missing = append(missing, "Azure OpenAI endpoint (--base-url or OCR_BASE_URL)")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingConfig, strings.Join(missing, ", "))
+	}
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("%w: concurrency must be a positive integer", ErrInvalidInput)
+	}
+	if c.Template != "" && c.Format != FormatText {
+		return fmt.Errorf("%w: a template can not be combined with the %s format", ErrInvalidInput, c.Format)
+	}
+	return nil
+}
+
+// repositoryConfig returns the settings of the repository that lists and loads the images
+func (c *Config) repositoryConfig() repository.Config {
+	return repository.Config{
+		Include:  c.Include,
+		Exclude:  c.Exclude,
+		MaxDepth: c.MaxDepth,
+	}
+}
+
+// DateLayout returns the time layout of the date format
+func (c *Config) DateLayout() string {
+	if layout, ok := dateLayouts[c.DateFormat]; ok {
+		return layout
+	}
+	return c.DateFormat
 }
 
 var (
@@ -22,6 +169,12 @@ var (
 	ErrConfigCancelled = fmt.Errorf("configuration cancelled")
 	// ErrInvalidInput is returned when user input is invalid
 	ErrInvalidInput = fmt.Errorf("invalid input")
+	// ErrMissingConfig is returned when required values are missing and prompting is not possible
+	ErrMissingConfig = fmt.Errorf("missing required configuration")
+	// ErrUnknownProfile is returned when the selected profile is not defined in any config file
+	ErrUnknownProfile = fmt.Errorf("unknown profile")
+	// ErrInvalidConfigFile is returned when a config file cannot be read or parsed
+	ErrInvalidConfigFile = fmt.Errorf("invalid config file")
 )
 
 // configCollector collects configuration using huh
@@ -32,16 +185,11 @@ func newConfigCollector() *configCollector {
 	return &configCollector{}
 }
 
-// Collect gathers configuration parameters from the user
-func (c *configCollector) Collect() (*Config, error) {
+// Collect prompts the user for configuration parameters, starting from the values already in config
+func (c *configCollector) Collect(config *Config) (*Config, error) {
 	wd, _ := os.Getwd()
 
-	var concurrencyStr string = "10"
-	config := &Config{
-		InputDir:    wd,
-		OutputFile:  "output.txt",
-		Concurrency: 10,
-	}
+	concurrencyStr := strconv.Itoa(config.Concurrency)
 
 	form := huh.NewForm(
 		huh.NewGroup(
@@ -70,8 +218,8 @@ func (c *configCollector) Collect() (*Config, error) {
 				}),
 
 			huh.NewInput().
-				Title("🔑 OpenAI API Key").
-				Description("Your OpenAI API key for OCR operations").
+				Title("🔑 API Key").
+				Description("Your API key of the OCR provider").
 				Value(&config.APIKey).
 				Password(true).
 				Validate(func(s string) error {
@@ -0,0 +1,164 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigFileName is the name of the config file read from the input directory
+const ProjectConfigFileName = ".ocr.yaml"
+
+// profilesKey is the config file key that holds the named profiles
+const profilesKey = "profiles"
+
+// configFile is a parsed YAML config file. Its keys are the flag names, e.g.
+//
+//	concurrency: 5
+//	model: gpt-4o
+//	profiles:
+//	  handwriting-cheap:
+//	    model: gpt-4o-mini
+//	    max-image-dimension: 1024
+type configFile struct {
+	path     string
+	project  bool // the file is a project file, which can not set the global only options
+	values   map[string]any
+	profiles map[string]map[string]any
+}
+
+// defaultGlobalConfigPath returns the path of the global config file in the user's config directory
+func defaultGlobalConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "ocr", "config.yaml")
+}
+
+// loadConfigFile reads and parses the config file at path.
+// A missing file returns a nil configFile unless the file is required.
+func loadConfigFile(path string, required, project bool) (*configFile, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfigFile, err)
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidConfigFile, path, err)
+	}
+
+	file := &configFile{
+		path:     path,
+		project:  project,
+		values:   values,
+		profiles: map[string]map[string]any{},
+	}
+
+	// Split the profiles out of the top level values
+	if raw, ok := values[profilesKey]; ok {
+		delete(values, profilesKey)
+		profiles, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: %s must be a mapping", ErrInvalidConfigFile, path, profilesKey)
+		}
+		for name, rawProfile := range profiles {
+			profile, ok := rawProfile.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s: profile %q must be a mapping", ErrInvalidConfigFile, path, name)
+			}
+			file.profiles[name] = profile
+		}
+	}
+
+	return file, nil
+}
+
+// hasProfile reports whether the file defines the named profile
+func (f *configFile) hasProfile(name string) bool {
+	if f == nil {
+		return false
+	}
+	_, ok := f.profiles[name]
+	return ok
+}
+
+// apply sets the file's top level values on cfg, followed by the values of the named profile
+func (f *configFile) apply(cfg *Config, profile string) error {
+	if f == nil {
+		return nil
+	}
+	if err := f.applyValues(cfg, f.values); err != nil {
+		return err
+	}
+	if profile == "" {
+		return nil
+	}
+	return f.applyValues(cfg, f.profiles[profile])
+}
+
+// applyValues sets values on cfg in a stable order using the matching options.
+// Empty values, e.g. "model:" or "model: null", leave the setting unset.
+func (f *configFile) applyValues(cfg *Config, values map[string]any) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		opt, ok := findOption(key)
+		if !ok || opt.noFile {
+			return fmt.Errorf("%w: %s: unknown setting %q", ErrInvalidConfigFile, f.path, key)
+		}
+		if opt.globalOnly && f.project {
+			return fmt.Errorf("%w: %s: %q can only be set in the global config file", ErrInvalidConfigFile, f.path, key)
+		}
+		if values[key] == nil {
+			continue
+		}
+		if err := opt.set(cfg, configValue(values[key])); err != nil {
+			return fmt.Errorf("%s: %s: %w", f.path, key, err)
+		}
+	}
+
+	return nil
+}
+
+// configValue returns the flag value of a config file value, joining lists with commas and leaving out empty items
+func configValue(value any) string {
+	list, ok := value.([]any)
+	if !ok {
+		return fmt.Sprint(value)
+	}
+	items := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != nil {
+			items = append(items, fmt.Sprint(item))
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// findOption returns the option with the given flag name
+func findOption(name string) (option, bool) {
+	for _, opt := range options {
+		if opt.flag == name {
+			return opt, true
+		}
+	}
+	return option{}, false
+}
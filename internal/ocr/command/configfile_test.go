@@ -0,0 +1,139 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFile writes content to name inside dir and returns the full path
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	err := os.WriteFile(path, []byte(content), 0644)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestLoadConfig_ConfigFiles(t *testing.T) {
+	configDir := t.TempDir()
+	inputDir := t.TempDir()
+
+	globalPath := writeFile(t, configDir, "config.yaml", `
+model: gpt-4o
+concurrency: 3
+max-tokens: 2000
+profiles:
+  handwriting-cheap:
+    model: gpt-4o-mini
+    max-image-dimension: 1024
+`)
+	writeFile(t, inputDir, ProjectConfigFileName, `
+output: journal.txt
+concurrency: 6
+date-locales: [en, de]
+profiles:
+  handwriting-cheap:
+    max-retries: 2
+`)
+
+	t.Run("global and project files", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"--input", inputDir}, envMap(map[string]string{"OCR_CONFIG": globalPath}))
+		assert.NoError(t, err)
+		assert.Equal(t, "gpt-4o", cfg.Model)
+		assert.Equal(t, 2000, cfg.MaxTokens)
+		assert.Equal(t, "journal.txt", cfg.OutputFile)
+		assert.Equal(t, 6, cfg.Concurrency, "project file should override global file")
+		assert.Equal(t, 1500, cfg.MaxImageDimension)
+		assert.Equal(t, []string{"en", "de"}, cfg.DateLocales, "lists should be joined with commas")
+	})
+
+	t.Run("profile", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"--input", inputDir, "--config", globalPath, "--profile", "handwriting-cheap"}, envMap(nil))
+		assert.NoError(t, err)
+		assert.Equal(t, "gpt-4o-mini", cfg.Model)
+		assert.Equal(t, 1024, cfg.MaxImageDimension)
+		assert.Equal(t, 2, cfg.MaxRetries)
+	})
+
+	t.Run("env and flags override files", func(t *testing.T) {
+		env := envMap(map[string]string{"OCR_CONFIG": globalPath, "OCR_MODEL": "env-model", "OCR_CONCURRENCY": "8"})
+		cfg, err := loadConfig([]string{"--input", inputDir, "--profile", "handwriting-cheap", "--concurrency", "9"}, env)
+		assert.NoError(t, err)
+		assert.Equal(t, "env-model", cfg.Model)
+		assert.Equal(t, 9, cfg.Concurrency)
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := loadConfig([]string{"--input", inputDir, "--config", globalPath, "--profile", "missing"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrUnknownProfile)
+	})
+
+	t.Run("missing explicit config file", func(t *testing.T) {
+		_, err := loadConfig([]string{"--config", filepath.Join(configDir, "missing.yaml")}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidConfigFile)
+	})
+}
+
+func TestLoadConfigFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("unknown setting", func(t *testing.T) {
+		path := writeFile(t, dir, "unknown.yaml", "colour: blue\n")
+		file, err := loadConfigFile(path, true, false)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, file.apply(defaultConfig(), ""), ErrInvalidConfigFile)
+	})
+
+	t.Run("profile can not be set from a file", func(t *testing.T) {
+		path := writeFile(t, dir, "profile.yaml", "profile: cheap\n")
+		file, err := loadConfigFile(path, true, false)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, file.apply(defaultConfig(), ""), ErrInvalidConfigFile)
+	})
+
+	t.Run("credentials and endpoints can not be set from a project file", func(t *testing.T) {
+		for _, setting := range []string{"api-key: sk-test", "base-url: https://example.com/v1", "auth-header: none"} {
+			path := writeFile(t, dir, "project.yaml", setting+"\n")
+			project, err := loadConfigFile(path, true, true)
+			assert.NoError(t, err)
+			assert.ErrorIs(t, project.apply(defaultConfig(), ""), ErrInvalidConfigFile, setting)
+
+			global, err := loadConfigFile(path, true, false)
+			assert.NoError(t, err)
+			assert.NoError(t, global.apply(defaultConfig(), ""), setting)
+		}
+	})
+
+	t.Run("empty values are unset", func(t *testing.T) {
+		path := writeFile(t, dir, "empty.yaml", "model:\nbase-url: null\ndate-locales: [en, null]\n")
+		file, err := loadConfigFile(path, true, false)
+		assert.NoError(t, err)
+		cfg := defaultConfig()
+		assert.NoError(t, file.apply(cfg, ""))
+		assert.Equal(t, defaultConfig().Model, cfg.Model)
+		assert.Empty(t, cfg.BaseURL)
+		assert.Equal(t, []string{"en"}, cfg.DateLocales)
+	})
+
+	t.Run("invalid yaml", func(t *testing.T) {
+		path := writeFile(t, dir, "invalid.yaml", "concurrency: [\n")
+		_, err := loadConfigFile(path, true, false)
+		assert.ErrorIs(t, err, ErrInvalidConfigFile)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		path := writeFile(t, dir, "value.yaml", "concurrency: lots\n")
+		file, err := loadConfigFile(path, true, false)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, file.apply(defaultConfig(), ""), ErrInvalidInput)
+	})
+
+	t.Run("missing optional file", func(t *testing.T) {
+		file, err := loadConfigFile(filepath.Join(dir, "missing.yaml"), false, false)
+		assert.NoError(t, err)
+		assert.Nil(t, file)
+	})
+}
@@ -0,0 +1,736 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/client"
+	"github.com/marksalpeter/ocr/internal/ocr/preprocess"
+	"github.com/marksalpeter/ocr/internal/ocr/prompt"
+)
+
+// option describes a configuration value that can be set from a command line flag or an environment variable
+type option struct {
+	flag   string
+	env    string
+	usage  string
+	isBool bool
+	noFile bool // the option can not be set from a config file
+	// the option can only be set from the global config file, so a project file can not redirect the API key
+	globalOnly bool
+	set        func(cfg *Config, value string) error
+}
+
+// options lists every configurable value in the order it is shown in the usage text
+var options = []option{
+	{
+		flag:  "input",
+		env:   "OCR_INPUT_DIR",
+		usage: "directory containing images to process (default: current directory)",
+		set: func(cfg *Config, value string) error {
+			cfg.InputDir = value
+			return nil
+		},
+	},
+	{
+		flag:  "include",
+		env:   "OCR_INCLUDE",
+		usage: "comma separated glob patterns of the images to process, matched against the file name or, with a slash, the path in the input directory, e.g. *.jpg,vol1/* (default: every image)",
+		set: func(cfg *Config, value string) error {
+			return setPatterns(&cfg.Include, "include", value)
+		},
+	},
+	{
+		flag:  "exclude",
+		env:   "OCR_EXCLUDE",
+		usage: "comma separated glob patterns of the images and directories to skip, matched like include, e.g. drafts,*-blank.jpg",
+		set: func(cfg *Config, value string) error {
+			return setPatterns(&cfg.Exclude, "exclude", value)
+		},
+	},
+	{
+		flag:  "max-depth",
+		env:   "OCR_MAX_DEPTH",
+		usage: "how many levels of directories are searched for images, 1 for the input directory alone (default: no limit)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.MaxDepth, "max-depth", value)
+		},
+	},
+	{
+		flag:  "output",
+		env:   "OCR_OUTPUT_FILE",
+		usage: "path where the output text will be saved (default: output.txt)",
+		set: func(cfg *Config, value string) error {
+			cfg.OutputFile = value
+			return nil
+		},
+	},
+	{
+		flag:       "api-key",
+		env:        "OPENAI_API_KEY",
+		globalOnly: true,
+		usage:      "API key of the OCR provider, read from ANTHROPIC_API_KEY and GEMINI_API_KEY instead of OPENAI_API_KEY for those providers",
+		set: func(cfg *Config, value string) error {
+			cfg.APIKey = value
+			return nil
+		},
+	},
+	{
+		flag:  "concurrency",
+		env:   "OCR_CONCURRENCY",
+		usage: "number of images to process in parallel (default: 10)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.Concurrency, "concurrency", value)
+		},
+	},
+	{
+		flag:  "start-date",
+		env:   "OCR_START_DATE",
+		usage: "date to use if the first page has no date",
+		set: func(cfg *Config, value string) error {
+			cfg.StartDate = value
+			return nil
+		},
+	},
+	{
+		flag:  "date-locales",
+		env:   "OCR_DATE_LOCALES",
+		usage: "comma separated languages of the month and weekday names in dates: " + strings.Join(ocr.DateLocales(), ", ") + " (default: en)",
+		set: func(cfg *Config, value string) error {
+			var locales []string
+			for _, locale := range strings.Split(value, ",") {
+				locale = strings.ToLower(strings.TrimSpace(locale))
+				if !slices.Contains(ocr.DateLocales(), locale) {
+					return fmt.Errorf("%w: unknown date locale %q, must be one of %s", ErrInvalidInput, locale, strings.Join(ocr.DateLocales(), ", "))
+				}
+				locales = append(locales, locale)
+			}
+			cfg.DateLocales = locales
+			return nil
+		},
+	},
+	{
+		flag:  "date-order",
+		env:   "OCR_DATE_ORDER",
+		usage: "order of the day and month in numeric dates such as 01/02/2024: mdy or dmy (default: mdy)",
+		set: func(cfg *Config, value string) error {
+			switch order := ocr.DateOrder(value); order {
+			case ocr.DateOrderMDY, ocr.DateOrderDMY:
+				cfg.DateOrder = order
+				return nil
+			}
+			return fmt.Errorf("%w: date-order must be mdy or dmy", ErrInvalidInput)
+		},
+	},
+	{
+		flag:  "date-format",
+		env:   "OCR_DATE_FORMAT",
+		usage: "format of the entry dates in the output: iso, long or a Go time layout (default: iso)",
+		set: func(cfg *Config, value string) error {
+			cfg.DateFormat = value
+			return nil
+		},
+	},
+	{
+		flag:  "format",
+		env:   "OCR_FORMAT",
+		usage: "output format: text, json or jsonl (default: text)",
+		set: func(cfg *Config, value string) error {
+			switch value {
+			case FormatText, FormatJSON, FormatJSONL:
+				cfg.Format = value
+				return nil
+			}
+			return fmt.Errorf("%w: format must be text, json or jsonl", ErrInvalidInput)
+		},
+	},
+	{
+		flag:  "template",
+		env:   "OCR_TEMPLATE",
+		usage: "text/template file used to format the output instead of the text layout",
+		set: func(cfg *Config, value string) error {
+			cfg.Template = value
+			return nil
+		},
+	},
+	{
+		flag:   "split-by-date",
+		env:    "OCR_SPLIT_BY_DATE",
+		usage:  "save one file per entry date, e.g. 2024-01-01.txt, next to the output file instead of the output file itself",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.SplitByDate, "split-by-date", value)
+		},
+	},
+	{
+		flag:   "split-by-directory",
+		env:    "OCR_SPLIT_BY_DIRECTORY",
+		usage:  "save the images of each subdirectory of the input directory to their own output, e.g. vol1/output.txt next to the output file",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.SplitByDirectory, "split-by-directory", value)
+		},
+	},
+	{
+		flag:  "provider",
+		env:   "OCR_PROVIDER",
+		usage: "vendor API used for OCR: " + strings.Join(providerNames(), ", ") + " (default: openai)",
+		set: func(cfg *Config, value string) error {
+			provider := client.Provider(value)
+			if !slices.Contains(client.Providers(), provider) {
+				return fmt.Errorf("%w: provider must be one of %s", ErrInvalidInput, strings.Join(providerNames(), ", "))
+			}
+			cfg.Provider = provider
+			return nil
+		},
+	},
+	{
+		flag:  "model",
+		env:   "OCR_MODEL",
+		usage: "model used for OCR (default: gpt-4o for openai, claude-sonnet-4-5 for anthropic, gemini-2.5-flash for gemini)",
+		set: func(cfg *Config, value string) error {
+			cfg.Model = value
+			return nil
+		},
+	},
+	{
+		flag:  "max-tokens",
+		env:   "OCR_MAX_TOKENS",
+		usage: "maximum number of tokens in each transcription (default: 4096)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.MaxTokens, "max-tokens", value)
+		},
+	},
+	{
+		flag:  "prompt-preset",
+		env:   "OCR_PROMPT_PRESET",
+		usage: "type of document the prompts are written for: " + strings.Join(prompt.Presets(), ", ") + " (default: journal)",
+		set: func(cfg *Config, value string) error {
+			if !slices.Contains(prompt.Presets(), value) {
+				return fmt.Errorf("%w: prompt-preset must be one of %s", ErrInvalidInput, strings.Join(prompt.Presets(), ", "))
+			}
+			cfg.PromptPreset = value
+			return nil
+		},
+	},
+	{
+		flag:  "prompt-template",
+		env:   "OCR_PROMPT_TEMPLATE",
+		usage: "text/template file that replaces the system, user or structured prompt of the preset",
+		set: func(cfg *Config, value string) error {
+			cfg.PromptTemplate = value
+			return nil
+		},
+	},
+	{
+		flag:  "language",
+		env:   "OCR_LANGUAGE",
+		usage: "language the pages are written in, e.g. German, given to the model as a hint (default: none)",
+		set: func(cfg *Config, value string) error {
+			cfg.Language = value
+			return nil
+		},
+	},
+	{
+		flag:  "max-retries",
+		env:   "OCR_MAX_RETRIES",
+		usage: "maximum number of OCR attempts per image (default: 5)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.MaxRetries, "max-retries", value)
+		},
+	},
+	{
+		flag:  "retry-base-delay",
+		env:   "OCR_RETRY_BASE_DELAY",
+		usage: "longest wait before the first retry of an image, doubled for each further retry, e.g. 500ms (default: 500ms)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveDuration(&cfg.RetryBaseDelay, "retry-base-delay", value)
+		},
+	},
+	{
+		flag:  "retry-max-delay",
+		env:   "OCR_RETRY_MAX_DELAY",
+		usage: "longest wait before any retry, e.g. 30s (default: 30s)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveDuration(&cfg.RetryMaxDelay, "retry-max-delay", value)
+		},
+	},
+	{
+		flag:  "image-timeout",
+		env:   "OCR_IMAGE_TIMEOUT",
+		usage: "deadline of all attempts of an image together, e.g. 2m (default: no deadline)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveDuration(&cfg.ImageTimeout, "image-timeout", value)
+		},
+	},
+	{
+		flag:  "refusal-strategies",
+		env:   "OCR_REFUSAL_STRATEGIES",
+		usage: "comma separated changes made to the request of an image after each refusal, in order: " + strings.Join(refusalStrategyNames(), ", ") + " (default: context,model,split)",
+		set: func(cfg *Config, value string) error {
+			var strategies []client.RefusalStrategy
+			for _, name := range strings.Split(value, ",") {
+				strategy := client.RefusalStrategy(strings.ToLower(strings.TrimSpace(name)))
+				if !slices.Contains(client.RefusalStrategies, strategy) {
+					return fmt.Errorf("%w: unknown refusal strategy %q, must be one of %s", ErrInvalidInput, name, strings.Join(refusalStrategyNames(), ", "))
+				}
+				strategies = append(strategies, strategy)
+			}
+			cfg.RefusalStrategies = strategies
+			return nil
+		},
+	},
+	{
+		flag:  "fallback-model",
+		env:   "OCR_FALLBACK_MODEL",
+		usage: "model of the same provider used by the model refusal strategy (default: none, the strategy is skipped)",
+		set: func(cfg *Config, value string) error {
+			cfg.FallbackModel = value
+			return nil
+		},
+	},
+	{
+		flag:  "requests-per-minute",
+		env:   "OCR_REQUESTS_PER_MINUTE",
+		usage: "most OCR requests sent per minute by all workers together (default: no limit, only the limits reported by the API)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.RequestsPerMinute, "requests-per-minute", value)
+		},
+	},
+	{
+		flag:  "tokens-per-minute",
+		env:   "OCR_TOKENS_PER_MINUTE",
+		usage: "most tokens sent per minute by all workers together, counting the image, prompts and max-tokens of each request (default: no limit)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.TokensPerMinute, "tokens-per-minute", value)
+		},
+	},
+	{
+		flag:  "max-image-dimension",
+		env:   "OCR_MAX_IMAGE_DIMENSION",
+		usage: "longest side, in pixels, images are resized to before OCR (default: 1500)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.MaxImageDimension, "max-image-dimension", value)
+		},
+	},
+	{
+		flag:   "detect-rotation",
+		env:    "OCR_DETECT_ROTATION",
+		usage:  "turn pages whose text runs sideways or upside down upright before OCR; EXIF orientations are always applied",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.DetectRotation, "detect-rotation", value)
+		},
+	},
+	{
+		flag:   "split-spreads",
+		env:    "OCR_SPLIT_SPREADS",
+		usage:  "split photos and scans of open-book spreads into their left and right pages, named like IMG_0001.jpg#L and IMG_0001.jpg#R",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.SplitSpreads, "split-spreads", value)
+		},
+	},
+	{
+		flag:   "correct-perspective",
+		env:    "OCR_CORRECT_PERSPECTIVE",
+		usage:  "find the page in photos of a page on a darker background, flatten it and cut away the background before OCR",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.CorrectPerspective, "correct-perspective", value)
+		},
+	},
+	{
+		flag:  "tiles",
+		env:   "OCR_TILES",
+		usage: "fewest overlapping bands each image is split into, from top to bottom, each sent for OCR at full resolution and stitched back together; more bands are used when they would be higher than max-image-dimension (default: 1, the image is sent whole)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveInt(&cfg.Tiles, "tiles", value)
+		},
+	},
+	{
+		flag:  "preprocess",
+		env:   "OCR_PREPROCESS",
+		usage: "comma separated steps applied in order to each resized image before OCR: " + strings.Join(preprocessStepNames(), ", ") + " (default: none)",
+		set: func(cfg *Config, value string) error {
+			var steps []preprocess.Step
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.ToLower(strings.TrimSpace(name)); name == "" || name == "none" {
+					continue
+				}
+				step := preprocess.Step(name)
+				if !slices.Contains(preprocess.Steps, step) {
+					return fmt.Errorf("%w: unknown preprocessing step %q, must be one of %s", ErrInvalidInput, name, strings.Join(preprocessStepNames(), ", "))
+				}
+				steps = append(steps, step)
+			}
+			cfg.Preprocess = steps
+			return nil
+		},
+	},
+	{
+		flag:  "preprocess-debug-dir",
+		env:   "OCR_PREPROCESS_DEBUG_DIR",
+		usage: "directory each image is saved to after every preprocessing step, for debugging",
+		set: func(cfg *Config, value string) error {
+			cfg.PreprocessDebugDir = value
+			return nil
+		},
+	},
+	{
+		flag:  "max-cost",
+		env:   "OCR_MAX_COST",
+		usage: "most a run may spend on OCR, in dollars; images that would go over it are not processed (default: no limit)",
+		set: func(cfg *Config, value string) error {
+			return setPositiveFloat(&cfg.MaxCost, "max-cost", value)
+		},
+	},
+	{
+		flag:  "pricing-file",
+		env:   "OCR_PRICING_FILE",
+		usage: "YAML file with the prices of models, in dollars per million tokens, that replace or add to the built-in prices",
+		set: func(cfg *Config, value string) error {
+			cfg.PricingFile = value
+			return nil
+		},
+	},
+	{
+		flag:       "base-url",
+		env:        "OCR_BASE_URL",
+		globalOnly: true,
+		usage:      "root of the OpenAI compatible API (default: https://api.openai.com/v1)",
+		set: func(cfg *Config, value string) error {
+			cfg.BaseURL = value
+			return nil
+		},
+	},
+	{
+		flag:       "api-type",
+		env:        "OCR_API_TYPE",
+		globalOnly: true,
+		usage:      "URL layout of the API: openai or azure (default: openai)",
+		set: func(cfg *Config, value string) error {
+			switch apiType := client.APIType(value); apiType {
+			case client.APITypeOpenAI, client.APITypeAzure:
+				cfg.APIType = apiType
+				return nil
+			}
+			return fmt.Errorf("%w: api-type must be openai or azure", ErrInvalidInput)
+		},
+	},
+	{
+		flag:  "api-version",
+		env:   "OCR_API_VERSION",
+		usage: "api-version query parameter sent with each request (default for azure: 2024-10-21)",
+		set: func(cfg *Config, value string) error {
+			cfg.APIVersion = value
+			return nil
+		},
+	},
+	{
+		flag:       "azure-deployment",
+		env:        "OCR_AZURE_DEPLOYMENT",
+		globalOnly: true,
+		usage:      "Azure OpenAI deployment name (default: the model name)",
+		set: func(cfg *Config, value string) error {
+			cfg.AzureDeployment = value
+			return nil
+		},
+	},
+	{
+		flag:       "auth-header",
+		env:        "OCR_AUTH_HEADER",
+		globalOnly: true,
+		usage:      "how the API key is sent: bearer, api-key or none (default: bearer, api-key for azure)",
+		set: func(cfg *Config, value string) error {
+			switch header := client.AuthHeader(value); header {
+			case client.AuthHeaderBearer, client.AuthHeaderAPIKey, client.AuthHeaderNone:
+				cfg.AuthHeader = header
+				return nil
+			}
+			return fmt.Errorf("%w: auth-header must be bearer, api-key or none", ErrInvalidInput)
+		},
+	},
+	{
+		flag:   "plain-text",
+		env:    "OCR_PLAIN_TEXT",
+		usage:  "request a free text transcription instead of structured output, for servers that do not support JSON schemas",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.PlainText, "plain-text", value)
+		},
+	},
+	{
+		flag:   "config",
+		env:    "OCR_CONFIG",
+		noFile: true,
+		usage:  "path to the global config file (default: <user config dir>/ocr/config.yaml)",
+		set: func(cfg *Config, value string) error {
+			cfg.ConfigFile = value
+			return nil
+		},
+	},
+	{
+		flag:   "profile",
+		env:    "OCR_PROFILE",
+		noFile: true,
+		usage:  "named profile to apply from the config files",
+		set: func(cfg *Config, value string) error {
+			cfg.Profile = value
+			return nil
+		},
+	},
+	{
+		flag:   "fresh",
+		env:    "OCR_FRESH",
+		usage:  "ignore the checkpoint of previous runs and send every image for OCR again",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.Fresh, "fresh", value)
+		},
+	},
+	{
+		flag:   "no-input",
+		env:    "OCR_NO_INPUT",
+		usage:  "never prompt for missing configuration, fail instead",
+		isBool: true,
+		set: func(cfg *Config, value string) error {
+			return setBool(&cfg.NoInput, "no-input", value)
+		},
+	},
+}
+
+// providerAPIKeyEnvs are the API key variables of the providers
+var providerAPIKeyEnvs = map[client.Provider]string{
+	client.ProviderOpenAI:    "OPENAI_API_KEY",
+	client.ProviderAnthropic: "ANTHROPIC_API_KEY",
+	client.ProviderGemini:    "GEMINI_API_KEY",
+}
+
+// refusalStrategyNames returns the names of the refusal strategies
+func refusalStrategyNames() []string {
+	names := make([]string, 0, len(client.RefusalStrategies))
+	for _, strategy := range client.RefusalStrategies {
+		names = append(names, string(strategy))
+	}
+	return names
+}
+
+// preprocessStepNames returns the names of the preprocessing steps
+func preprocessStepNames() []string {
+	names := make([]string, 0, len(preprocess.Steps))
+	for _, step := range preprocess.Steps {
+		names = append(names, string(step))
+	}
+	return names
+}
+
+// providerNames returns the names of the registered providers
+func providerNames() []string {
+	var names []string
+	for _, provider := range client.Providers() {
+		names = append(names, string(provider))
+	}
+	return names
+}
+
+// flagValue is a flag given on the command line
+type flagValue struct {
+	opt   option
+	value string
+}
+
+// loadConfig builds the configuration from every source, in increasing order of precedence:
+//
+//  1. the defaults
+//  2. the global config file and its selected profile
+//  3. the .ocr.yaml file in the input directory and its selected profile
+//  4. environment variables
+//  5. command line flags
+func loadConfig(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	flagValues, err := parseFlags(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Env and flags decide which config file and profile are used
+	cfg := defaultConfig()
+	if err := applyEnvAndFlags(cfg, lookupEnv, flagValues); err != nil {
+		return nil, err
+	}
+	profile := cfg.Profile
+
+	globalPath, required := cfg.ConfigFile, true
+	if globalPath == "" {
+		globalPath, required = defaultGlobalConfigPath(), false
+	}
+	global, err := loadConfigFile(globalPath, required, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the global file, then env and flags so the input directory of the project file is known
+	cfg = defaultConfig()
+	if err := global.apply(cfg, profile); err != nil {
+		return nil, err
+	}
+	if err := applyEnvAndFlags(cfg, lookupEnv, flagValues); err != nil {
+		return nil, err
+	}
+
+	project, err := loadConfigFile(filepath.Join(cfg.InputDir, ProjectConfigFileName), false, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := project.apply(cfg, profile); err != nil {
+		return nil, err
+	}
+	if err := applyEnvAndFlags(cfg, lookupEnv, flagValues); err != nil {
+		return nil, err
+	}
+
+	if profile != "" && !global.hasProfile(profile) && !project.hasProfile(profile) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProfile, profile)
+	}
+
+	// The API key is only read from the variable of the selected provider, so a key meant for one vendor is never
+	// sent to another. It takes precedence over config files, and --api-key over it.
+	if !slices.ContainsFunc(flagValues, func(fv flagValue) bool { return fv.opt.flag == "api-key" }) {
+		if key, ok := lookupEnv(providerAPIKeyEnvs[cfg.Provider]); ok && key != "" {
+			cfg.APIKey = key
+		}
+	}
+
+	// The model defaults to the one of the selected provider
+	if cfg.Model == "" {
+		cfg.Model = client.DefaultModels[cfg.Provider]
+	}
+
+	return cfg, nil
+}
+
+// parseFlags parses the command line and returns the given flags in order
+func parseFlags(args []string) ([]flagValue, error) {
+	var flagValues []flagValue
+
+	fs := flag.NewFlagSet("ocr", flag.ContinueOnError)
+	for _, opt := range options {
+		usage := fmt.Sprintf("%s (env %s)", opt.usage, opt.env)
+		record := func(value string) error {
+			flagValues = append(flagValues, flagValue{opt: opt, value: value})
+			return nil
+		}
+		if opt.isBool {
+			fs.BoolFunc(opt.flag, usage, record)
+		} else {
+			fs.Func(opt.flag, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("%w: unexpected argument %q", ErrInvalidInput, fs.Arg(0))
+	}
+
+	return flagValues, nil
+}
+
+// applyEnvAndFlags applies environment variables and then flags to the config
+func applyEnvAndFlags(cfg *Config, lookupEnv func(string) (string, bool), flagValues []flagValue) error {
+	for _, opt := range options {
+		// The API key variable depends on the provider, so it is read once the provider is known
+		if opt.flag == "api-key" {
+			continue
+		}
+		value, ok := lookupEnv(opt.env)
+		if !ok || value == "" {
+			continue
+		}
+		if err := opt.set(cfg, value); err != nil {
+			return fmt.Errorf("%s: %w", opt.env, err)
+		}
+	}
+
+	for _, fv := range flagValues {
+		if err := fv.opt.set(cfg, fv.value); err != nil {
+			return fmt.Errorf("--%s: %w", fv.opt.flag, err)
+		}
+	}
+
+	return nil
+}
+
+// setPositiveInt parses value into dst, failing unless it is a positive integer
+func setPositiveInt(dst *int, name, value string) error {
+	conv, err := strconv.Atoi(value)
+	if err != nil || conv <= 0 {
+		return fmt.Errorf("%w: %s must be a positive integer", ErrInvalidInput, name)
+	}
+	*dst = conv
+	return nil
+}
+
+// setPatterns parses the comma separated glob patterns in value into dst, failing on an invalid pattern
+func setPatterns(dst *[]string, name, value string) error {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: invalid %s pattern %q", ErrInvalidInput, name, pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	*dst = patterns
+	return nil
+}
+
+// setPositiveFloat parses value into dst, failing unless it is a positive number
+func setPositiveFloat(dst *float64, name, value string) error {
+	conv, err := strconv.ParseFloat(value, 64)
+	if err != nil || conv <= 0 || math.IsInf(conv, 0) {
+		return fmt.Errorf("%w: %s must be a positive number", ErrInvalidInput, name)
+	}
+	*dst = conv
+	return nil
+}
+
+// setPositiveDuration parses value into dst, failing unless it is a positive duration such as 30s
+func setPositiveDuration(dst *time.Duration, name, value string) error {
+	conv, err := time.ParseDuration(value)
+	if err != nil || conv <= 0 {
+		return fmt.Errorf("%w: %s must be a positive duration, e.g. 30s", ErrInvalidInput, name)
+	}
+	*dst = conv
+	return nil
+}
+
+// setBool parses value into dst, failing unless it is a boolean
+func setBool(dst *bool, name, value string) error {
+	conv, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("%w: %s must be a boolean", ErrInvalidInput, name)
+	}
+	*dst = conv
+	return nil
+}
+
+// isTerminal reports whether the file is attached to an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,312 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/marksalpeter/ocr/internal/ocr/client"
+	"github.com/marksalpeter/ocr/internal/ocr/preprocess"
+	"github.com/stretchr/testify/assert"
+)
+
+// envMap returns a lookupEnv func backed by the given map
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+}
+
+// isolateUserConfig points the user config directory at an empty temp dir
+func isolateUserConfig(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+}
+
+func TestLoadConfig_Defaults(t *testing.T) {
+	isolateUserConfig(t)
+
+	cfg, err := loadConfig(nil, envMap(nil))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cfg.InputDir)
+	assert.Equal(t, "output.txt", cfg.OutputFile)
+	assert.Equal(t, 10, cfg.Concurrency)
+	assert.Equal(t, FormatText, cfg.Format)
+	assert.Equal(t, "gpt-4o", cfg.Model)
+	assert.Equal(t, 4096, cfg.MaxTokens)
+	assert.Equal(t, 5, cfg.MaxRetries)
+	assert.Equal(t, 1500, cfg.MaxImageDimension)
+	assert.False(t, cfg.DetectRotation)
+	assert.False(t, cfg.CorrectPerspective)
+	assert.False(t, cfg.SplitSpreads)
+	assert.Equal(t, 1, cfg.Tiles)
+	assert.Empty(t, cfg.Preprocess)
+	assert.Empty(t, cfg.PreprocessDebugDir)
+	assert.Zero(t, cfg.MaxCost)
+	assert.Equal(t, 500*time.Millisecond, cfg.RetryBaseDelay)
+	assert.Equal(t, 30*time.Second, cfg.RetryMaxDelay)
+	assert.Zero(t, cfg.ImageTimeout)
+	assert.Equal(t, client.DefaultRefusalStrategies, cfg.RefusalStrategies)
+	assert.Empty(t, cfg.FallbackModel)
+	assert.Equal(t, "journal", cfg.PromptPreset)
+	assert.Empty(t, cfg.PromptTemplate)
+	assert.Empty(t, cfg.Language)
+	assert.Empty(t, cfg.APIKey)
+	assert.False(t, cfg.NoInput)
+	assert.False(t, cfg.SplitByDate)
+	assert.False(t, cfg.SplitByDirectory)
+	assert.Empty(t, cfg.Include)
+	assert.Empty(t, cfg.Exclude)
+	assert.Zero(t, cfg.MaxDepth)
+	assert.Equal(t, []string{"en"}, cfg.DateLocales)
+	assert.Equal(t, ocr.DateOrderMDY, cfg.DateOrder)
+	assert.Equal(t, "2006-01-02", cfg.DateLayout())
+}
+
+func TestLoadConfig_Directories(t *testing.T) {
+	isolateUserConfig(t)
+
+	cfg, err := loadConfig([]string{"--include", "*.jpg, vol1/*", "--max-depth", "2", "--split-by-directory"}, envMap(map[string]string{"OCR_EXCLUDE": "drafts"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*.jpg", "vol1/*"}, cfg.Include)
+	assert.Equal(t, []string{"drafts"}, cfg.Exclude)
+	assert.Equal(t, 2, cfg.MaxDepth)
+	assert.True(t, cfg.SplitByDirectory)
+
+	_, err = loadConfig([]string{"--exclude", "[drafts"}, envMap(nil))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	_, err = loadConfig([]string{"--max-depth", "0"}, envMap(nil))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestLoadConfig_Dates(t *testing.T) {
+	isolateUserConfig(t)
+
+	cfg, err := loadConfig([]string{"--date-locales", "de, FR", "--date-order", "dmy", "--date-format", "long"}, envMap(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"de", "fr"}, cfg.DateLocales)
+	assert.Equal(t, ocr.DateOrderDMY, cfg.DateOrder)
+	assert.Equal(t, "Monday, January 2, 2006", cfg.DateLayout())
+
+	// Any other format is a Go time layout
+	cfg, err = loadConfig(nil, envMap(map[string]string{"OCR_DATE_FORMAT": "02.01.2006"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "02.01.2006", cfg.DateLayout())
+
+	_, err = loadConfig([]string{"--date-locales", "en,xx"}, envMap(nil))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = loadConfig([]string{"--date-order", "ymd"}, envMap(nil))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestLoadConfig_EnvAndFlags(t *testing.T) {
+	isolateUserConfig(t)
+
+	env := envMap(map[string]string{
+		"OCR_INPUT_DIR":   "/env/images",
+		"OCR_OUTPUT_FILE": "env.txt",
+		"OPENAI_API_KEY":  "env-key",
+		"OCR_CONCURRENCY": "4",
+		"OCR_START_DATE":  "January 1, 2024",
+		"OCR_MAX_COST":    "2.50",
+
+		"OCR_REFUSAL_STRATEGIES": "context, Model",
+		"OCR_FALLBACK_MODEL":     "gpt-4.1",
+		"OCR_PROMPT_PRESET":      "letter",
+		"OCR_LANGUAGE":           "German",
+	})
+
+	t.Run("env only", func(t *testing.T) {
+		cfg, err := loadConfig(nil, env)
+		assert.NoError(t, err)
+		assert.Equal(t, "/env/images", cfg.InputDir)
+		assert.Equal(t, "env.txt", cfg.OutputFile)
+		assert.Equal(t, "env-key", cfg.APIKey)
+		assert.Equal(t, 4, cfg.Concurrency)
+		assert.Equal(t, "January 1, 2024", cfg.StartDate)
+		assert.Equal(t, 2.5, cfg.MaxCost)
+		assert.Equal(t, []client.RefusalStrategy{client.RefusalStrategyContext, client.RefusalStrategyModel}, cfg.RefusalStrategies)
+		assert.Equal(t, "gpt-4.1", cfg.FallbackModel)
+		assert.Equal(t, "letter", cfg.PromptPreset)
+		assert.Equal(t, "German", cfg.Language)
+	})
+
+	t.Run("flags override env", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"--input", "/flag/images", "--concurrency=2", "--no-input", "--format", "jsonl", "--split-by-date", "--image-timeout", "2m", "--detect-rotation", "--correct-perspective", "--split-spreads", "--tiles", "3", "--preprocess", "shadows, CLAHE,binarize"}, env)
+		assert.NoError(t, err)
+		assert.Equal(t, 2*time.Minute, cfg.ImageTimeout)
+		assert.True(t, cfg.DetectRotation)
+		assert.True(t, cfg.CorrectPerspective)
+		assert.True(t, cfg.SplitSpreads)
+		assert.Equal(t, 3, cfg.Tiles)
+		assert.Equal(t, []preprocess.Step{preprocess.StepShadows, preprocess.StepCLAHE, preprocess.StepBinarize}, cfg.Preprocess)
+		assert.Equal(t, FormatJSONL, cfg.Format)
+		assert.True(t, cfg.SplitByDate)
+		assert.Equal(t, "/flag/images", cfg.InputDir)
+		assert.Equal(t, "env.txt", cfg.OutputFile)
+		assert.Equal(t, 2, cfg.Concurrency)
+		assert.True(t, cfg.NoInput)
+	})
+}
+
+func TestLoadConfig_Errors(t *testing.T) {
+	isolateUserConfig(t)
+
+	t.Run("invalid concurrency flag", func(t *testing.T) {
+		_, err := loadConfig([]string{"--concurrency", "zero"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid concurrency env", func(t *testing.T) {
+		_, err := loadConfig(nil, envMap(map[string]string{"OCR_CONCURRENCY": "-1"}))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		_, err := loadConfig([]string{"--format", "xml"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid retry delay", func(t *testing.T) {
+		_, err := loadConfig([]string{"--retry-base-delay", "5"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid max cost", func(t *testing.T) {
+		_, err := loadConfig([]string{"--max-cost", "-5"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid prompt preset", func(t *testing.T) {
+		_, err := loadConfig([]string{"--prompt-preset", "diary"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid preprocessing step", func(t *testing.T) {
+		_, err := loadConfig([]string{"--preprocess", "grayscale,sharpen"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("invalid refusal strategy", func(t *testing.T) {
+		_, err := loadConfig([]string{"--refusal-strategies", "context,rephrase"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("unexpected argument", func(t *testing.T) {
+		_, err := loadConfig([]string{"extra"}, envMap(nil))
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+
+	t.Run("unknown flag", func(t *testing.T) {
+		_, err := loadConfig([]string{"--unknown"}, envMap(nil))
+		assert.Error(t, err)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := defaultConfig()
+	err := cfg.Validate()
+	assert.ErrorIs(t, err, ErrMissingConfig)
+	assert.Contains(t, err.Error(), "OPENAI_API_KEY")
+
+	// Estimates send nothing, so they do not need a key
+	assert.NoError(t, cfg.validate(false))
+
+	cfg.APIKey = "key"
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Concurrency = 0
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidInput)
+
+	// Templates replace the text layout, so they can not be combined with another format
+	cfg = defaultConfig()
+	cfg.APIKey = "key"
+	cfg.Template = "journal.tmpl"
+	assert.NoError(t, cfg.Validate())
+	cfg.Format = FormatJSON
+	assert.ErrorIs(t, cfg.Validate(), ErrInvalidInput)
+
+	// Local servers without authentication do not need a key
+	cfg = defaultConfig()
+	cfg.AuthHeader = client.AuthHeaderNone
+	assert.NoError(t, cfg.Validate())
+
+	// Azure needs an endpoint
+	cfg = defaultConfig()
+	cfg.APIKey = "key"
+	cfg.APIType = client.APITypeAzure
+	err = cfg.Validate()
+	assert.ErrorIs(t, err, ErrMissingConfig)
+	assert.Contains(t, err.Error(), "--base-url")
+}
+
+func TestLoadConfig_Endpoint(t *testing.T) {
+	isolateUserConfig(t)
+
+	cfg, err := loadConfig([]string{"--api-type", "azure", "--base-url", "https://example.openai.azure.com", "--azure-deployment", "ocr", "--api-version", "2025-01-01", "--plain-text"}, envMap(map[string]string{"OCR_AUTH_HEADER": "bearer"}))
+	assert.NoError(t, err)
+	assert.True(t, cfg.PlainText)
+	assert.Equal(t, client.APITypeAzure, cfg.APIType)
+	assert.Equal(t, "https://example.openai.azure.com", cfg.BaseURL)
+	assert.Equal(t, "ocr", cfg.AzureDeployment)
+	assert.Equal(t, "2025-01-01", cfg.APIVersion)
+	assert.Equal(t, client.AuthHeaderBearer, cfg.AuthHeader)
+
+	_, err = loadConfig([]string{"--api-type", "anthropic"}, envMap(nil))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = loadConfig([]string{"--auth-header", "basic"}, envMap(nil))
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestLoadConfig_Provider(t *testing.T) {
+	isolateUserConfig(t)
+
+	env := envMap(map[string]string{"OPENAI_API_KEY": "openai-key", "ANTHROPIC_API_KEY": "anthropic-key"})
+
+	t.Run("default", func(t *testing.T) {
+		cfg, err := loadConfig(nil, env)
+		assert.NoError(t, err)
+		assert.Equal(t, client.ProviderOpenAI, cfg.Provider)
+		assert.Equal(t, "openai-key", cfg.APIKey)
+		assert.Equal(t, "gpt-4o", cfg.Model)
+	})
+
+	t.Run("provider key and model", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"--provider", "anthropic"}, env)
+		assert.NoError(t, err)
+		assert.Equal(t, client.ProviderAnthropic, cfg.Provider)
+		assert.Equal(t, "anthropic-key", cfg.APIKey)
+		assert.Equal(t, client.DefaultAnthropicModel, cfg.Model)
+	})
+
+	t.Run("flags override provider key and model", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"--provider", "anthropic", "--api-key", "flag-key", "--model", "claude-haiku-4-5"}, env)
+		assert.NoError(t, err)
+		assert.Equal(t, "flag-key", cfg.APIKey)
+		assert.Equal(t, "claude-haiku-4-5", cfg.Model)
+	})
+
+	t.Run("OpenAI key is not sent to another provider", func(t *testing.T) {
+		cfg, err := loadConfig([]string{"--provider", "anthropic"}, envMap(map[string]string{"OPENAI_API_KEY": "openai-key"}))
+		assert.NoError(t, err)
+		assert.Empty(t, cfg.APIKey)
+		assert.ErrorContains(t, cfg.Validate(), "API key (--api-key or ANTHROPIC_API_KEY)")
+
+		// The key of the config file is used instead
+		configPath := writeFile(t, t.TempDir(), "config.yaml", "provider: anthropic\napi-key: file-key\n")
+		cfg, err = loadConfig(nil, envMap(map[string]string{"OPENAI_API_KEY": "openai-key", "OCR_CONFIG": configPath}))
+		assert.NoError(t, err)
+		assert.Equal(t, client.ProviderAnthropic, cfg.Provider)
+		assert.Equal(t, "file-key", cfg.APIKey)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		_, err := loadConfig([]string{"--provider", "mistral"}, env)
+		assert.ErrorIs(t, err, ErrInvalidInput)
+	})
+}
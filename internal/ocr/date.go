@@ -0,0 +1,345 @@
+package ocr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateOrder is the order of the day and the month in numeric dates such as 01/02/2024
+type DateOrder string
+
+const (
+	// DateOrderMDY reads 01/02/2024 as January 2, 2024
+	DateOrderMDY DateOrder = "mdy"
+	// DateOrderDMY reads 01/02/2024 as February 1, 2024
+	DateOrderDMY DateOrder = "dmy"
+)
+
+const (
+	// DefaultDateLocale is the locale used when none is configured
+	DefaultDateLocale = "en"
+	// DefaultDateOrder is the order of numeric dates when none is configured
+	DefaultDateOrder = DateOrderMDY
+	// DefaultDateLayout formats entry dates as ISO 8601 dates, so they sort and compare correctly
+	DefaultDateLayout = time.DateOnly
+)
+
+// ErrUnknownDateLocale is returned when a date locale is not supported
+var ErrUnknownDateLocale = fmt.Errorf("unknown date locale")
+
+// dateLocale contains the month and weekday names of a language, full names first
+type dateLocale struct {
+	months   [12][]string
+	weekdays [7][]string
+}
+
+// dateLocales are the supported locales keyed by their language code
+var dateLocales = map[string]dateLocale{
+	"en": {
+		months: [12][]string{
+			{"january", "jan"}, {"february", "feb"}, {"march", "mar"}, {"april", "apr"},
+			{"may"}, {"june", "jun"}, {"july", "jul"}, {"august", "aug"},
+			{"september", "sept", "sep"}, {"october", "oct"}, {"november", "nov"}, {"december", "dec"},
+		},
+		weekdays: [7][]string{
+			{"sunday", "sun"}, {"monday", "mon"}, {"tuesday", "tues", "tue"}, {"wednesday", "wed"},
+			{"thursday", "thurs", "thu"}, {"friday", "fri"}, {"saturday", "sat"},
+		},
+	},
+	"de": {
+		months: [12][]string{
+			{"januar", "jänner", "jan"}, {"februar", "feb"}, {"märz", "maerz", "mär", "mrz"}, {"april", "apr"},
+			{"mai"}, {"juni", "jun"}, {"juli", "jul"}, {"august", "aug"},
+			{"september", "sept", "sep"}, {"oktober", "okt"}, {"november", "nov"}, {"dezember", "dez"},
+		},
+		weekdays: [7][]string{
+			{"sonntag", "so"}, {"montag", "mo"}, {"dienstag", "di"}, {"mittwoch", "mi"},
+			{"donnerstag", "do"}, {"freitag", "fr"}, {"samstag", "sonnabend", "sa"},
+		},
+	},
+	"fr": {
+		months: [12][]string{
+			{"janvier", "janv"}, {"février", "fevrier", "févr", "fevr"}, {"mars"}, {"avril", "avr"},
+			{"mai"}, {"juin"}, {"juillet", "juil"}, {"août", "aout"},
+			{"septembre", "sept"}, {"octobre", "oct"}, {"novembre", "nov"}, {"décembre", "decembre", "déc", "dec"},
+		},
+		weekdays: [7][]string{
+			{"dimanche", "dim"}, {"lundi", "lun"}, {"mardi", "mar"}, {"mercredi", "mer"},
+			{"jeudi", "jeu"}, {"vendredi", "ven"}, {"samedi", "sam"},
+		},
+	},
+	"es": {
+		months: [12][]string{
+			{"enero", "ene"}, {"febrero", "feb"}, {"marzo", "mar"}, {"abril", "abr"},
+			{"mayo", "may"}, {"junio", "jun"}, {"julio", "jul"}, {"agosto", "ago"},
+			{"septiembre", "setiembre", "sept", "sep"}, {"octubre", "oct"}, {"noviembre", "nov"}, {"diciembre", "dic"},
+		},
+		weekdays: [7][]string{
+			{"domingo", "dom"}, {"lunes", "lun"}, {"martes", "mar"}, {"miércoles", "miercoles", "mié", "mie"},
+			{"jueves", "jue"}, {"viernes", "vie"}, {"sábado", "sabado", "sáb", "sab"},
+		},
+	},
+	"it": {
+		months: [12][]string{
+			{"gennaio", "gen"}, {"febbraio", "feb"}, {"marzo", "mar"}, {"aprile", "apr"},
+			{"maggio", "mag"}, {"giugno", "giu"}, {"luglio", "lug"}, {"agosto", "ago"},
+			{"settembre", "set"}, {"ottobre", "ott"}, {"novembre", "nov"}, {"dicembre", "dic"},
+		},
+		weekdays: [7][]string{
+			{"domenica", "dom"}, {"lunedì", "lunedi", "lun"}, {"martedì", "martedi", "mar"}, {"mercoledì", "mercoledi", "mer"},
+			{"giovedì", "giovedi", "gio"}, {"venerdì", "venerdi", "ven"}, {"sabato", "sab"},
+		},
+	},
+	"nl": {
+		months: [12][]string{
+			{"januari", "jan"}, {"februari", "feb"}, {"maart", "mrt"}, {"april", "apr"},
+			{"mei"}, {"juni", "jun"}, {"juli", "jul"}, {"augustus", "aug"},
+			{"september", "sept", "sep"}, {"oktober", "okt"}, {"november", "nov"}, {"december", "dec"},
+		},
+		weekdays: [7][]string{
+			{"zondag", "zo"}, {"maandag", "ma"}, {"dinsdag", "di"}, {"woensdag", "wo"},
+			{"donderdag", "do"}, {"vrijdag", "vr"}, {"zaterdag", "za"},
+		},
+	},
+	"pt": {
+		months: [12][]string{
+			{"janeiro", "jan"}, {"fevereiro", "fev"}, {"março", "marco", "mar"}, {"abril", "abr"},
+			{"maio", "mai"}, {"junho", "jun"}, {"julho", "jul"}, {"agosto", "ago"},
+			{"setembro", "set"}, {"outubro", "out"}, {"novembro", "nov"}, {"dezembro", "dez"},
+		},
+		weekdays: [7][]string{
+			{"domingo", "dom"}, {"segunda-feira", "segunda", "seg"}, {"terça-feira", "terca-feira", "terça", "terca", "ter"},
+			{"quarta-feira", "quarta", "qua"}, {"quinta-feira", "quinta", "qui"}, {"sexta-feira", "sexta", "sex"},
+			{"sábado", "sabado", "sáb", "sab"},
+		},
+	},
+}
+
+// DateLocales returns the supported date locales in alphabetical order
+func DateLocales() []string {
+	locales := make([]string, 0, len(dateLocales))
+	for locale := range dateLocales {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Date is a date found in a transcription
+type Date struct {
+	// Time is the date at midnight UTC
+	Time time.Time
+	// Text is the date as it was written
+	Text string
+}
+
+// DateParserConfig configures how dates are found and formatted
+type DateParserConfig struct {
+	// Locales are the languages of the month and weekday names (default: en)
+	Locales []string
+	// Order is the order of the day and the month in numeric dates (default: mdy)
+	Order DateOrder
+	// Layout is the time layout entry dates are formatted with (default: 2006-01-02)
+	Layout string
+}
+
+// DateParser finds dates written in the configured locales and formats them in a normalized layout
+type DateParser struct {
+	order    DateOrder
+	layout   string
+	months   map[string]time.Month
+	patterns []*regexp.Regexp
+}
+
+// ordinal matches the suffixes written after a day, e.g. 1st, 1er, 1º or 3.
+const ordinal = `(?:st|nd|rd|th|er|º|°|ª|\.)?`
+
+// NewDateParser creates a new DateParser, using the defaults for every value that is not configured
+func NewDateParser(config DateParserConfig) (*DateParser, error) {
+	if len(config.Locales) == 0 {
+		config.Locales = []string{DefaultDateLocale}
+	}
+	if config.Order == "" {
+		config.Order = DefaultDateOrder
+	}
+	if config.Layout == "" {
+		config.Layout = DefaultDateLayout
+	}
+	if config.Order != DateOrderMDY && config.Order != DateOrderDMY {
+		return nil, fmt.Errorf("%w: date order must be %s or %s", ErrInvalidConfig, DateOrderMDY, DateOrderDMY)
+	}
+
+	// Collect the month and weekday names of every locale
+	months := map[string]time.Month{}
+	var weekdays []string
+	for _, name := range config.Locales {
+		locale, ok := dateLocales[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownDateLocale, name)
+		}
+		for i, names := range locale.months {
+			for _, month := range names {
+				months[month] = time.Month(i + 1)
+			}
+		}
+		for _, names := range locale.weekdays {
+			weekdays = append(weekdays, names...)
+		}
+	}
+	monthNames := make([]string, 0, len(months))
+	for month := range months {
+		monthNames = append(monthNames, month)
+	}
+
+	weekday := `(?:(?:` + alternation(weekdays) + `)\.?,?\s+)?`
+	month := `(` + alternation(monthNames) + `)\.?`
+	day := `(\d{1,2})` + ordinal
+	patterns := []string{
+		// 2024-01-02 or 2024/01/02
+		weekday + `(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})`,
+		// 01/02/2024, 01-02-24 or 01.02.2024
+		weekday + `(\d{1,2})[-/.](\d{1,2})[-/.](\d{4}|\d{2})`,
+		// 2 January 2024, 2nd of Jan 2024, 2. Januar 2024 or 2 de enero de 2024
+		weekday + day + `\s*(?:of\s+|de\s+)?` + month + `,?\s+(?:de\s+)?(\d{4})`,
+		// January 2, 2024 or Jan 2nd 2024
+		weekday + month + `\s+` + day + `,?\s+(\d{4})`,
+	}
+
+	parser := &DateParser{
+		order:  config.Order,
+		layout: config.Layout,
+		months: months,
+	}
+	for _, pattern := range patterns {
+		parser.patterns = append(parser.patterns, regexp.MustCompile(`(?i)\b`+pattern+`\b`))
+	}
+	return parser, nil
+}
+
+// alternation returns a regular expression that matches any of the names, trying longer names first
+func alternation(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, regexp.QuoteMeta(name))
+	}
+	sort.Slice(quoted, func(i, j int) bool {
+		if len(quoted[i]) != len(quoted[j]) {
+			return len(quoted[i]) > len(quoted[j])
+		}
+		return quoted[i] < quoted[j]
+	})
+	return strings.Join(quoted, "|")
+}
+
+// Extract finds the date at the top of a transcription.
+// Only the first 5 lines are searched, since journal entries start with their date.
+func (p *DateParser) Extract(text string) (Date, bool) {
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines) && i < 5; i++ {
+		if date, ok := p.Parse(lines[i]); ok {
+			return date, true
+		}
+	}
+	return Date{}, false
+}
+
+// Parse finds the first valid date in s
+func (p *DateParser) Parse(s string) (Date, bool) {
+	var found Date
+	start := -1
+	for i, pattern := range p.patterns {
+		for _, match := range pattern.FindAllStringSubmatchIndex(s, -1) {
+			// Keep the earliest date in the text, preferring the longest at the same position
+			if start != -1 && (match[0] > start || (match[0] == start && match[1]-match[0] <= len(found.Text))) {
+				continue
+			}
+			groups := make([]string, 0, len(match)/2-1)
+			for g := 2; g < len(match); g += 2 {
+				groups = append(groups, s[match[g]:match[g+1]])
+			}
+			t, ok := p.toTime(i, groups)
+			if !ok {
+				continue
+			}
+			found, start = Date{Time: t, Text: s[match[0]:match[1]]}, match[0]
+		}
+	}
+	return found, start != -1
+}
+
+// toTime converts the groups matched by the pattern at index into a date
+func (p *DateParser) toTime(index int, groups []string) (time.Time, bool) {
+	switch index {
+	case 0:
+		return newDate(groups[0], groups[1], groups[2])
+	case 1:
+		first, second := groups[0], groups[1]
+		if p.order == DateOrderDMY {
+			first, second = second, first
+		}
+		// Fall back to the other order when the configured order can not be a date, e.g. 13/01/2024
+		if t, ok := newDate(groups[2], first, second); ok {
+			return t, true
+		}
+		return newDate(groups[2], second, first)
+	case 2:
+		return newDate(groups[2], p.month(groups[1]), groups[0])
+	default:
+		return newDate(groups[2], p.month(groups[0]), groups[1])
+	}
+}
+
+// month returns the number of the named month
+func (p *DateParser) month(name string) string {
+	return strconv.Itoa(int(p.months[strings.ToLower(name)]))
+}
+
+// newDate returns the date at midnight UTC, failing unless the year, month and day form a real date.
+// Two digit years follow the convention of time.Parse: 69 to 99 are in the 1900s and 00 to 68 in the 2000s.
+func newDate(year, month, day string) (time.Time, bool) {
+	y, err := strconv.Atoi(year)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if len(year) == 2 {
+		y += 1900
+		if y < 1969 {
+			y += 100
+		}
+	}
+	m, err := strconv.Atoi(month)
+	if err != nil || m < 1 || m > 12 {
+		return time.Time{}, false
+	}
+	d, err := strconv.Atoi(day)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Day() != d || t.Month() != time.Month(m) {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Format formats the date with the configured layout
+func (p *DateParser) Format(t time.Time) string {
+	return t.Format(p.layout)
+}
+
+// normalize returns the formatted date and its time, parsing text when t is not set.
+// Text that is not a date is returned as written.
+func (p *DateParser) normalize(text string, t time.Time) (string, time.Time) {
+	if t.IsZero() {
+		date, ok := p.Parse(text)
+		if !ok {
+			return text, time.Time{}
+		}
+		t = date.Time
+	}
+	return p.Format(t), t
+}
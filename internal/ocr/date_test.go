@@ -0,0 +1,127 @@
+package ocr
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateParser_Extract(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+		date     time.Time
+	}{
+		{
+			name:     "date at top",
+			text:     "Monday, January 1, 2024\nSome text here",
+			expected: "Monday, January 1, 2024",
+			date:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "date without day",
+			text:     "January 1, 2024\nSome text",
+			expected: "January 1, 2024",
+			date:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "date with slashes",
+			text:     "01/01/2024\nSome text",
+			expected: "01/01/2024",
+			date:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "no date",
+			text: "Some text without date",
+		},
+		{
+			name:     "date in second line",
+			text:     "\nMonday, January 1, 2024\nSome text",
+			expected: "Monday, January 1, 2024",
+			date:     time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date after the fifth line",
+			text: "1\n2\n3\n4\n5\nJanuary 1, 2024",
+		},
+	}
+
+	dates, err := NewDateParser(DateParserConfig{})
+	assert.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, ok := dates.Extract(tt.text)
+			assert.Equal(t, tt.expected != "", ok)
+			assert.Equal(t, tt.expected, date.Text)
+			assert.Equal(t, tt.date, date.Time)
+		})
+	}
+}
+
+func TestDateParser_Parse(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   DateParserConfig
+		text     string
+		expected string
+		date     string
+	}{
+		{name: "ordinal", text: "1st Jan 2024", expected: "1st Jan 2024", date: "2024-01-01"},
+		{name: "month first ordinal", text: "Tuesday, January 2nd, 2024", expected: "Tuesday, January 2nd, 2024", date: "2024-01-02"},
+		{name: "day of month", text: "the 3rd of March, 2024 was cold", expected: "3rd of March, 2024", date: "2024-03-03"},
+		{name: "abbreviation with period", text: "Sept. 9, 2024", expected: "Sept. 9, 2024", date: "2024-09-09"},
+		{name: "iso", text: "2024-01-01", expected: "2024-01-01", date: "2024-01-01"},
+		{name: "month first numeric", text: "01/02/2024", expected: "01/02/2024", date: "2024-01-02"},
+		{name: "day first numeric", config: DateParserConfig{Order: DateOrderDMY}, text: "01/02/2024", expected: "01/02/2024", date: "2024-02-01"},
+		{name: "impossible month falls back", text: "13/01/2024", expected: "13/01/2024", date: "2024-01-13"},
+		{name: "two digit year", text: "1-2-24", expected: "1-2-24", date: "2024-01-02"},
+		{name: "two digit year in the 1900s", text: "12/31/99", expected: "12/31/99", date: "1999-12-31"},
+		{name: "two digit year before the pivot", text: "1/1/68", expected: "1/1/68", date: "2068-01-01"},
+		{name: "two digit year after the pivot", text: "1/1/69", expected: "1/1/69", date: "1969-01-01"},
+		{name: "invalid day", text: "February 30, 2024"},
+		{name: "german", config: DateParserConfig{Locales: []string{"de"}}, text: "Sonntag, 3. März 2024", expected: "Sonntag, 3. März 2024", date: "2024-03-03"},
+		{name: "german abbreviation", config: DateParserConfig{Locales: []string{"de"}}, text: "3. mrz. 2024", expected: "3. mrz. 2024", date: "2024-03-03"},
+		{name: "french ordinal", config: DateParserConfig{Locales: []string{"fr"}}, text: "lundi 1er avril 2024", expected: "lundi 1er avril 2024", date: "2024-04-01"},
+		{name: "spanish", config: DateParserConfig{Locales: []string{"es"}}, text: "5 de mayo de 2024", expected: "5 de mayo de 2024", date: "2024-05-05"},
+		{name: "locale not configured", text: "3. März 2024"},
+		{name: "several locales", config: DateParserConfig{Locales: []string{"en", "de"}}, text: "3. März 2024", expected: "3. März 2024", date: "2024-03-03"},
+		{name: "earliest date wins", text: "January 5, 2024 (written 2024-01-07)", expected: "January 5, 2024", date: "2024-01-05"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates, err := NewDateParser(tt.config)
+			assert.NoError(t, err)
+
+			date, ok := dates.Parse(tt.text)
+			assert.Equal(t, tt.expected != "", ok)
+			assert.Equal(t, tt.expected, date.Text)
+			if ok {
+				assert.Equal(t, tt.date, date.Time.Format(time.DateOnly))
+			}
+		})
+	}
+}
+
+func TestDateParser_Format(t *testing.T) {
+	date := time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	dates, err := NewDateParser(DateParserConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-02", dates.Format(date))
+
+	dates, err = NewDateParser(DateParserConfig{Layout: "Monday, January 2, 2006"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Tuesday, January 2, 2024", dates.Format(date))
+}
+
+func TestNewDateParser_Errors(t *testing.T) {
+	_, err := NewDateParser(DateParserConfig{Locales: []string{"xx"}})
+	assert.ErrorIs(t, err, ErrUnknownDateLocale)
+
+	_, err = NewDateParser(DateParserConfig{Order: "ymd"})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
@@ -4,9 +4,11 @@ import "errors"
 
 // Application-level errors
 var (
-	ErrNoImagesFound     = errors.New("no images found in directory")
-	ErrInvalidConfig      = errors.New("invalid configuration")
+	ErrNoImagesFound        = errors.New("no images found in directory")
+	ErrInvalidConfig        = errors.New("invalid configuration")
 	ErrDateExtractionFailed = errors.New("failed to extract date from image")
-	ErrProcessingFailed   = errors.New("failed to process images")
+	ErrProcessingFailed     = errors.New("failed to process images")
+	ErrCheckpointFailed     = errors.New("failed to load checkpoints")
+	ErrUnreadableImage      = errors.New("failed to read image size")
+	ErrBudgetExceeded       = errors.New("not processed, the cost budget of the run was reached")
 )
-
@@ -0,0 +1,130 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"text/tabwriter"
+)
+
+// ImageEstimate is the predicted size, tokens and cost of sending a single image for OCR
+type ImageEstimate struct {
+	ImageName string
+	// Width and Height are the dimensions of the image after it is resized, or of its first tile when it is tiled
+	Width  int
+	Height int
+	// Tiles is the number of tiles the image is split into, or 0 when it is sent whole
+	Tiles int
+	// ImageTokens are the input tokens of the image under the tiling rules of the model
+	ImageTokens int
+	MinCost     float64
+	MaxCost     float64
+	Error       error
+}
+
+// EstimateResults contains the predicted tokens and cost of processing images
+type EstimateResults struct {
+	Images           []ImageEstimate
+	TotalImageTokens int
+	TotalMinCost     float64
+	TotalMaxCost     float64
+}
+
+func (r EstimateResults) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "image\tsize\timage tokens\tcost")
+	for _, estimate := range r.Images {
+		if estimate.Error != nil {
+			fmt.Fprintf(w, "%s\t\t\terror: %v\n", estimate.ImageName, estimate.Error)
+			continue
+		}
+		size := fmt.Sprintf("%dx%d", estimate.Width, estimate.Height)
+		if estimate.Tiles > 0 {
+			size += fmt.Sprintf(" x %d tiles", estimate.Tiles)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t$%.3f - $%.3f\n", estimate.ImageName, size, estimate.ImageTokens, estimate.MinCost, estimate.MaxCost)
+	}
+	w.Flush()
+
+	return buf.String() + fmt.Sprintf("\ntotal images:       %d\ntotal image tokens: %d\nprojected cost:     $%.3f - $%.3f\n",
+		len(r.Images), r.TotalImageTokens, r.TotalMinCost, r.TotalMaxCost)
+}
+
+// EstimateImages predicts the tokens and cost of processing the images, without sending any request to the OCR client.
+// Each image is loaded, split, resized and tiled as it would be for OCR. Images that can not be loaded are reported with their error.
+func (a *App) EstimateImages(ctx context.Context, estimator CostEstimator) (*EstimateResults, error) {
+	// Get image names (uses repository's base directory)
+	imageNames, err := a.repo.GetImageNames()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNoImagesFound, err)
+	} else if len(imageNames) == 0 {
+		return nil, ErrNoImagesFound
+	}
+
+	results := &EstimateResults{}
+	for i, imageName := range imageNames {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, estimate := range a.estimateImage(imageName, estimator) {
+			results.Images = append(results.Images, estimate)
+			results.TotalImageTokens += estimate.ImageTokens
+			results.TotalMinCost += estimate.MinCost
+			results.TotalMaxCost += estimate.MaxCost
+		}
+
+		if a.progressUpdater != nil {
+			a.progressUpdater.UpdateProgress(i+1, len(imageNames))
+		}
+	}
+
+	return results, nil
+}
+
+// estimateImage predicts the tokens and cost of a single image, or of each page of a spread when spreads are split
+func (a *App) estimateImage(imageName string, estimator CostEstimator) []ImageEstimate {
+	names, pages, err := a.loadPages(imageName)
+	if err != nil {
+		return []ImageEstimate{{ImageName: imageName, Error: err}}
+	}
+
+	estimates := make([]ImageEstimate, 0, len(pages))
+	for i, imageData := range pages {
+		estimates = append(estimates, a.estimatePage(names[i], imageData, estimator))
+	}
+	return estimates
+}
+
+// estimatePage predicts the tokens and cost of a single page
+func (a *App) estimatePage(imageName string, imageData []byte, estimator CostEstimator) ImageEstimate {
+	estimate := ImageEstimate{ImageName: imageName}
+
+	tiles, err := a.prepareImage(imageName, imageData)
+	if err != nil {
+		estimate.Error = err
+		return estimate
+	}
+
+	// Each tile of a tiled image is sent on its own
+	for i, tile := range tiles {
+		config, _, err := image.DecodeConfig(bytes.NewReader(tile))
+		if err != nil {
+			return ImageEstimate{ImageName: imageName, Error: fmt.Errorf("%w: %s", ErrUnreadableImage, strings.TrimPrefix(err.Error(), "image: "))}
+		}
+		if i == 0 {
+			estimate.Width, estimate.Height = config.Width, config.Height
+		}
+		imageTokens, minCost, maxCost := estimator.EstimateImage(config.Width, config.Height)
+		estimate.ImageTokens += imageTokens
+		estimate.MinCost += minCost
+		estimate.MaxCost += maxCost
+	}
+	if len(tiles) > 1 {
+		estimate.Tiles = len(tiles)
+	}
+	return estimate
+}
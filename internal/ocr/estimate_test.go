@@ -0,0 +1,101 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pngImage encodes a blank PNG image of the given size
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestApp_EstimateImages(t *testing.T) {
+	large, small := pngImage(t, 3000, 4000), pngImage(t, 300, 400)
+	resized := pngImage(t, 1125, 1500)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetImageNames").Return([]string{"Img-0001.png", "Img-0002.png", "Img-0003.png", "Img-0004.png"}, nil)
+	mockRepo.On("LoadImageByName", "Img-0001.png").Return(large, nil)
+	mockRepo.On("LoadImageByName", "Img-0002.png").Return(small, nil)
+	mockRepo.On("LoadImageByName", "Img-0003.png").Return([]byte("not an image"), nil)
+	mockRepo.On("LoadImageByName", "Img-0004.png").Return(nil, errors.New("permission denied"))
+
+	mockResizer := new(MockResizer)
+	mockResizer.On("ResizeImage", large, 1500).Return(resized, nil)
+	mockResizer.On("ResizeImage", small, 1500).Return(small, nil)
+	mockResizer.On("ResizeImage", []byte("not an image"), 1500).Return([]byte("not an image"), nil)
+
+	mockEstimator := new(MockCostEstimator)
+	mockEstimator.On("EstimateImage", 1125, 1500).Return(765, 0.01, 0.03)
+	mockEstimator.On("EstimateImage", 300, 400).Return(255, 0.005, 0.02)
+
+	// No OCR client is needed, so nothing can be sent
+	app := NewApp(nil, mockRepo, mockResizer, nil, nil, nil, &AppConfig{})
+
+	results, err := app.EstimateImages(context.Background(), mockEstimator)
+	assert.NoError(t, err)
+	assert.Len(t, results.Images, 4)
+	assert.Equal(t, ImageEstimate{ImageName: "Img-0001.png", Width: 1125, Height: 1500, ImageTokens: 765, MinCost: 0.01, MaxCost: 0.03}, results.Images[0])
+	assert.Equal(t, ImageEstimate{ImageName: "Img-0002.png", Width: 300, Height: 400, ImageTokens: 255, MinCost: 0.005, MaxCost: 0.02}, results.Images[1])
+	assert.ErrorIs(t, results.Images[2].Error, ErrUnreadableImage)
+	assert.EqualError(t, results.Images[3].Error, "permission denied")
+	assert.Equal(t, 1020, results.TotalImageTokens)
+	assert.InDelta(t, 0.015, results.TotalMinCost, 1e-9)
+	assert.InDelta(t, 0.05, results.TotalMaxCost, 1e-9)
+
+	output := results.String()
+	assert.Contains(t, output, "Img-0001.png  1125x1500  765")
+	assert.Contains(t, output, "projected cost:     $0.015 - $0.050")
+
+	mockRepo.AssertExpectations(t)
+	mockResizer.AssertExpectations(t)
+	mockEstimator.AssertExpectations(t)
+}
+
+func TestApp_EstimateImages_NoImages(t *testing.T) {
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetImageNames").Return([]string{}, nil)
+
+	app := NewApp(nil, mockRepo, new(MockResizer), nil, nil, nil, &AppConfig{})
+	_, err := app.EstimateImages(context.Background(), new(MockCostEstimator))
+	assert.ErrorIs(t, err, ErrNoImagesFound)
+}
+
+func TestApp_EstimateImages_Tiler(t *testing.T) {
+	page := pngImage(t, 1000, 3000)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("GetImageNames").Return([]string{"Img-0001.png"}, nil)
+	mockRepo.On("LoadImageByName", "Img-0001.png").Return(page, nil)
+
+	// Every tile is sent on its own, so the estimate is that of all tiles
+	mockTiler := new(MockTiler)
+	mockTiler.On("TileImage", page, 1500).Return([][]byte{pngImage(t, 1000, 1150), pngImage(t, 1000, 1150), pngImage(t, 1000, 1000)}, nil)
+
+	mockEstimator := new(MockCostEstimator)
+	mockEstimator.On("EstimateImage", 1000, 1150).Return(765, 0.01, 0.03)
+	mockEstimator.On("EstimateImage", 1000, 1000).Return(765, 0.01, 0.03)
+
+	app := NewApp(nil, mockRepo, new(MockResizer), nil, nil, nil, &AppConfig{Tiler: mockTiler})
+
+	results, err := app.EstimateImages(context.Background(), mockEstimator)
+	assert.NoError(t, err)
+	assert.Len(t, results.Images, 1)
+	assert.Equal(t, 1000, results.Images[0].Width)
+	assert.Equal(t, 1150, results.Images[0].Height)
+	assert.Equal(t, 3, results.Images[0].Tiles)
+	assert.Equal(t, 2295, results.Images[0].ImageTokens)
+	assert.InDelta(t, 0.03, results.Images[0].MinCost, 1e-9)
+	assert.InDelta(t, 0.09, results.Images[0].MaxCost, 1e-9)
+	assert.Contains(t, results.String(), "Img-0001.png  1000x1150 x 3 tiles  2295")
+}
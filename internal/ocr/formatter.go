@@ -0,0 +1,84 @@
+package ocr
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// UndatedOutputName is the output name of the pages before the first entry date
+const UndatedOutputName = "undated"
+
+// TextFormatter implements the OutputFormatter interface with the default plain text layout.
+// Each result starts with a horizontal rule, followed by the image name, the entry date and the transcript.
+type TextFormatter struct{}
+
+// Format formats the results into the plain text layout
+func (TextFormatter) Format(results []OCRResult, _ *ProcessImageResults) (string, error) {
+	var builder strings.Builder
+
+	for _, result := range results {
+		// Horizontal rule
+		builder.WriteString("---\n")
+
+		// Image name
+		builder.WriteString(result.ImageName)
+		builder.WriteString("\n")
+
+		// Pages skipped by the budget are marked, so they can be found and processed later
+		if errors.Is(result.Error, ErrBudgetExceeded) {
+			builder.WriteString("[NOT PROCESSED: cost budget reached]\n")
+			continue
+		}
+		if result.Error != nil {
+			builder.WriteString("Error: ")
+			builder.WriteString(result.Error.Error())
+			builder.WriteString("\n")
+			continue
+		}
+
+		// Date (extracted or carried forward)
+		if result.EntryDate != "" {
+			builder.WriteString(result.EntryDate)
+			builder.WriteString("\n")
+		}
+
+		// Transcript
+		builder.WriteString(result.Text)
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
+// assignEntryDates sets the entry date of each result to its extracted date,
+// or carries forward the last date found, starting with startDate.
+// Dates are normalized with the date parser, and dates it can not parse are kept as written.
+func assignEntryDates(results []OCRResult, startDate string, dates *DateParser) {
+	lastDate, lastTime := dates.normalize(startDate, time.Time{})
+	for i := range results {
+		if results[i].Error == nil && results[i].Date != "" {
+			lastDate, lastTime = dates.normalize(results[i].Date, results[i].ParsedDate)
+		}
+		results[i].EntryDate = lastDate
+		results[i].ParsedEntryDate = lastTime
+	}
+}
+
+// entryOutputName returns the output name of a result's entry date, e.g. "2024-01-01".
+// Entry dates that are not dates are turned into a name that is safe to use as a file name.
+func entryOutputName(result OCRResult) string {
+	if !result.ParsedEntryDate.IsZero() {
+		return result.ParsedEntryDate.Format(time.DateOnly)
+	}
+
+	// Replace every run of characters other than letters and digits with a dash
+	name := strings.Join(strings.FieldsFunc(strings.ToLower(result.EntryDate), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+	if name == "" {
+		return UndatedOutputName
+	}
+	return name
+}
@@ -0,0 +1,164 @@
+package formatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+// JSON implements the ocr.OutputFormatter interface as a single JSON document with the results and the summary
+type JSON struct{}
+
+// JSONL implements the ocr.OutputFormatter interface as one JSON object per result, one per line
+type JSONL struct{}
+
+var (
+	_ ocr.OutputFormatter = JSON{}
+	_ ocr.OutputFormatter = JSONL{}
+)
+
+// ErrFailedToFormat is returned when the results can not be encoded
+var ErrFailedToFormat = fmt.Errorf("failed to format output")
+
+// Result is the JSON representation of an ocr.OCRResult
+type Result struct {
+	ImageName         string    `json:"image_name"`
+	Date              string    `json:"date"`
+	EntryDate         string    `json:"entry_date"`
+	Text              string    `json:"text"`
+	PageNumber        string    `json:"page_number"`
+	IllegibleSegments []string  `json:"illegible_segments"`
+	Cost              float64   `json:"cost"`
+	OCRAttempts       int       `json:"ocr_attempts"`
+	Attempts          []Attempt `json:"attempts"`
+	DurationMS        int64     `json:"duration_ms"`
+	Error             *string   `json:"error"`
+	Resumed           bool      `json:"resumed"`
+	PromptPreset      string    `json:"prompt_preset"`
+	PromptHash        string    `json:"prompt_hash"`
+}
+
+// Attempt is the JSON representation of an ocr.Attempt
+type Attempt struct {
+	DelayMS    int64   `json:"delay_ms"`
+	DurationMS int64   `json:"duration_ms"`
+	Cost       float64 `json:"cost"`
+	Error      *string `json:"error"`
+	Refusal    string  `json:"refusal,omitempty"`
+	Strategy   string  `json:"strategy,omitempty"`
+}
+
+// Summary is the JSON representation of an ocr.ProcessImageResults
+type Summary struct {
+	TotalImagesProcessed int     `json:"total_images_processed"`
+	TotalImagesResumed   int     `json:"total_images_resumed"`
+	TotalImagesSkipped   int     `json:"total_images_skipped"`
+	TotalCost            float64 `json:"total_cost"`
+	CostPerImage         float64 `json:"cost_per_image"`
+	TotalOCRAttempts     int     `json:"total_ocr_attempts"`
+	OCRAttemptsPerImage  float64 `json:"ocr_attempts_per_image"`
+	TotalDurationMS      int64   `json:"total_duration_ms"`
+	DurationPerImageMS   int64   `json:"duration_per_image_ms"`
+	BudgetExceeded       bool    `json:"budget_exceeded"`
+}
+
+// Document is the JSON document written by the JSON formatter
+type Document struct {
+	Results []Result `json:"results"`
+	Summary *Summary `json:"summary,omitempty"`
+}
+
+// Format formats the results and the summary as an indented JSON document
+func (JSON) Format(results []ocr.OCRResult, summary *ocr.ProcessImageResults) (string, error) {
+	doc := Document{
+		Results: make([]Result, 0, len(results)),
+		Summary: newSummary(summary),
+	}
+	for _, result := range results {
+		doc.Results = append(doc.Results, newResult(result))
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFailedToFormat, err)
+	}
+	return string(data) + "\n", nil
+}
+
+// Format formats each result as a JSON object on its own line
+func (JSONL) Format(results []ocr.OCRResult, _ *ocr.ProcessImageResults) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, result := range results {
+		if err := encoder.Encode(newResult(result)); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrFailedToFormat, err)
+		}
+	}
+	return buf.String(), nil
+}
+
+// newResult converts an ocr.OCRResult into its JSON representation
+func newResult(result ocr.OCRResult) Result {
+	r := Result{
+		ImageName:   result.ImageName,
+		Date:        result.Date,
+		EntryDate:   result.EntryDate,
+		Text:        result.Text,
+		PageNumber:  result.PageNumber,
+		Cost:        result.Cost,
+		OCRAttempts: result.OCRAttempts,
+		DurationMS:  result.Duration.Milliseconds(),
+		Resumed:     result.Resumed,
+
+		IllegibleSegments: result.IllegibleSegments,
+		PromptPreset:      result.PromptPreset,
+		PromptHash:        result.PromptHash,
+	}
+	// Always write a list, so consumers do not have to check for null
+	if r.IllegibleSegments == nil {
+		r.IllegibleSegments = []string{}
+	}
+	r.Attempts = make([]Attempt, 0, len(result.Attempts))
+	for _, attempt := range result.Attempts {
+		r.Attempts = append(r.Attempts, Attempt{
+			DelayMS:    attempt.Delay.Milliseconds(),
+			DurationMS: attempt.Duration.Milliseconds(),
+			Cost:       attempt.Cost,
+			Error:      errorString(attempt.Error),
+			Refusal:    attempt.Refusal,
+			Strategy:   attempt.Strategy,
+		})
+	}
+	r.Error = errorString(result.Error)
+	return r
+}
+
+// errorString returns the message of err, or nil when there is no error
+func errorString(err error) *string {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	return &msg
+}
+
+// newSummary converts an ocr.ProcessImageResults into its JSON representation
+func newSummary(summary *ocr.ProcessImageResults) *Summary {
+	if summary == nil {
+		return nil
+	}
+	return &Summary{
+		TotalImagesProcessed: summary.TotalImagesProcessed,
+		TotalImagesResumed:   summary.TotalImagesResumed,
+		TotalImagesSkipped:   summary.TotalImagesSkipped,
+		TotalCost:            summary.TotalCost,
+		CostPerImage:         summary.CostPerImage,
+		TotalOCRAttempts:     summary.TotalOCRAttempts,
+		OCRAttemptsPerImage:  summary.OCRAttemptsPerImage,
+		TotalDurationMS:      summary.TotalDuration.Milliseconds(),
+		DurationPerImageMS:   summary.DurationPerImage.Milliseconds(),
+		BudgetExceeded:       summary.BudgetExceeded,
+	}
+}
@@ -0,0 +1,112 @@
+package formatter
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/stretchr/testify/assert"
+)
+
+var testResults = []ocr.OCRResult{
+	{
+		ImageName:   "Img-0001.jpg",
+		Date:        "January 1, 2024",
+		EntryDate:   "January 1, 2024",
+		Text:        "First page text",
+		PageNumber:  "1",
+		Cost:        0.01,
+		OCRAttempts: 1,
+		Duration:    1500 * time.Millisecond,
+
+		IllegibleSegments: []string{"[?]"},
+		PromptPreset:      "journal",
+		PromptHash:        "0123456789abcdef",
+	},
+	{
+		ImageName:   "Img-0002.jpg",
+		EntryDate:   "January 1, 2024",
+		OCRAttempts: 2,
+		Attempts: []ocr.Attempt{
+			{Duration: 800 * time.Millisecond, Cost: 0.01, Error: errors.New("model refused to process image"), Refusal: "I can't help with that."},
+			{Delay: 400 * time.Millisecond, Duration: 800 * time.Millisecond, Error: errors.New("API error (status 400): Image too large"), Strategy: "context"},
+		},
+		Duration: 2 * time.Second,
+		Error:    errors.New("max retries exceeded"),
+	},
+}
+
+func TestJSON_Format(t *testing.T) {
+	summary := &ocr.ProcessImageResults{
+		TotalImagesProcessed: 2,
+		TotalCost:            0.01,
+		TotalOCRAttempts:     6,
+		TotalDuration:        3500 * time.Millisecond,
+	}
+
+	output, err := JSON{}.Format(testResults, summary)
+	assert.NoError(t, err)
+
+	var doc Document
+	assert.NoError(t, json.Unmarshal([]byte(output), &doc))
+	assert.Len(t, doc.Results, 2)
+
+	first := doc.Results[0]
+	assert.Equal(t, "Img-0001.jpg", first.ImageName)
+	assert.Equal(t, "journal", first.PromptPreset)
+	assert.Equal(t, "0123456789abcdef", first.PromptHash)
+	assert.Equal(t, "January 1, 2024", first.Date)
+	assert.Equal(t, "First page text", first.Text)
+	assert.Equal(t, "1", first.PageNumber)
+	assert.Equal(t, []string{"[?]"}, first.IllegibleSegments)
+	assert.InDelta(t, 0.01, first.Cost, 0.0001)
+	assert.Equal(t, 1, first.OCRAttempts)
+	assert.Equal(t, int64(1500), first.DurationMS)
+	assert.Nil(t, first.Error)
+
+	second := doc.Results[1]
+	assert.Empty(t, second.Date)
+	assert.Equal(t, []string{}, second.IllegibleSegments)
+	assert.Equal(t, "January 1, 2024", second.EntryDate)
+	if assert.NotNil(t, second.Error) {
+		assert.Equal(t, "max retries exceeded", *second.Error)
+	}
+	assert.Empty(t, first.Attempts)
+	if assert.Len(t, second.Attempts, 2) {
+		assert.Equal(t, int64(400), second.Attempts[1].DelayMS)
+		assert.Equal(t, int64(800), second.Attempts[1].DurationMS)
+		assert.Equal(t, "I can't help with that.", second.Attempts[0].Refusal)
+		assert.Equal(t, "context", second.Attempts[1].Strategy)
+		if assert.NotNil(t, second.Attempts[0].Error) {
+			assert.Equal(t, "model refused to process image", *second.Attempts[0].Error)
+		}
+	}
+
+	if assert.NotNil(t, doc.Summary) {
+		assert.Equal(t, 2, doc.Summary.TotalImagesProcessed)
+		assert.Equal(t, 6, doc.Summary.TotalOCRAttempts)
+		assert.Equal(t, int64(3500), doc.Summary.TotalDurationMS)
+	}
+}
+
+func TestJSON_Format_Empty(t *testing.T) {
+	output, err := JSON{}.Format(nil, nil)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"results": []}`, output)
+}
+
+func TestJSONL_Format(t *testing.T) {
+	output, err := JSONL{}.Format(testResults, nil)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	assert.Len(t, lines, 2)
+	for i, line := range lines {
+		var result Result
+		assert.NoError(t, json.Unmarshal([]byte(line), &result))
+		assert.Equal(t, testResults[i].ImageName, result.ImageName)
+	}
+}
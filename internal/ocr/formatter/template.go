@@ -0,0 +1,115 @@
+package formatter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+const (
+	// EntryTemplateName is the template executed once per result
+	EntryTemplateName = "entry"
+	// DocumentTemplateName is the template executed once for the whole output
+	DocumentTemplateName = "document"
+)
+
+// defaultDocumentTemplate concatenates the entries when the template file does not define a document
+const defaultDocumentTemplate = `{{range .Entries}}{{template "entry" .}}{{end}}`
+
+// Template implements the ocr.OutputFormatter interface with a user defined text/template layout.
+//
+// The template file may define an "entry" template, which runs once per result, and a "document"
+// template, which runs once for the whole output. A file without either definition is used as the
+// entry template, and a missing document template concatenates the entries.
+type Template struct {
+	tmpl *template.Template
+}
+
+var _ ocr.OutputFormatter = (*Template)(nil)
+
+// ErrInvalidTemplate is returned when a template file can not be read or parsed
+var ErrInvalidTemplate = fmt.Errorf("invalid template")
+
+// TemplateEntry is the data passed to the entry template
+type TemplateEntry struct {
+	ocr.OCRResult
+	// Index is the 1-based position of the result in the output
+	Index int
+	// Summary contains the totals of the run
+	Summary *ocr.ProcessImageResults
+}
+
+// TemplateDocument is the data passed to the document template
+type TemplateDocument struct {
+	Entries []TemplateEntry
+	Summary *ocr.ProcessImageResults
+}
+
+// templateFuncs are the helper functions available to templates
+var templateFuncs = template.FuncMap{
+	"trim":    strings.TrimSpace,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"replace": strings.ReplaceAll,
+	"lines":   func(s string) []string { return strings.Split(s, "\n") },
+}
+
+// NewTemplate parses the template file at path
+func NewTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return ParseTemplate(filepath.Base(path), string(data))
+}
+
+// ParseTemplate parses the template text
+func ParseTemplate(name, text string) (*Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	// A file without definitions is the entry template
+	if tmpl.Lookup(EntryTemplateName) == nil && tmpl.Lookup(DocumentTemplateName) == nil {
+		if _, err := tmpl.New(EntryTemplateName).Parse(text); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	if tmpl.Lookup(DocumentTemplateName) == nil {
+		if tmpl.Lookup(EntryTemplateName) == nil {
+			return nil, fmt.Errorf("%w: %s must define an %q or %q template", ErrInvalidTemplate, name, EntryTemplateName, DocumentTemplateName)
+		}
+		if _, err := tmpl.New(DocumentTemplateName).Parse(defaultDocumentTemplate); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Format executes the document template with every result and the summary
+func (t *Template) Format(results []ocr.OCRResult, summary *ocr.ProcessImageResults) (string, error) {
+	doc := TemplateDocument{
+		Entries: make([]TemplateEntry, 0, len(results)),
+		Summary: summary,
+	}
+	for i, result := range results {
+		doc.Entries = append(doc.Entries, TemplateEntry{
+			OCRResult: result,
+			Index:     i + 1,
+			Summary:   summary,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := t.tmpl.ExecuteTemplate(&buf, DocumentTemplateName, doc); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFailedToFormat, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,75 @@
+package formatter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplate_Format(t *testing.T) {
+	summary := &ocr.ProcessImageResults{TotalImagesProcessed: 2, TotalCost: 0.01}
+
+	t.Run("entry only", func(t *testing.T) {
+		tmpl, err := ParseTemplate("entry.tmpl", "## {{.EntryDate}} ({{.ImageName}})\n{{if .Error}}Error: {{.Error}}{{else}}{{trim .Text}}{{end}}\n")
+		assert.NoError(t, err)
+
+		output, err := tmpl.Format(testResults, summary)
+		assert.NoError(t, err)
+		assert.Equal(t, "## January 1, 2024 (Img-0001.jpg)\nFirst page text\n## January 1, 2024 (Img-0002.jpg)\nError: max retries exceeded\n", output)
+	})
+
+	t.Run("entry and document", func(t *testing.T) {
+		tmpl, err := ParseTemplate("journal.tmpl", `{{define "entry"}}* {{.Index}}. {{upper .ImageName}}
+{{end}}{{define "document"}}#+TITLE: Journal
+{{range .Entries}}{{template "entry" .}}{{end}}{{printf "%d pages, $%.2f" .Summary.TotalImagesProcessed .Summary.TotalCost}}
+{{end}}`)
+		assert.NoError(t, err)
+
+		output, err := tmpl.Format(testResults, summary)
+		assert.NoError(t, err)
+		assert.Equal(t, "#+TITLE: Journal\n* 1. IMG-0001.JPG\n* 2. IMG-0002.JPG\n2 pages, $0.01\n", output)
+	})
+
+	t.Run("entries see the summary", func(t *testing.T) {
+		tmpl, err := ParseTemplate("summary.tmpl", "{{.Index}}/{{.Summary.TotalImagesProcessed}}\n")
+		assert.NoError(t, err)
+
+		output, err := tmpl.Format(testResults, summary)
+		assert.NoError(t, err)
+		assert.Equal(t, "1/2\n2/2\n", output)
+	})
+}
+
+func TestTemplate_Errors(t *testing.T) {
+	t.Run("parse error", func(t *testing.T) {
+		_, err := ParseTemplate("bad.tmpl", "{{.ImageName")
+		assert.ErrorIs(t, err, ErrInvalidTemplate)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := NewTemplate(filepath.Join(t.TempDir(), "missing.tmpl"))
+		assert.ErrorIs(t, err, ErrInvalidTemplate)
+	})
+
+	t.Run("execution error", func(t *testing.T) {
+		tmpl, err := ParseTemplate("unknown.tmpl", "{{.Unknown}}")
+		assert.NoError(t, err)
+		_, err = tmpl.Format(testResults, nil)
+		assert.ErrorIs(t, err, ErrFailedToFormat)
+	})
+}
+
+func TestNewTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entry.tmpl")
+	assert.NoError(t, os.WriteFile(path, []byte("{{.ImageName}}\n"), 0644))
+
+	tmpl, err := NewTemplate(path)
+	assert.NoError(t, err)
+
+	output, err := tmpl.Format(testResults, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Img-0001.jpg\nImg-0002.jpg\n", output)
+}
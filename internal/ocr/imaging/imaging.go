@@ -0,0 +1,115 @@
+// Package imaging holds the image helpers shared by the adapters that read and analyze pages: grayscale
+// conversion, downsampling, thresholding and turning images upright.
+package imaging
+
+import (
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+)
+
+// Gray converts an image to grayscale, with its origin at 0,0. A grayscale image that already is one is
+// returned as it is.
+func Gray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok && gray.Rect.Min == (image.Point{}) && gray.Stride == gray.Rect.Dx() {
+		return gray
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			gray.Pix[y*gray.Stride+x] = color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return gray
+}
+
+// Sample returns a grayscale copy of an image scaled down so its longest side is at most size.
+// Smaller images are copied at their own size.
+func Sample(img image.Image, size int) *image.Gray {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if scale := float64(size) / float64(max(w, h, 1)); scale < 1 {
+		w, h = max(1, int(float64(w)*scale)), max(1, int(float64(h)*scale))
+	}
+	gray := image.NewGray(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(gray, gray.Bounds(), img, bounds, draw.Src, nil)
+	return gray
+}
+
+// OtsuThreshold returns the gray level that best separates the dark and the light pixels with Otsu's method.
+// Pixels below the threshold are dark.
+func OtsuThreshold(pix []uint8) uint8 {
+	var histogram [256]int
+	for _, v := range pix {
+		histogram[v]++
+	}
+	total := len(pix)
+	sum := 0
+	for i, n := range histogram {
+		sum += i * n
+	}
+
+	var best float64
+	threshold, darkCount, darkSum := 0, 0, 0
+	for i, n := range histogram {
+		darkCount += n
+		darkSum += i * n
+		lightCount := total - darkCount
+		if darkCount == 0 || lightCount == 0 {
+			continue
+		}
+		darkMean := float64(darkSum) / float64(darkCount)
+		lightMean := float64(sum-darkSum) / float64(lightCount)
+		variance := float64(darkCount) * float64(lightCount) * (darkMean - lightMean) * (darkMean - lightMean)
+		if variance > best {
+			best, threshold = variance, i
+		}
+	}
+	return uint8(threshold + 1)
+}
+
+// Rotate turns an image clockwise by 90, 180 or 270 degrees. Other angles leave it as it is.
+func Rotate(img image.Image, degrees int) image.Image {
+	switch degrees {
+	case 90:
+		return Transform(img, true, true, false)
+	case 180:
+		return Transform(img, false, true, true)
+	case 270:
+		return Transform(img, true, false, true)
+	default:
+		return img
+	}
+}
+
+// Transform transposes an image across its main diagonal when transpose is set,
+// then mirrors it left to right and top to bottom as requested
+func Transform(img image.Image, transpose, flipX, flipY bool) image.Image {
+	src := image.NewRGBA(img.Bounds())
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+
+	dw, dh := w, h
+	if transpose {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			if transpose {
+				dx, dy = y, x
+			}
+			if flipX {
+				dx = dw - 1 - dx
+			}
+			if flipY {
+				dy = dh - 1 - dy
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):][:4], src.Pix[src.PixOffset(x, y):][:4])
+		}
+	}
+	return dst
+}
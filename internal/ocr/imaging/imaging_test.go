@@ -0,0 +1,39 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGray(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 20, 12, 21))
+	img.Set(10, 20, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	img.Set(11, 20, color.RGBA{A: 255})
+
+	gray := Gray(img)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), gray.Rect)
+	assert.Equal(t, []uint8{255, 0}, gray.Pix)
+
+	// A grayscale image at the origin is not copied
+	assert.Same(t, gray, Gray(gray))
+}
+
+func TestSample(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 1000, 500))
+	assert.Equal(t, image.Rect(0, 0, 100, 50), Sample(img, 100).Rect)
+	assert.Equal(t, image.Rect(0, 0, 50, 100), Sample(image.NewGray(image.Rect(0, 0, 500, 1000)), 100).Rect)
+
+	// Small images are not scaled up
+	assert.Equal(t, image.Rect(0, 0, 1000, 500), Sample(img, 2000).Rect)
+}
+
+func TestOtsuThreshold(t *testing.T) {
+	// Ink around 40 on paper around 200 is split between the two
+	pix := []uint8{30, 40, 50, 190, 200, 210, 200, 190}
+	threshold := OtsuThreshold(pix)
+	assert.Greater(t, threshold, uint8(50))
+	assert.LessOrEqual(t, threshold, uint8(190))
+}
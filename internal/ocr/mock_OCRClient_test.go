@@ -21,41 +21,89 @@ func (_m *MockOCRClient) EXPECT() *MockOCRClient_Expecter {
 	return &MockOCRClient_Expecter{mock: &_m.Mock}
 }
 
-// OCRImage provides a mock function with given fields: ctx, imageData
-func (_m *MockOCRClient) OCRImage(ctx context.Context, imageData []byte) (string, float64, int, error) {
-	ret := _m.Called(ctx, imageData)
+// Fingerprint provides a mock function with given fields: page
+func (_m *MockOCRClient) Fingerprint(page PageInfo) string {
+	ret := _m.Called(page)
 
 	if len(ret) == 0 {
-		panic("no return value specified for OCRImage")
+		panic("no return value specified for Fingerprint")
 	}
 
 	var r0 string
+	if rf, ok := ret.Get(0).(func(PageInfo) string); ok {
+		r0 = rf(page)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	return r0
+}
+
+// MockOCRClient_Fingerprint_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Fingerprint'
+type MockOCRClient_Fingerprint_Call struct {
+	*mock.Call
+}
+
+// Fingerprint is a helper method to define mock.On call
+//   - page PageInfo
+func (_e *MockOCRClient_Expecter) Fingerprint(page interface{}) *MockOCRClient_Fingerprint_Call {
+	return &MockOCRClient_Fingerprint_Call{Call: _e.mock.On("Fingerprint", page)}
+}
+
+func (_c *MockOCRClient_Fingerprint_Call) Run(run func(page PageInfo)) *MockOCRClient_Fingerprint_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(PageInfo))
+	})
+	return _c
+}
+
+func (_c *MockOCRClient_Fingerprint_Call) Return(_a0 string) *MockOCRClient_Fingerprint_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockOCRClient_Fingerprint_Call) RunAndReturn(run func(PageInfo) string) *MockOCRClient_Fingerprint_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// OCRImage provides a mock function with given fields: ctx, imageData, page
+func (_m *MockOCRClient) OCRImage(ctx context.Context, imageData []byte, page PageInfo) (Transcription, float64, []Attempt, error) {
+	ret := _m.Called(ctx, imageData, page)
+
+	if len(ret) == 0 {
+		panic("no return value specified for OCRImage")
+	}
+
+	var r0 Transcription
 	var r1 float64
-	var r2 int
+	var r2 []Attempt
 	var r3 error
-	if rf, ok := ret.Get(0).(func(context.Context, []byte) (string, float64, int, error)); ok {
-		return rf(ctx, imageData)
+	if rf, ok := ret.Get(0).(func(context.Context, []byte, PageInfo) (Transcription, float64, []Attempt, error)); ok {
+		return rf(ctx, imageData, page)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, []byte) string); ok {
-		r0 = rf(ctx, imageData)
+	if rf, ok := ret.Get(0).(func(context.Context, []byte, PageInfo) Transcription); ok {
+		r0 = rf(ctx, imageData, page)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(Transcription)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, []byte) float64); ok {
-		r1 = rf(ctx, imageData)
+	if rf, ok := ret.Get(1).(func(context.Context, []byte, PageInfo) float64); ok {
+		r1 = rf(ctx, imageData, page)
 	} else {
 		r1 = ret.Get(1).(float64)
 	}
 
-	if rf, ok := ret.Get(2).(func(context.Context, []byte) int); ok {
-		r2 = rf(ctx, imageData)
+	if rf, ok := ret.Get(2).(func(context.Context, []byte, PageInfo) []Attempt); ok {
+		r2 = rf(ctx, imageData, page)
 	} else {
-		r2 = ret.Get(2).(int)
+		if ret.Get(2) != nil {
+			r2 = ret.Get(2).([]Attempt)
+		}
 	}
 
-	if rf, ok := ret.Get(3).(func(context.Context, []byte) error); ok {
-		r3 = rf(ctx, imageData)
+	if rf, ok := ret.Get(3).(func(context.Context, []byte, PageInfo) error); ok {
+		r3 = rf(ctx, imageData, page)
 	} else {
 		r3 = ret.Error(3)
 	}
@@ -71,23 +119,24 @@ type MockOCRClient_OCRImage_Call struct {
 // OCRImage is a helper method to define mock.On call
 //   - ctx context.Context
 //   - imageData []byte
-func (_e *MockOCRClient_Expecter) OCRImage(ctx interface{}, imageData interface{}) *MockOCRClient_OCRImage_Call {
-	return &MockOCRClient_OCRImage_Call{Call: _e.mock.On("OCRImage", ctx, imageData)}
+//   - page PageInfo
+func (_e *MockOCRClient_Expecter) OCRImage(ctx interface{}, imageData interface{}, page interface{}) *MockOCRClient_OCRImage_Call {
+	return &MockOCRClient_OCRImage_Call{Call: _e.mock.On("OCRImage", ctx, imageData, page)}
 }
 
-func (_c *MockOCRClient_OCRImage_Call) Run(run func(ctx context.Context, imageData []byte)) *MockOCRClient_OCRImage_Call {
+func (_c *MockOCRClient_OCRImage_Call) Run(run func(ctx context.Context, imageData []byte, page PageInfo)) *MockOCRClient_OCRImage_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(context.Context), args[1].([]byte))
+		run(args[0].(context.Context), args[1].([]byte), args[2].(PageInfo))
 	})
 	return _c
 }
 
-func (_c *MockOCRClient_OCRImage_Call) Return(text string, cost float64, attempts int, err error) *MockOCRClient_OCRImage_Call {
-	_c.Call.Return(text, cost, attempts, err)
+func (_c *MockOCRClient_OCRImage_Call) Return(transcription Transcription, cost float64, attempts []Attempt, err error) *MockOCRClient_OCRImage_Call {
+	_c.Call.Return(transcription, cost, attempts, err)
 	return _c
 }
 
-func (_c *MockOCRClient_OCRImage_Call) RunAndReturn(run func(context.Context, []byte) (string, float64, int, error)) *MockOCRClient_OCRImage_Call {
+func (_c *MockOCRClient_OCRImage_Call) RunAndReturn(run func(context.Context, []byte, PageInfo) (Transcription, float64, []Attempt, error)) *MockOCRClient_OCRImage_Call {
 	_c.Call.Return(run)
 	return _c
 }
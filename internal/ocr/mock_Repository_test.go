@@ -132,6 +132,100 @@ func (_c *MockRepository_LoadImageByName_Call) RunAndReturn(run func(string) ([]
 	return _c
 }
 
+// SaveDirectoryOutput provides a mock function with given fields: dir, content
+func (_m *MockRepository) SaveDirectoryOutput(dir string, content string) error {
+	ret := _m.Called(dir, content)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveDirectoryOutput")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(dir, content)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_SaveDirectoryOutput_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveDirectoryOutput'
+type MockRepository_SaveDirectoryOutput_Call struct {
+	*mock.Call
+}
+
+// SaveDirectoryOutput is a helper method to define mock.On call
+//   - dir string
+//   - content string
+func (_e *MockRepository_Expecter) SaveDirectoryOutput(dir interface{}, content interface{}) *MockRepository_SaveDirectoryOutput_Call {
+	return &MockRepository_SaveDirectoryOutput_Call{Call: _e.mock.On("SaveDirectoryOutput", dir, content)}
+}
+
+func (_c *MockRepository_SaveDirectoryOutput_Call) Run(run func(dir string, content string)) *MockRepository_SaveDirectoryOutput_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_SaveDirectoryOutput_Call) Return(_a0 error) *MockRepository_SaveDirectoryOutput_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_SaveDirectoryOutput_Call) RunAndReturn(run func(string, string) error) *MockRepository_SaveDirectoryOutput_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SaveDirectoryOutputs provides a mock function with given fields: dir, outputs
+func (_m *MockRepository) SaveDirectoryOutputs(dir string, outputs map[string]string) error {
+	ret := _m.Called(dir, outputs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveDirectoryOutputs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, map[string]string) error); ok {
+		r0 = rf(dir, outputs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_SaveDirectoryOutputs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveDirectoryOutputs'
+type MockRepository_SaveDirectoryOutputs_Call struct {
+	*mock.Call
+}
+
+// SaveDirectoryOutputs is a helper method to define mock.On call
+//   - dir string
+//   - outputs map[string]string
+func (_e *MockRepository_Expecter) SaveDirectoryOutputs(dir interface{}, outputs interface{}) *MockRepository_SaveDirectoryOutputs_Call {
+	return &MockRepository_SaveDirectoryOutputs_Call{Call: _e.mock.On("SaveDirectoryOutputs", dir, outputs)}
+}
+
+func (_c *MockRepository_SaveDirectoryOutputs_Call) Run(run func(dir string, outputs map[string]string)) *MockRepository_SaveDirectoryOutputs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(string), args[1].(map[string]string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_SaveDirectoryOutputs_Call) Return(_a0 error) *MockRepository_SaveDirectoryOutputs_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_SaveDirectoryOutputs_Call) RunAndReturn(run func(string, map[string]string) error) *MockRepository_SaveDirectoryOutputs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // SaveOutput provides a mock function with given fields: content
 func (_m *MockRepository) SaveOutput(content string) error {
 	ret := _m.Called(content)
@@ -178,6 +272,52 @@ func (_c *MockRepository_SaveOutput_Call) RunAndReturn(run func(string) error) *
 	return _c
 }
 
+// SaveOutputs provides a mock function with given fields: outputs
+func (_m *MockRepository) SaveOutputs(outputs map[string]string) error {
+	ret := _m.Called(outputs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveOutputs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(map[string]string) error); ok {
+		r0 = rf(outputs)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MockRepository_SaveOutputs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveOutputs'
+type MockRepository_SaveOutputs_Call struct {
+	*mock.Call
+}
+
+// SaveOutputs is a helper method to define mock.On call
+//   - outputs map[string]string
+func (_e *MockRepository_Expecter) SaveOutputs(outputs interface{}) *MockRepository_SaveOutputs_Call {
+	return &MockRepository_SaveOutputs_Call{Call: _e.mock.On("SaveOutputs", outputs)}
+}
+
+func (_c *MockRepository_SaveOutputs_Call) Run(run func(outputs map[string]string)) *MockRepository_SaveOutputs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run(args[0].(map[string]string))
+	})
+	return _c
+}
+
+func (_c *MockRepository_SaveOutputs_Call) Return(_a0 error) *MockRepository_SaveOutputs_Call {
+	_c.Call.Return(_a0)
+	return _c
+}
+
+func (_c *MockRepository_SaveOutputs_Call) RunAndReturn(run func(map[string]string) error) *MockRepository_SaveOutputs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockRepository creates a new instance of MockRepository. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockRepository(t interface {
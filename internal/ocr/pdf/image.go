@@ -0,0 +1,454 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/ccitt"
+)
+
+// filters returns the names and parameters of the filters of a stream in the order they are applied
+func (d *Document) filters(dict Dict) ([]Name, []Dict) {
+	var names []Name
+	var parms []Dict
+	switch f := d.resolve(dict["Filter"]).(type) {
+	case Name:
+		names = []Name{f}
+	case []any:
+		for _, v := range f {
+			name, _ := d.resolve(v).(Name)
+			names = append(names, name)
+		}
+	}
+	switch p := d.resolve(dict["DecodeParms"]).(type) {
+	case Dict:
+		parms = []Dict{p}
+	case []any:
+		for _, v := range p {
+			parm, _ := d.resolve(v).(Dict)
+			parms = append(parms, parm)
+		}
+	}
+	for len(parms) < len(names) {
+		parms = append(parms, nil)
+	}
+	return names, parms
+}
+
+// maxStreamSize caps the decoded size of a stream that is not an image, like an object stream or a palette,
+// so a small compressed stream can not inflate into more memory than any scanner would write
+const maxStreamSize = 64 << 20
+
+// decodeStream returns the decoded data of a stream that only uses the Flate filter
+func (d *Document) decodeStream(stream *Stream) ([]byte, error) {
+	data := stream.Data
+	names, parms := d.filters(stream.Dict)
+	for i, name := range names {
+		if name != "FlateDecode" && name != "Fl" {
+			return nil, fmt.Errorf("unsupported filter %s", name)
+		}
+		var err error
+		if data, err = d.inflate(data, parms[i], maxStreamSize); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// decodeImage returns an image XObject as a JPEG, or as a PNG when it is not stored as one
+func (d *Document) decodeImage(img *Stream) ([]byte, error) {
+	data := img.Data
+	names, parms := d.filters(img.Dict)
+	for i, name := range names {
+		last := i == len(names)-1
+		switch name {
+		case "FlateDecode", "Fl":
+			// Samples never take more than their size, and data for another filter is capped like any stream
+			limit := int64(maxStreamSize)
+			if last {
+				limit = d.sampleSize(img.Dict)
+			}
+			var err error
+			if data, err = d.inflate(data, parms[i], limit); err != nil {
+				return nil, err
+			}
+		case "DCTDecode", "DCT":
+			if !last {
+				return nil, fmt.Errorf("filter %s must be the last one", name)
+			}
+			return data, nil
+		case "CCITTFaxDecode", "CCF":
+			if !last {
+				return nil, fmt.Errorf("filter %s must be the last one", name)
+			}
+			gray, err := d.decodeFax(data, parms[i], img.Dict)
+			if err != nil {
+				return nil, err
+			}
+			return encodePNG(gray)
+		default:
+			return nil, fmt.Errorf("unsupported filter %s", name)
+		}
+	}
+
+	samples, err := d.decodeSamples(data, img.Dict)
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(samples)
+}
+
+// sampleSize returns the size of the samples of an image from its width, height, components and bits per
+// component, with a byte more per row for the filter type of a PNG predictor
+func (d *Document) sampleSize(dict Dict) int64 {
+	width := int64(min(max(d.intOr(dict["Width"], 0), 0), maxImageDimension))
+	height := int64(min(max(d.intOr(dict["Height"], 0), 0), maxImageDimension))
+	bpc := int64(min(max(d.intOr(dict["BitsPerComponent"], 8), 1), 16))
+	if dict["ImageMask"] == true {
+		bpc = 1
+	}
+	components, _, err := d.colorSpace(dict["ColorSpace"], dict["ImageMask"] == true)
+	if err != nil {
+		// The samples are rejected with the error of the color space once they are inflated
+		components = 4
+	}
+	return height * ((width*int64(components)*bpc+7)/8 + 1)
+}
+
+// inflate decompresses Flate data and undoes its PNG predictor. Data that inflates to more than limit bytes
+// is an error, so a small stream can not exhaust memory.
+func (d *Document) inflate(data []byte, parms Dict, limit int64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("flate: %v", err)
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("flate: %v", err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("flate: data inflates to more than the %d bytes expected", limit)
+	}
+
+	predictor := d.intOr(parms["Predictor"], 1)
+	switch {
+	case predictor == 1:
+		return out, nil
+	case predictor >= 10:
+		colors := d.intOr(parms["Colors"], 1)
+		bpc := d.intOr(parms["BitsPerComponent"], 8)
+		columns := d.intOr(parms["Columns"], 1)
+		return unpredictPNG(out, colors, bpc, columns)
+	default:
+		return nil, fmt.Errorf("flate: unsupported predictor %d", predictor)
+	}
+}
+
+// unpredictPNG undoes the PNG filter of each row, which is written before the row as a single byte
+func unpredictPNG(data []byte, colors, bpc, columns int) ([]byte, error) {
+	if colors < 1 || bpc < 1 || columns < 1 {
+		return nil, fmt.Errorf("flate: invalid predictor parameters")
+	}
+	bpp := max(1, colors*bpc/8)
+	rowLen := (colors*bpc*columns + 7) / 8
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for len(data) > 0 {
+		if len(data) < rowLen+1 {
+			break
+		}
+		filter, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("flate: invalid PNG filter %d", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+// paeth predicts a byte from its neighbors as described in the PNG specification
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// maxImageDimension caps the width and height an image is allowed to inflate to, far larger than any scan
+const maxImageDimension = 1 << 16
+
+// maxFaxDimension caps the width and height of a fax image, which are allocated before the data is decoded.
+// It is more than an A3 page at 1200 dpi.
+const maxFaxDimension = 20000
+
+// decodeFax decodes a CCITT Group 3 or Group 4 fax image, which is how most scanners store black and white pages
+func (d *Document) decodeFax(data []byte, parms Dict, dict Dict) (image.Image, error) {
+	k := d.intOr(parms["K"], 0)
+	if k > 0 {
+		return nil, fmt.Errorf("ccitt: two-dimensional Group 3 encoding is not supported")
+	}
+	subFormat := ccitt.Group3
+	if k < 0 {
+		subFormat = ccitt.Group4
+	}
+	width := d.intOr(parms["Columns"], 1728)
+	height := d.intOr(parms["Rows"], 0)
+	if height <= 0 {
+		height = d.intOr(dict["Height"], 0)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("ccitt: invalid size %dx%d", width, height)
+	}
+	// Every row takes at least one bit, so data that is too short for the rows is not a page of that size
+	if width > maxFaxDimension || height > maxFaxDimension || height > 8*len(data) {
+		return nil, fmt.Errorf("ccitt: size %dx%d is too large for %d bytes of data", width, height, len(data))
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, width, height))
+	opts := &ccitt.Options{Align: parms["EncodedByteAlign"] == true}
+	if err := ccitt.DecodeIntoGray(gray, bytes.NewReader(data), ccitt.MSB, subFormat, opts); err != nil {
+		return nil, fmt.Errorf("ccitt: %v", err)
+	}
+	// Black is 0 in the decoded image. BlackIs1 and a [1 0] decode array each swap black and white.
+	if (parms["BlackIs1"] == true) != d.invertedDecode(dict) {
+		for i := range gray.Pix {
+			gray.Pix[i] = 255 - gray.Pix[i]
+		}
+	}
+	return gray, nil
+}
+
+// decodeSamples builds an image from uncompressed samples in a gray, RGB, CMYK or indexed color space
+func (d *Document) decodeSamples(data []byte, dict Dict) (image.Image, error) {
+	width := d.intOr(dict["Width"], 0)
+	height := d.intOr(dict["Height"], 0)
+	bpc := d.intOr(dict["BitsPerComponent"], 8)
+	if dict["ImageMask"] == true {
+		bpc = 1
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", width, height)
+	}
+	if bpc != 1 && bpc != 2 && bpc != 4 && bpc != 8 {
+		return nil, fmt.Errorf("unsupported %d bits per component", bpc)
+	}
+
+	components, palette, err := d.colorSpace(dict["ColorSpace"], dict["ImageMask"] == true)
+	if err != nil {
+		return nil, err
+	}
+	rowLen := (width*components*bpc + 7) / 8
+	if len(data) < rowLen*height {
+		return nil, fmt.Errorf("image data is %d bytes, expected %d", len(data), rowLen*height)
+	}
+
+	maxValue := 1<<bpc - 1
+	sample := func(row []byte, i int) int {
+		bit := i * bpc
+		return int(row[bit/8]>>(8-bpc-bit%8)) & maxValue
+	}
+	scale := func(v int) uint8 {
+		return uint8(v * 255 / maxValue)
+	}
+	invert := d.invertedDecode(dict)
+
+	rect := image.Rect(0, 0, width, height)
+	switch {
+	case palette != nil:
+		img := image.NewPaletted(rect, palette)
+		for y := 0; y < height; y++ {
+			row := data[y*rowLen:]
+			for x := 0; x < width; x++ {
+				img.Pix[y*img.Stride+x] = uint8(min(sample(row, x), len(palette)-1))
+			}
+		}
+		return img, nil
+	case components == 1:
+		img := image.NewGray(rect)
+		for y := 0; y < height; y++ {
+			row := data[y*rowLen:]
+			for x := 0; x < width; x++ {
+				v := scale(sample(row, x))
+				if invert {
+					v = 255 - v
+				}
+				img.Pix[y*img.Stride+x] = v
+			}
+		}
+		return img, nil
+	case components == 3:
+		img := image.NewRGBA(rect)
+		for y := 0; y < height; y++ {
+			row := data[y*rowLen:]
+			for x := 0; x < width; x++ {
+				i := y*img.Stride + x*4
+				for c := 0; c < 3; c++ {
+					img.Pix[i+c] = scale(sample(row, x*3+c))
+				}
+				img.Pix[i+3] = 255
+			}
+		}
+		return img, nil
+	default:
+		img := image.NewCMYK(rect)
+		for y := 0; y < height; y++ {
+			row := data[y*rowLen:]
+			for x := 0; x < width*4; x++ {
+				img.Pix[y*img.Stride+x] = scale(sample(row, x))
+			}
+		}
+		return img, nil
+	}
+}
+
+// colorSpace returns the number of components of a color space, and the palette of an indexed one
+func (d *Document) colorSpace(v any, imageMask bool) (int, color.Palette, error) {
+	if imageMask {
+		return 1, nil, nil
+	}
+	cs := d.resolve(v)
+	if array, ok := cs.([]any); ok && len(array) > 0 {
+		family, _ := d.resolve(array[0]).(Name)
+		switch family {
+		case "ICCBased":
+			if len(array) > 1 {
+				if profile, ok := d.resolve(array[1]).(*Stream); ok {
+					if n := d.intOr(profile.Dict["N"], 0); n == 1 || n == 3 || n == 4 {
+						return n, nil, nil
+					}
+				}
+			}
+			return 0, nil, fmt.Errorf("invalid ICC based color space")
+		case "Indexed", "I":
+			palette, err := d.palette(array)
+			return 1, palette, err
+		case "CalGray", "CalRGB":
+			return d.colorSpace(family, false)
+		}
+		return 0, nil, fmt.Errorf("unsupported color space %s", family)
+	}
+
+	switch name, _ := cs.(Name); name {
+	case "DeviceGray", "CalGray", "G":
+		return 1, nil, nil
+	case "DeviceRGB", "CalRGB", "RGB":
+		return 3, nil, nil
+	case "DeviceCMYK", "CMYK":
+		return 4, nil, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported color space %v", cs)
+	}
+}
+
+// palette returns the colors of an indexed color space, [/Indexed base hival lookup]
+func (d *Document) palette(array []any) (color.Palette, error) {
+	if len(array) != 4 {
+		return nil, fmt.Errorf("invalid indexed color space")
+	}
+	components, _, err := d.colorSpace(array[1], false)
+	if err != nil {
+		return nil, err
+	}
+	hival := d.intOr(array[2], -1)
+	var lookup []byte
+	switch l := d.resolve(array[3]).(type) {
+	case string:
+		lookup = []byte(l)
+	case *Stream:
+		if lookup, err = d.decodeStream(l); err != nil {
+			return nil, err
+		}
+	}
+	if hival < 0 || hival > 255 || len(lookup) < (hival+1)*components {
+		return nil, fmt.Errorf("invalid indexed color space")
+	}
+
+	palette := make(color.Palette, hival+1)
+	for i := range palette {
+		c := lookup[i*components : (i+1)*components]
+		switch components {
+		case 1:
+			palette[i] = color.Gray{Y: c[0]}
+		case 3:
+			palette[i] = color.RGBA{R: c[0], G: c[1], B: c[2], A: 255}
+		default:
+			palette[i] = color.CMYK{C: c[0], M: c[1], Y: c[2], K: c[3]}
+		}
+	}
+	return palette, nil
+}
+
+// invertedDecode reports whether the decode array of an image maps its samples the other way around, e.g. [1 0]
+func (d *Document) invertedDecode(dict Dict) bool {
+	decode, ok := d.resolve(dict["Decode"]).([]any)
+	if !ok || len(decode) < 2 {
+		return false
+	}
+	return d.number(decode[0]) > d.number(decode[1])
+}
+
+// intOr returns v as an integer, or def when it is not one
+func (d *Document) intOr(v any, def int) int {
+	if n, ok := d.resolve(v).(int); ok {
+		return n
+	}
+	return def
+}
+
+// number returns v as a float, or 0 when it is not a number
+func (d *Document) number(v any) float64 {
+	switch n := d.resolve(v).(type) {
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// encodePNG encodes an extracted image
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
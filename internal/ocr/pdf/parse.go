@@ -0,0 +1,323 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Name is a PDF name object, e.g. /Type, without its slash
+type Name string
+
+// Dict is a PDF dictionary
+type Dict map[Name]any
+
+// Ref is a reference to an indirect object, e.g. 12 0 R
+type Ref struct {
+	Num int
+	Gen int
+}
+
+// Stream is a stream object with its encoded data
+type Stream struct {
+	Dict Dict
+	Data []byte
+}
+
+// keyword is a bare word such as obj, endobj or stream
+type keyword string
+
+// parser reads PDF objects from data, starting at pos
+type parser struct {
+	data []byte
+	pos  int
+}
+
+// isSpace reports whether c is PDF white space
+func isSpace(c byte) bool {
+	switch c {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+// isDelim reports whether c ends a name, number or keyword
+func isDelim(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return isSpace(c)
+}
+
+// skipSpace skips white space and comments
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case isSpace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// word reads the characters up to the next delimiter
+func (p *parser) word() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isDelim(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// object reads the next object. Keywords are returned as a keyword.
+func (p *parser) object() (any, error) {
+	return p.value(0)
+}
+
+// value reads the next object nested in depth arrays and dictionaries
+func (p *parser) value(depth int) (any, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("objects nested deeper than %d at offset %d", maxDepth, p.pos)
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("unexpected end of data")
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		return p.name(), nil
+	case c == '(':
+		return p.literalString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		return p.dict(depth)
+	case c == '<':
+		return p.hexString()
+	case c == '[':
+		p.pos++
+		return p.array(depth)
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return p.number()
+	case isDelim(c):
+		return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+	}
+
+	switch word := p.word(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	default:
+		return keyword(word), nil
+	}
+}
+
+// name reads a name after its slash, decoding #xx escapes
+func (p *parser) name() Name {
+	word := p.word()
+	if !bytes.ContainsRune([]byte(word), '#') {
+		return Name(word)
+	}
+	var b []byte
+	for i := 0; i < len(word); i++ {
+		if word[i] == '#' && i+2 < len(word) {
+			if v, err := strconv.ParseUint(word[i+1:i+3], 16, 8); err == nil {
+				b = append(b, byte(v))
+				i += 2
+				continue
+			}
+		}
+		b = append(b, word[i])
+	}
+	return Name(b)
+}
+
+// number reads an integer, a real number or a reference such as 12 0 R
+func (p *parser) number() (any, error) {
+	word := p.word()
+	n, err := strconv.Atoi(word)
+	if err != nil {
+		f, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", word)
+		}
+		return f, nil
+	}
+
+	// An integer followed by a generation and R is a reference
+	start := p.pos
+	p.skipSpace()
+	if gen, err := strconv.Atoi(p.word()); err == nil && n >= 0 && gen >= 0 {
+		p.skipSpace()
+		if p.word() == "R" {
+			return Ref{Num: n, Gen: gen}, nil
+		}
+	}
+	p.pos = start
+	return n, nil
+}
+
+// literalString reads a string in parentheses, which may contain balanced parentheses and escapes
+func (p *parser) literalString() (string, error) {
+	p.pos++
+	var b []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return string(b), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			c = p.data[p.pos]
+			p.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string on the next one
+				if c == '\r' && p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// hexString reads a string of hexadecimal digits in angle brackets
+func (p *parser) hexString() (string, error) {
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated hex string")
+	}
+	var digits []byte
+	for _, c := range p.data[p.pos+1 : p.pos+end] {
+		if !isSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	p.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex string")
+		}
+		b[i] = byte(v)
+	}
+	return string(b), nil
+}
+
+// array reads the objects up to the closing bracket of an array nested in depth others
+func (p *parser) array(depth int) ([]any, error) {
+	var array []any
+	for {
+		p.skipSpace()
+		if p.pos < len(p.data) && p.data[p.pos] == ']' {
+			p.pos++
+			return array, nil
+		}
+		v, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+}
+
+// dict reads the keys and values up to the closing angle brackets of a dictionary nested in depth others
+func (p *parser) dict(depth int) (Dict, error) {
+	dict := Dict{}
+	for {
+		p.skipSpace()
+		if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+			p.pos += 2
+			return dict, nil
+		}
+		key, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		name, ok := key.(Name)
+		if !ok {
+			return nil, fmt.Errorf("dictionary key %v is not a name", key)
+		}
+		value, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		dict[name] = value
+	}
+}
+
+// streamData returns the data of the stream that starts after the stream keyword at p.pos,
+// and moves p.pos past the endstream keyword
+func (p *parser) streamData(length any) ([]byte, error) {
+	// The stream keyword is followed by CRLF or LF
+	if bytes.HasPrefix(p.data[p.pos:], []byte("\r\n")) {
+		p.pos += 2
+	} else if p.pos < len(p.data) && (p.data[p.pos] == '\n' || p.data[p.pos] == '\r') {
+		p.pos++
+	}
+	start := p.pos
+
+	// Trust the length when endstream follows it, otherwise look for endstream
+	if n, ok := length.(int); ok && n >= 0 && start+n <= len(p.data) {
+		end := &parser{data: p.data, pos: start + n}
+		end.skipSpace()
+		if bytes.HasPrefix(p.data[end.pos:], []byte("endstream")) {
+			p.pos = end.pos + len("endstream")
+			return p.data[start : start+n], nil
+		}
+	}
+	n := bytes.Index(p.data[start:], []byte("endstream"))
+	if n < 0 {
+		return nil, fmt.Errorf("unterminated stream")
+	}
+	p.pos = start + n + len("endstream")
+	data := p.data[start : start+n]
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return data, nil
+}
@@ -0,0 +1,325 @@
+// Package pdf extracts the scanned images from the pages of PDF files.
+// It only reads as much of the format as document scanners write: it does not render text or vector graphics,
+// and it does not support encrypted files.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the JPEG decoder for rotated pages
+	"regexp"
+	"strconv"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+)
+
+var (
+	// ErrInvalidPDF is returned when the data is not a PDF file that can be read
+	ErrInvalidPDF = fmt.Errorf("invalid PDF")
+	// ErrPageNotFound is returned when the document has no page with the requested number
+	ErrPageNotFound = fmt.Errorf("page not found")
+	// ErrNoImage is returned when a page has no image to extract
+	ErrNoImage = fmt.Errorf("page has no image")
+	// ErrUnsupportedImage is returned when the image of a page is encoded in a way that cannot be decoded
+	ErrUnsupportedImage = fmt.Errorf("unsupported image")
+)
+
+// maxDepth limits how deep references, page trees and forms are followed, so malformed files cannot loop forever
+const maxDepth = 32
+
+// objectHeader matches the start of an indirect object, e.g. 12 0 obj
+var objectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// trailerHeader matches the start of a trailer, up to its dictionary
+var trailerHeader = regexp.MustCompile(`trailer\s*<<`)
+
+// Document is a parsed PDF file
+type Document struct {
+	objects map[int]any
+	pages   []Dict
+}
+
+// Open parses the PDF file in data
+func Open(data []byte) (*Document, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, fmt.Errorf("%w: missing %%PDF header", ErrInvalidPDF)
+	}
+	d := &Document{objects: map[int]any{}}
+	catalog, err := d.readObjects(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	if catalog == nil {
+		return nil, fmt.Errorf("%w: no document catalog", ErrInvalidPDF)
+	}
+	if err := d.readPages(catalog["Pages"], Dict{}, map[int]bool{}, 0); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPDF, err)
+	}
+	return d, nil
+}
+
+// PageCount is the number of pages in the document
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// PageImage returns the largest image drawn on page n, counting from 1, turned by the rotation of the page.
+// JPEG images of pages that are not rotated are returned as they are stored, other images are encoded as PNG.
+func (d *Document) PageImage(n int) ([]byte, error) {
+	if n < 1 || n > len(d.pages) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrPageNotFound, n, len(d.pages))
+	}
+	var best *Stream
+	for _, img := range d.images(d.pages[n-1]["Resources"], map[int]bool{}, 0) {
+		if best == nil || d.area(img) > d.area(best) {
+			best = img
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: page %d", ErrNoImage, n)
+	}
+	data, err := d.decodeImage(best)
+	if err != nil {
+		return nil, fmt.Errorf("%w: page %d: %v", ErrUnsupportedImage, n, err)
+	}
+	if data, err = d.rotatePage(data, d.pages[n-1]["Rotate"]); err != nil {
+		return nil, fmt.Errorf("%w: page %d: %v", ErrUnsupportedImage, n, err)
+	}
+	return data, nil
+}
+
+// rotatePage turns the image of a page clockwise by the page's rotation, a multiple of 90 degrees,
+// so the page is shown upright like a PDF viewer shows it
+func (d *Document) rotatePage(data []byte, rotate any) ([]byte, error) {
+	degrees := (d.intOr(rotate, 0)%360 + 360) % 360
+	if degrees == 0 || degrees%90 != 0 {
+		return data, nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return encodePNG(imaging.Rotate(img, degrees))
+}
+
+// readObjects reads every indirect object in data and returns the document catalog.
+// The file is scanned for objects rather than read through its cross-reference table, which scanners
+// and incremental updates often get wrong. Later definitions of an object replace earlier ones.
+func (d *Document) readObjects(data []byte) (Dict, error) {
+	var catalog Dict
+	var objectStreams []*Stream
+	var trailers []Dict
+	for pos := 0; pos < len(data); {
+		loc := objectHeader.FindSubmatchIndex(data[pos:])
+		if loc == nil {
+			break
+		}
+		start := pos + loc[0]
+		if start > 0 && !isDelim(data[start-1]) {
+			pos = start + 1
+			continue
+		}
+		num, _ := strconv.Atoi(string(data[pos+loc[2] : pos+loc[3]]))
+		p := &parser{data: data, pos: pos + loc[1]}
+		obj, err := p.object()
+		if err != nil {
+			pos = start + 1
+			continue
+		}
+
+		p.skipSpace()
+		if dict, ok := obj.(Dict); ok && bytes.HasPrefix(data[p.pos:], []byte("stream")) {
+			p.pos += len("stream")
+			streamData, err := p.streamData(dict["Length"])
+			if err != nil {
+				return nil, fmt.Errorf("object %d: %v", num, err)
+			}
+			stream := &Stream{Dict: dict, Data: streamData}
+			switch dict["Type"] {
+			case Name("ObjStm"):
+				objectStreams = append(objectStreams, stream)
+			case Name("XRef"):
+				// The dictionary of a cross-reference stream is the trailer of the file
+				trailers = append(trailers, dict)
+			}
+			obj = stream
+		}
+		if dict, ok := obj.(Dict); ok && dict["Type"] == Name("Catalog") {
+			catalog = dict
+		}
+		d.objects[num] = obj
+		pos = p.pos
+	}
+
+	// Encrypted files are only told apart by their trailer, since /Encrypt may as well be the content of a stream
+	for _, trailer := range append(trailers, readTrailers(data)...) {
+		if _, ok := trailer["Encrypt"]; ok {
+			return nil, fmt.Errorf("encrypted files are not supported")
+		}
+	}
+
+	// Objects compressed into object streams never replace the ones defined in the file itself
+	for _, stream := range objectStreams {
+		objects, err := d.readObjectStream(stream)
+		if err != nil {
+			return nil, err
+		}
+		for num, obj := range objects {
+			if _, ok := d.objects[num]; ok {
+				continue
+			}
+			d.objects[num] = obj
+			if dict, ok := obj.(Dict); ok && dict["Type"] == Name("Catalog") && catalog == nil {
+				catalog = dict
+			}
+		}
+	}
+	return catalog, nil
+}
+
+// readTrailers reads the dictionary of every trailer in data
+func readTrailers(data []byte) []Dict {
+	var trailers []Dict
+	for _, loc := range trailerHeader.FindAllIndex(data, -1) {
+		if loc[0] > 0 && !isDelim(data[loc[0]-1]) {
+			continue
+		}
+		p := &parser{data: data, pos: loc[1] - len("<<")}
+		if obj, err := p.object(); err == nil {
+			if dict, ok := obj.(Dict); ok {
+				trailers = append(trailers, dict)
+			}
+		}
+	}
+	return trailers
+}
+
+// readObjectStream reads the objects compressed into an object stream
+func (d *Document) readObjectStream(stream *Stream) (map[int]any, error) {
+	data, err := d.decodeStream(stream)
+	if err != nil {
+		return nil, fmt.Errorf("object stream: %v", err)
+	}
+	n, _ := d.resolve(stream.Dict["N"]).(int)
+	first, _ := d.resolve(stream.Dict["First"]).(int)
+	if first < 0 || first > len(data) {
+		return nil, fmt.Errorf("object stream: invalid offset %d", first)
+	}
+
+	header := &parser{data: data[:first]}
+	objects := map[int]any{}
+	for i := 0; i < n; i++ {
+		num, err := header.object()
+		if err != nil {
+			return nil, fmt.Errorf("object stream: %v", err)
+		}
+		offset, err := header.object()
+		if err != nil {
+			return nil, fmt.Errorf("object stream: %v", err)
+		}
+		numInt, ok1 := num.(int)
+		offsetInt, ok2 := offset.(int)
+		if !ok1 || !ok2 || offsetInt < 0 || first+offsetInt > len(data) {
+			return nil, fmt.Errorf("object stream: invalid header")
+		}
+		obj, err := (&parser{data: data, pos: first + offsetInt}).object()
+		if err != nil {
+			return nil, fmt.Errorf("object stream: object %d: %v", numInt, err)
+		}
+		objects[numInt] = obj
+	}
+	return objects, nil
+}
+
+// resolve follows references until it reaches an object that is not one. Missing objects are nil.
+func (d *Document) resolve(v any) any {
+	for i := 0; i < maxDepth; i++ {
+		ref, ok := v.(Ref)
+		if !ok {
+			return v
+		}
+		v = d.objects[ref.Num]
+	}
+	return nil
+}
+
+// inheritable are the attributes of a page that it inherits from the nearest node above it that has them
+var inheritable = []Name{"Resources", "Rotate"}
+
+// readPages appends the pages of the page tree node to the document in order, with the attributes they inherit
+func (d *Document) readPages(node any, inherited Dict, seen map[int]bool, depth int) error {
+	if ref, ok := node.(Ref); ok {
+		if seen[ref.Num] {
+			return fmt.Errorf("page tree has a cycle at object %d", ref.Num)
+		}
+		seen[ref.Num] = true
+	}
+	if depth > maxDepth {
+		return fmt.Errorf("page tree is too deep")
+	}
+	dict, ok := d.resolve(node).(Dict)
+	if !ok {
+		return fmt.Errorf("page tree node is not a dictionary")
+	}
+	attributes := Dict{}
+	for _, name := range inheritable {
+		if v, ok := dict[name]; ok {
+			attributes[name] = v
+		} else if v, ok := inherited[name]; ok {
+			attributes[name] = v
+		}
+	}
+
+	kids, isNode := d.resolve(dict["Kids"]).([]any)
+	if !isNode || dict["Type"] == Name("Page") {
+		d.pages = append(d.pages, attributes)
+		return nil
+	}
+	for _, kid := range kids {
+		if err := d.readPages(kid, attributes, seen, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// images returns the image XObjects of the resources, including those drawn by the forms they use
+func (d *Document) images(resources any, seen map[int]bool, depth int) []*Stream {
+	res, ok := d.resolve(resources).(Dict)
+	if !ok || depth > maxDepth {
+		return nil
+	}
+	xobjects, ok := d.resolve(res["XObject"]).(Dict)
+	if !ok {
+		return nil
+	}
+
+	var images []*Stream
+	for _, v := range xobjects {
+		if ref, ok := v.(Ref); ok {
+			if seen[ref.Num] {
+				continue
+			}
+			seen[ref.Num] = true
+		}
+		stream, ok := d.resolve(v).(*Stream)
+		if !ok {
+			continue
+		}
+		switch stream.Dict["Subtype"] {
+		case Name("Image"):
+			images = append(images, stream)
+		case Name("Form"):
+			images = append(images, d.images(stream.Dict["Resources"], seen, depth+1)...)
+		}
+	}
+	return images
+}
+
+// area is the number of pixels of an image
+func (d *Document) area(img *Stream) int {
+	width, _ := d.resolve(img.Dict["Width"]).(int)
+	height, _ := d.resolve(img.Dict["Height"]).(int)
+	return width * height
+}
@@ -0,0 +1,317 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writePDF writes the numbered objects as a PDF file. Objects that are []byte are written as a stream
+// with the dictionary that comes before them in the same entry, e.g. {"<< /Length 3 >>", []byte("abc")}.
+func writePDF(objects ...[]any) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.7\n%\xe2\xe3\xcf\xd3\n")
+	for i, obj := range objects {
+		fmt.Fprintf(&b, "%d 0 obj\n", i+1)
+		for _, part := range obj {
+			switch p := part.(type) {
+			case string:
+				b.WriteString(p)
+			case []byte:
+				b.WriteString("\nstream\r\n")
+				b.Write(p)
+				b.WriteString("\nendstream")
+			}
+		}
+		b.WriteString("\nendobj\n")
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes()
+}
+
+// deflate compresses data with zlib, as the Flate filter does
+func deflate(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// testJPEG encodes a solid gray JPEG
+func testJPEG(t *testing.T, width, height int) []byte {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 200
+	}
+	var b bytes.Buffer
+	require.NoError(t, jpeg.Encode(&b, img, nil))
+	return b.Bytes()
+}
+
+// decodePNG decodes an extracted PNG image
+func decodePNG(t *testing.T, data []byte) image.Image {
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	return img
+}
+
+func TestDocument_PageImage(t *testing.T) {
+	photo := testJPEG(t, 40, 60)
+
+	// 2x2 gray samples, each row starting with the PNG Up filter
+	gray := deflate([]byte{2, 10, 20, 2, 5, 5})
+	// 2x1 RGB samples
+	rgb := []byte{255, 0, 0, 0, 0, 255}
+
+	data := writePDF(
+		[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+		// The first two pages inherit their resources from a page tree node
+		[]any{"<< /Type /Pages /Kids [3 0 R 6 0 R] /Count 3 >>"},
+		[]any{"<< /Type /Pages /Kids [4 0 R 5 0 R] /Count 2 /Resources << /XObject << /Im0 7 0 R >> >> >>"},
+		[]any{"<< /Type /Page /Parent 3 0 R >>"},
+		[]any{"<< /Type /Page /Parent 3 0 R /Resources << /XObject << /Im1 8 0 R /Fm0 10 0 R >> >> >>"},
+		[]any{"<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im2 9 0 R >> >> >>"},
+		[]any{fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 40 /Height 60 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", len(photo)), photo},
+		[]any{fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 2 /Height 2 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /DecodeParms << /Predictor 15 /Columns 2 >> /Length %d >>", len(gray)), gray},
+		[]any{"<< /Type /XObject /Subtype /Image /Width 2 /Height 1 /ColorSpace [/ICCBased 11 0 R] /BitsPerComponent 8 /Length 6 >>", rgb},
+		// A form with a small image on the second page, which is not the one extracted
+		[]any{"<< /Type /XObject /Subtype /Form /Resources << /XObject << /Thumb 12 0 R >> >> /Length 0 >>", []byte{}},
+		[]any{"<< /N 3 /Length 0 >>", []byte{}},
+		[]any{"<< /Type /XObject /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>", []byte{0}},
+	)
+
+	doc, err := Open(data)
+	require.NoError(t, err)
+	assert.Equal(t, 3, doc.PageCount())
+
+	page, err := doc.PageImage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, photo, page, "JPEG images are extracted as they are stored")
+
+	page, err = doc.PageImage(2)
+	require.NoError(t, err)
+	img := decodePNG(t, page)
+	assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+	assert.Equal(t, color.Gray{Y: 20}, color.GrayModel.Convert(img.At(1, 0)))
+	assert.Equal(t, color.Gray{Y: 25}, color.GrayModel.Convert(img.At(1, 1)))
+
+	page, err = doc.PageImage(3)
+	require.NoError(t, err)
+	img = decodePNG(t, page)
+	assert.Equal(t, color.RGBA{R: 255, A: 255}, color.RGBAModel.Convert(img.At(0, 0)))
+	assert.Equal(t, color.RGBA{B: 255, A: 255}, color.RGBAModel.Convert(img.At(1, 0)))
+
+	for _, n := range []int{0, 4} {
+		_, err = doc.PageImage(n)
+		assert.ErrorIs(t, err, ErrPageNotFound)
+	}
+}
+
+func TestDocument_PageImage_Rotate(t *testing.T) {
+	photo := testJPEG(t, 40, 60)
+	// 2x1 RGB samples, red then blue
+	rgb := []byte{255, 0, 0, 0, 0, 255}
+
+	data := writePDF(
+		[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+		// The rotation is inherited like the resources, and a page can set its own
+		[]any{"<< /Type /Pages /Kids [3 0 R 4 0 R 5 0 R 6 0 R] /Count 4 /Rotate 90 /Resources << /XObject << /Im0 7 0 R >> >> >>"},
+		[]any{"<< /Type /Page /Parent 2 0 R >>"},
+		[]any{"<< /Type /Page /Parent 2 0 R /Rotate -90 >>"},
+		[]any{"<< /Type /Page /Parent 2 0 R /Rotate 0 >>"},
+		[]any{"<< /Type /Page /Parent 2 0 R /Rotate 180 /Resources << /XObject << /Im1 8 0 R >> >> >>"},
+		[]any{"<< /Type /XObject /Subtype /Image /Width 2 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Length 6 >>", rgb},
+		[]any{fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width 40 /Height 60 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", len(photo)), photo},
+	)
+	doc, err := Open(data)
+	require.NoError(t, err)
+
+	red, blue := color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}
+	for n, want := range map[int][]color.RGBA{1: {red, blue}, 2: {blue, red}} {
+		page, err := doc.PageImage(n)
+		require.NoError(t, err)
+		img := decodePNG(t, page)
+		assert.Equal(t, image.Rect(0, 0, 1, 2), img.Bounds(), "page %d", n)
+		assert.Equal(t, want[0], color.RGBAModel.Convert(img.At(0, 0)), "page %d", n)
+		assert.Equal(t, want[1], color.RGBAModel.Convert(img.At(0, 1)), "page %d", n)
+	}
+
+	page, err := doc.PageImage(3)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 2, 1), decodePNG(t, page).Bounds())
+
+	// A rotated JPEG is decoded, turned and encoded as PNG
+	page, err = doc.PageImage(4)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 40, 60), decodePNG(t, page).Bounds())
+}
+
+func TestDocument_PageImage_ObjectStream(t *testing.T) {
+	photo := testJPEG(t, 8, 8)
+
+	// Objects 3 to 5 are compressed into the object stream
+	objects := []string{
+		"<< /Type /Catalog /Pages 4 0 R >>",
+		"<< /Type /Pages /Kids [5 0 R] /Count 1 >>",
+		"<< /Type /Page /Resources << /XObject << /Im0 1 0 R >> >> >>",
+	}
+	var header, body string
+	for i, obj := range objects {
+		header += fmt.Sprintf("%d %d ", i+3, len(body))
+		body += obj + "\n"
+	}
+	compressed := deflate([]byte(header + body))
+
+	data := writePDF(
+		[]any{fmt.Sprintf("<< /Subtype /Image /Width 8 /Height 8 /Filter [/DCTDecode] /Length %d >>", len(photo)), photo},
+		[]any{fmt.Sprintf("<< /Type /ObjStm /N 3 /First %d /Filter /FlateDecode /Length %d >>", len(header), len(compressed)), compressed},
+	)
+
+	doc, err := Open(data)
+	require.NoError(t, err)
+	assert.Equal(t, 1, doc.PageCount())
+	page, err := doc.PageImage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, photo, page)
+}
+
+func TestDocument_PageImage_Fax(t *testing.T) {
+	// Each row of a blank Group 4 page is a single vertical mode code, the bit 1
+	fax := []byte{0xff}
+
+	for _, test := range []struct {
+		name  string
+		parms string
+		want  uint8
+	}{
+		{name: "default", parms: "/K -1 /Columns 8", want: 255},
+		{name: "black is 1", parms: "/K -1 /Columns 8 /BlackIs1 true", want: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data := writePDF(
+				[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+				[]any{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+				[]any{"<< /Type /Page /Resources << /XObject << /Im0 4 0 R >> >> >>"},
+				[]any{"<< /Subtype /Image /Width 8 /Height 8 /ImageMask true /Filter /CCITTFaxDecode /DecodeParms << " + test.parms + " >> /Length 1 >>", fax},
+			)
+			doc, err := Open(data)
+			require.NoError(t, err)
+			page, err := doc.PageImage(1)
+			require.NoError(t, err)
+			img := decodePNG(t, page)
+			assert.Equal(t, image.Rect(0, 0, 8, 8), img.Bounds())
+			assert.Equal(t, color.Gray{Y: test.want}, color.GrayModel.Convert(img.At(3, 5)))
+		})
+	}
+}
+
+func TestDocument_PageImage_Errors(t *testing.T) {
+	data := writePDF(
+		[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]any{"<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 >>"},
+		[]any{"<< /Type /Page /Contents 5 0 R >>"},
+		[]any{"<< /Type /Page /Resources << /XObject << /Im0 6 0 R >> >> >>"},
+		[]any{"<< /Length 13 >>", []byte("BT (Hi) Tj ET")},
+		[]any{"<< /Subtype /Image /Width 8 /Height 8 /Filter /JPXDecode /Length 3 >>", []byte("jpx")},
+	)
+	doc, err := Open(data)
+	require.NoError(t, err)
+
+	_, err = doc.PageImage(1)
+	assert.ErrorIs(t, err, ErrNoImage)
+	_, err = doc.PageImage(2)
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+func TestDocument_PageImage_FaxTooLarge(t *testing.T) {
+	for name, parms := range map[string]string{
+		"rows without data": "/K -1 /Columns 8 /Rows 9",
+		"too wide":          "/K -1 /Columns 1000000 /Rows 1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := writePDF(
+				[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+				[]any{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+				[]any{"<< /Type /Page /Resources << /XObject << /Im0 4 0 R >> >> >>"},
+				[]any{"<< /Subtype /Image /Width 8 /Height 8 /ImageMask true /Filter /CCITTFaxDecode /DecodeParms << " + parms + " >> /Length 1 >>", []byte{0xff}},
+			)
+			doc, err := Open(data)
+			require.NoError(t, err)
+			_, err = doc.PageImage(1)
+			assert.ErrorContains(t, err, "too large")
+		})
+	}
+}
+
+func TestDocument_PageImage_FlateTooLarge(t *testing.T) {
+	// 8x8 gray samples take 64 bytes, so a megabyte of them is not read
+	bomb := deflate(make([]byte, 1<<20))
+	data := writePDF(
+		[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]any{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]any{"<< /Type /Page /Resources << /XObject << /Im0 4 0 R >> >> >>"},
+		[]any{fmt.Sprintf("<< /Subtype /Image /Width 8 /Height 8 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>", len(bomb)), bomb},
+	)
+	doc, err := Open(data)
+	require.NoError(t, err)
+	_, err = doc.PageImage(1)
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+	assert.ErrorContains(t, err, "more than the 72 bytes expected")
+}
+
+func TestParser_Nested(t *testing.T) {
+	deep := strings.Repeat("[", 10000) + strings.Repeat("]", 10000)
+	_, err := (&parser{data: []byte(deep)}).object()
+	assert.ErrorContains(t, err, "nested deeper")
+
+	v, err := (&parser{data: []byte("<< /A [[1] << /B 2 >>] >>")}).object()
+	require.NoError(t, err)
+	assert.Equal(t, Dict{"A": []any{[]any{1}, Dict{"B": 2}}}, v)
+}
+
+func TestOpen_EncryptInContent(t *testing.T) {
+	// A page that draws the word /Encrypt is not taken for an encrypted file
+	photo := testJPEG(t, 8, 8)
+	content := []byte("BT (/Encrypt) Tj ET")
+	data := writePDF(
+		[]any{"<< /Type /Catalog /Pages 2 0 R >>"},
+		[]any{"<< /Type /Pages /Kids [3 0 R] /Count 1 >>"},
+		[]any{"<< /Type /Page /Contents 5 0 R /Resources << /XObject << /Im0 4 0 R >> >> >>"},
+		[]any{fmt.Sprintf("<< /Subtype /Image /Width 8 /Height 8 /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", len(photo)), photo},
+		[]any{fmt.Sprintf("<< /Length %d >>", len(content)), content},
+	)
+	doc, err := Open(data)
+	require.NoError(t, err)
+	page, err := doc.PageImage(1)
+	assert.NoError(t, err)
+	assert.Equal(t, photo, page)
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"not a PDF":  "\xff\xd8\xff\xe0 JFIF",
+		"no catalog": "%PDF-1.4\n1 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n",
+		"cycle":      "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n2 0 obj\n<< /Type /Pages /Kids [2 0 R] >>\nendobj\n",
+		"encrypted":  "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\ntrailer\n<< /Root 1 0 R /Encrypt 3 0 R >>\n",
+		"encrypted with a cross-reference stream": "%PDF-1.5\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+			"3 0 obj\n<< /Type /XRef /Size 4 /W [1 1 1] /Root 1 0 R /Encrypt 4 0 R /Length 0 >>\nstream\n\nendstream\nendobj\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Open([]byte(data))
+			assert.ErrorIs(t, err, ErrInvalidPDF)
+			if strings.HasPrefix(name, "encrypted") {
+				assert.ErrorContains(t, err, "encrypted files are not supported")
+			}
+		})
+	}
+}
@@ -9,22 +9,36 @@ import (
 //
 //go:generate go run github.com/vektra/mockery/v2 --name OCRClient
 type OCRClient interface {
-	// OCRImage processes an image and returns the transcribed text, total cost from all attempts, and the number of attempts made
-	OCRImage(ctx context.Context, imageData []byte) (text string, cost float64, attempts int, err error)
+	// OCRImage processes an image and returns the transcription, total cost from all attempts, and every attempt made.
+	// The attempts are returned with the error as well. The page describes the image to the prompt.
+	OCRImage(ctx context.Context, imageData []byte, page PageInfo) (transcription Transcription, cost float64, attempts []Attempt, err error)
 	// ValidateAPIKey validates the OpenAI API key
 	ValidateAPIKey(ctx context.Context) error
+	// Fingerprint identifies the model and the prompt rendered for the page, so saved results are only reused
+	// when they would not change
+	Fingerprint(page PageInfo) string
 }
 
 // Repository defines the interface for file operations
 //
 //go:generate go run github.com/vektra/mockery/v2 --name Repository
 type Repository interface {
-	// GetImageNames returns sorted image filenames from the repository's base directory
+	// GetImageNames returns the paths of the images relative to the repository's base directory, e.g. vol1/IMG_0001.jpg,
+	// sorted by directory and then by name.
+	// The pages of a PDF or multi-page TIFF file are named after the file and the page, e.g. scan.pdf#p3, and listed in page order.
 	GetImageNames() ([]string, error)
-	// LoadImageByName loads image data by filename from the repository's base directory
+	// LoadImageByName loads image data by its path relative to the repository's base directory
 	LoadImageByName(filename string) ([]byte, error)
 	// SaveOutput saves the output text to the repository's configured output path
 	SaveOutput(content string) error
+	// SaveOutputs saves each output to its own file named after its key, next to the repository's configured output path
+	SaveOutputs(outputs map[string]string) error
+	// SaveDirectoryOutput saves the output of the images of a subdirectory, e.g. vol1, to a file of the same name as
+	// the configured output path in that subdirectory of the output's directory
+	SaveDirectoryOutput(dir, content string) error
+	// SaveDirectoryOutputs saves each output of the images of a subdirectory to its own file named after its key,
+	// in that subdirectory of the output's directory
+	SaveDirectoryOutputs(dir string, outputs map[string]string) error
 }
 
 // Resizer defines the interface for image resizing operations
@@ -35,19 +49,128 @@ type Resizer interface {
 	ResizeImage(imageData []byte, maxDimension int) ([]byte, error)
 }
 
+// Tiler defines the interface for splitting images into tiles that are transcribed on their own
+//
+//go:generate go run github.com/vektra/mockery/v2 --name Tiler
+type Tiler interface {
+	// TileImage splits an image into overlapping horizontal bands, from top to bottom, that span the full width
+	// of the image and are at most maxDimension high
+	TileImage(imageData []byte, maxDimension int) ([][]byte, error)
+}
+
+// Splitter defines the interface for splitting photos and scans of open-book spreads into their pages
+//
+//go:generate go run github.com/vektra/mockery/v2 --name Splitter
+type Splitter interface {
+	// SplitSpread returns the left and right pages of an open-book spread, in reading order, or the image alone
+	// when it shows a single page
+	SplitSpread(imageData []byte) ([][]byte, error)
+}
+
+// Preprocessor defines the interface for cleaning up images before OCR
+//
+//go:generate go run github.com/vektra/mockery/v2 --name Preprocessor
+type Preprocessor interface {
+	// Preprocess returns the image prepared for OCR, e.g. with its shadows removed and its contrast raised
+	Preprocess(imageName string, imageData []byte) ([]byte, error)
+}
+
+// CheckpointStore defines the interface for saving results as soon as they finish, so interrupted runs can be resumed
+//
+//go:generate go run github.com/vektra/mockery/v2 --name CheckpointStore
+type CheckpointStore interface {
+	// LoadCheckpoints returns the saved results keyed by their checkpoint key
+	LoadCheckpoints() (map[string]OCRResult, error)
+	// SaveCheckpoint saves a finished result under its checkpoint key
+	SaveCheckpoint(key string, result OCRResult) error
+}
+
+// OutputFormatter defines the interface for formatting the results into the output document
+//
+//go:generate go run github.com/vektra/mockery/v2 --name OutputFormatter
+type OutputFormatter interface {
+	// Format formats the results, in page order, and the summary of the run into the output content
+	Format(results []OCRResult, summary *ProcessImageResults) (string, error)
+}
+
+// CostEstimator defines the interface for predicting the tokens and cost of OCR without sending any request
+//
+//go:generate go run github.com/vektra/mockery/v2 --name CostEstimator
+type CostEstimator interface {
+	// EstimateImage returns the input tokens of an image of the given size and the lowest and highest cost expected for transcribing it
+	EstimateImage(width, height int) (imageTokens int, minCost, maxCost float64)
+}
+
 // ProgressUpdater defines the interface for updating progress during image processing
 type ProgressUpdater interface {
 	// UpdateProgress is called after each image is processed with the current count and total
 	UpdateProgress(completed, total int)
 }
 
+// Transcription is what the OCR client read from an image
+type Transcription struct {
+	Text string
+	// Date is the date at the top of the page as reported by the model, or empty when the model did not report one
+	Date string
+	// PageNumber is the page number written on the page, or empty when there is none
+	PageNumber string
+	// IllegibleSegments are the parts of the page the model could not read
+	IllegibleSegments []string
+}
+
+// PageInfo describes the image being transcribed
+type PageInfo struct {
+	// Name is the name of the image
+	Name string
+	// Index is the position of the image in the run, starting at 1. The left and right pages of a split spread
+	// share the index of their image, so the index of every image after them is the same with or without splitting.
+	Index int
+}
+
+// Attempt is a single request sent to transcribe an image
+type Attempt struct {
+	// Delay is how long the client waited before sending the request, for the retry backoff and rate limits
+	Delay time.Duration
+	// Duration is how long the request took
+	Duration time.Duration
+	Cost     float64
+	// Error is why the attempt failed, or nil when it succeeded
+	Error error
+	// Refusal is the explanation given by the model when it refused to transcribe the image
+	Refusal string
+	// Strategy is the change made to the request after the model refused the attempt before it, e.g. "context"
+	Strategy string
+	// PromptHash identifies the prompts sent with the request
+	PromptHash string
+}
+
 // OCRResult represents the result of processing a single image
 type OCRResult struct {
-	ImageName   string
-	Date        string
-	Text        string
-	Cost        float64
-	OCRAttempts int
-	Duration    time.Duration
-	Error       error
+	ImageName string
+	// Date is the date at the top of the page, as it was written
+	Date string
+	// ParsedDate is the parsed Date, or zero when the page has no date
+	ParsedDate time.Time
+	// EntryDate is the date of the journal entry, formatted with the configured layout:
+	// Date, or the last date found on an earlier page
+	EntryDate string
+	// ParsedEntryDate is the parsed EntryDate, or zero when it is not a date
+	ParsedEntryDate time.Time
+	Text            string
+	// PageNumber is the page number written on the page, or empty when there is none
+	PageNumber string
+	// IllegibleSegments are the parts of the page the model could not read
+	IllegibleSegments []string
+	Cost              float64
+	OCRAttempts       int
+	// Attempts is the history of the requests sent for the image, including the failed ones
+	Attempts []Attempt
+	Duration time.Duration
+	Error    error
+	// Resumed is true when the result was loaded from a checkpoint instead of being sent for OCR
+	Resumed bool
+	// PromptPreset is the name of the prompt preset or template file used for OCR
+	PromptPreset string
+	// PromptHash identifies the prompts of the attempt that transcribed the image
+	PromptHash string
 }
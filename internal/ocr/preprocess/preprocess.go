@@ -0,0 +1,141 @@
+// Package preprocess cleans up photos and scans of pages before they are sent for OCR.
+package preprocess
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+	_ "golang.org/x/image/bmp"  // register the BMP decoder
+	_ "golang.org/x/image/tiff" // register the TIFF decoder
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+// Step is a change made to an image before OCR
+type Step string
+
+const (
+	// StepGrayscale removes the color of the image
+	StepGrayscale Step = "grayscale"
+	// StepShadows evens out the lighting of the page, removing shadows and the tint of the paper
+	StepShadows Step = "shadows"
+	// StepContrast stretches the tones of the image so the darkest ink is black and the paper is white
+	StepContrast Step = "contrast"
+	// StepCLAHE raises the contrast of each region of the image on its own (contrast limited adaptive histogram equalization)
+	StepCLAHE Step = "clahe"
+	// StepBinarize turns the image into black ink on white paper, with a threshold that adapts to each region
+	StepBinarize Step = "binarize"
+	// StepCrop cuts away the blank margins around the text
+	StepCrop Step = "crop"
+)
+
+// Steps lists every step in the order they are best applied
+var Steps = []Step{StepGrayscale, StepShadows, StepContrast, StepCLAHE, StepBinarize, StepCrop}
+
+var (
+	// ErrUnknownStep is returned when a step is not one of Steps
+	ErrUnknownStep = fmt.Errorf("unknown preprocessing step")
+	// ErrPreprocessingFailed is returned when an image cannot be decoded, encoded or saved for debugging
+	ErrPreprocessingFailed = fmt.Errorf("preprocessing failed")
+)
+
+// Config holds the settings of the Pipeline
+type Config struct {
+	// Steps are applied in order
+	Steps []Step
+	// DebugDir is the directory the image is saved to after each step, or empty to save nothing
+	DebugDir string
+}
+
+// Pipeline implements the ocr.Preprocessor interface by applying the configured steps in order
+type Pipeline struct {
+	config Config
+}
+
+// New creates a new Pipeline
+func New(config Config) (*Pipeline, error) {
+	for _, step := range config.Steps {
+		if !slices.Contains(Steps, step) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownStep, step)
+		}
+	}
+	return &Pipeline{config: config}, nil
+}
+
+// Preprocess applies the steps to the image. The image is encoded as a JPEG when it was one
+// and is still in color or shades of gray, and as a PNG otherwise.
+func (p *Pipeline) Preprocess(imageName string, imageData []byte) ([]byte, error) {
+	if len(p.config.Steps) == 0 {
+		return imageData, nil
+	}
+	img, format, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPreprocessingFailed, imageName, err)
+	}
+
+	for i, step := range p.config.Steps {
+		img = apply(step, img)
+		if p.config.DebugDir != "" {
+			if err := p.saveStep(imageName, i+1, step, img); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == "jpeg" && !slices.Contains(p.config.Steps, StepBinarize) {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92})
+	} else {
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPreprocessingFailed, imageName, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// apply runs a single step on an image
+func apply(step Step, img image.Image) image.Image {
+	switch step {
+	case StepGrayscale:
+		return imaging.Gray(img)
+	case StepShadows:
+		return mapTones(img, removeShadows)
+	case StepContrast:
+		return mapTones(img, autoContrast)
+	case StepCLAHE:
+		return mapTones(img, clahe)
+	case StepBinarize:
+		return binarize(imaging.Gray(img))
+	case StepCrop:
+		return cropMargins(img)
+	default:
+		return img
+	}
+}
+
+// saveStep saves the image after a step to the debug directory, e.g. IMG_0001.jpg.2-clahe.png
+func (p *Pipeline) saveStep(imageName string, n int, step Step, img image.Image) error {
+	if err := os.MkdirAll(p.config.DebugDir, 0755); err != nil {
+		return fmt.Errorf("%w: %v", ErrPreprocessingFailed, err)
+	}
+	name := strings.NewReplacer("/", "_", `\`, "_").Replace(imageName)
+	path := filepath.Join(p.config.DebugDir, fmt.Sprintf("%s.%d-%s.png", name, n, step))
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrPreprocessingFailed, imageName, err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("%w: %v", ErrPreprocessingFailed, err)
+	}
+	return nil
+}
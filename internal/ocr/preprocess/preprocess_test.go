@@ -0,0 +1,161 @@
+package preprocess
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shadedPage returns a 400x300 page whose paper darkens from left to right, as if shadowed by a hand,
+// with lines of dark ink in the middle
+func shadedPage() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 400; x++ {
+			paper := uint8(210 - x*90/400)
+			c := color.RGBA{paper, paper - 10, paper - 30, 255}
+			if x >= 100 && x < 260 && y >= 80 && y < 220 && y%16 < 4 {
+				c = color.RGBA{40, 40, 60, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// encode encodes an image in the given format
+func encode(t *testing.T, img image.Image, format string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		require.NoError(t, jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}))
+	} else {
+		require.NoError(t, png.Encode(&buf, img))
+	}
+	return buf.Bytes()
+}
+
+// run preprocesses the shaded page with the given steps and decodes the result
+func run(t *testing.T, format string, steps ...Step) (image.Image, string) {
+	t.Helper()
+	pipeline, err := New(Config{Steps: steps})
+	require.NoError(t, err)
+	data, err := pipeline.Preprocess("page.png", encode(t, shadedPage(), format))
+	require.NoError(t, err)
+	img, outFormat, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	return img, outFormat
+}
+
+// lum returns the luminance of a pixel
+func lum(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Steps: Steps})
+	assert.NoError(t, err)
+
+	_, err = New(Config{Steps: []Step{StepGrayscale, "sharpen"}})
+	assert.ErrorIs(t, err, ErrUnknownStep)
+	assert.Contains(t, err.Error(), "sharpen")
+}
+
+func TestPipeline_Preprocess_NoSteps(t *testing.T) {
+	pipeline, err := New(Config{})
+	require.NoError(t, err)
+
+	data := encode(t, shadedPage(), "png")
+	out, err := pipeline.Preprocess("page.png", data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, out)
+}
+
+func TestPipeline_Preprocess_Steps(t *testing.T) {
+	t.Run("grayscale", func(t *testing.T) {
+		img, _ := run(t, "png", StepGrayscale)
+		_, ok := img.(*image.Gray)
+		assert.True(t, ok, "expected a grayscale image, got %T", img)
+	})
+
+	t.Run("contrast stretches the tones", func(t *testing.T) {
+		img, _ := run(t, "png", StepContrast)
+		assert.Less(t, lum(img, 150, 80), uint8(10))
+		assert.Greater(t, lum(img, 10, 10), uint8(245))
+	})
+
+	t.Run("shadows even out the paper", func(t *testing.T) {
+		before := shadedPage()
+		img, _ := run(t, "png", StepShadows)
+		assert.Greater(t, int(lum(before, 10, 10))-int(lum(before, 390, 10)), 60)
+		assert.InDelta(t, lum(img, 10, 10), lum(img, 390, 10), 20)
+		// The ink stays dark
+		assert.Less(t, lum(img, 150, 80), uint8(100))
+	})
+
+	t.Run("clahe keeps the size", func(t *testing.T) {
+		img, _ := run(t, "png", StepCLAHE)
+		assert.Equal(t, image.Rect(0, 0, 400, 300), img.Bounds())
+		assert.Less(t, lum(img, 150, 80), lum(img, 150, 90))
+	})
+
+	t.Run("binarize leaves black and white", func(t *testing.T) {
+		img, format := run(t, "jpeg", StepBinarize)
+		assert.Equal(t, "png", format)
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if v := lum(img, x, y); v != 0 && v != 255 {
+					t.Fatalf("pixel %d,%d is %d", x, y, v)
+				}
+			}
+		}
+		assert.Equal(t, uint8(0), lum(img, 150, 80))
+		assert.Equal(t, uint8(255), lum(img, 150, 90))
+	})
+
+	t.Run("crop cuts the margins", func(t *testing.T) {
+		// The shadow is removed first, or it would be taken for ink
+		img, _ := run(t, "png", StepShadows, StepCrop)
+		bounds := img.Bounds()
+		assert.InDelta(t, 176, bounds.Dx(), 4)
+		assert.InDelta(t, 144, bounds.Dy(), 4)
+	})
+
+	t.Run("jpeg stays jpeg", func(t *testing.T) {
+		_, format := run(t, "jpeg", StepShadows, StepContrast)
+		assert.Equal(t, "jpeg", format)
+	})
+}
+
+func TestPipeline_Preprocess_DebugDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "debug")
+	pipeline, err := New(Config{Steps: []Step{StepGrayscale, StepCLAHE}, DebugDir: dir})
+	require.NoError(t, err)
+
+	_, err = pipeline.Preprocess("2024/page.png", encode(t, shadedPage(), "png"))
+	require.NoError(t, err)
+
+	for _, name := range []string{"2024_page.png.1-grayscale.png", "2024_page.png.2-clahe.png"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		_, err = png.Decode(bytes.NewReader(data))
+		assert.NoError(t, err)
+	}
+}
+
+func TestPipeline_Preprocess_InvalidImage(t *testing.T) {
+	pipeline, err := New(Config{Steps: []Step{StepGrayscale}})
+	require.NoError(t, err)
+
+	_, err = pipeline.Preprocess("page.png", []byte("not an image"))
+	assert.ErrorIs(t, err, ErrPreprocessingFailed)
+}
@@ -0,0 +1,315 @@
+package preprocess
+
+import (
+	"image"
+	"math"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// contrastClip is the share of the darkest and lightest pixels that auto contrast lets go to pure black and white
+	contrastClip = 0.01
+	// claheTiles is the number of tiles across each side of the image that CLAHE equalizes on their own
+	claheTiles = 8
+	// claheClipLimit limits the height of each bin of a tile's histogram to this multiple of the average bin,
+	// so the noise of the blank paper is not amplified
+	claheClipLimit = 2.0
+	// sauvolaK weighs the local deviation in the binarization threshold
+	sauvolaK = 0.2
+	// shadowSampleSize is the longest side of the copy of the page that the lighting is estimated on
+	shadowSampleSize = 64
+	// cropPadding is the share of each side of the image kept around the text when cropping
+	cropPadding = 0.02
+)
+
+// mapTones changes the tones of an image with a function of its luminance. A grayscale image is replaced by the
+// result, while the channels of a color image are scaled with the luminance of each pixel, so its colors are kept.
+func mapTones(img image.Image, f func(*image.Gray) *image.Gray) image.Image {
+	before := imaging.Gray(img)
+	after := f(before)
+	if _, ok := img.(*image.Gray); ok {
+		return after
+	}
+
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			from, to := float64(before.Pix[y*before.Stride+x]), float64(after.Pix[y*after.Stride+x])
+			i := out.PixOffset(x, y)
+			for c, v := range []uint32{r, g, b} {
+				if from == 0 {
+					out.Pix[i+c] = uint8(to)
+				} else {
+					out.Pix[i+c] = clamp(float64(v>>8) * to / from)
+				}
+			}
+			out.Pix[i+3] = 255
+		}
+	}
+	return out
+}
+
+// clamp rounds v to the nearest 8-bit value
+func clamp(v float64) uint8 {
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
+
+// autoContrast stretches the tones linearly so the darkest and lightest pixels, apart from contrastClip of each,
+// become black and white
+func autoContrast(gray *image.Gray) *image.Gray {
+	var histogram [256]int
+	for _, v := range gray.Pix {
+		histogram[v]++
+	}
+	clip := int(float64(len(gray.Pix)) * contrastClip)
+	low, high := 0, 255
+	for count := 0; low < 255 && count+histogram[low] <= clip; low++ {
+		count += histogram[low]
+	}
+	for count := 0; high > 0 && count+histogram[high] <= clip; high-- {
+		count += histogram[high]
+	}
+	if high <= low {
+		return gray
+	}
+
+	var lut [256]uint8
+	for i := range lut {
+		lut[i] = clamp(float64(i-low) * 255 / float64(high-low))
+	}
+	return applyLUT(gray, lut)
+}
+
+// applyLUT maps each pixel through a lookup table
+func applyLUT(gray *image.Gray, lut [256]uint8) *image.Gray {
+	out := image.NewGray(gray.Rect)
+	for i, v := range gray.Pix {
+		out.Pix[i] = lut[v]
+	}
+	return out
+}
+
+// clahe equalizes the histogram of each tile of the image, with the height of its bins limited to claheClipLimit,
+// and blends the mappings of the four nearest tiles of each pixel so there are no seams between tiles
+func clahe(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	tilesX, tilesY := min(claheTiles, w), min(claheTiles, h)
+	tileW, tileH := float64(w)/float64(tilesX), float64(h)/float64(tilesY)
+
+	// The mapping of each tile
+	luts := make([][256]uint8, tilesX*tilesY)
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			x0, x1 := int(float64(tx)*tileW), int(float64(tx+1)*tileW)
+			y0, y1 := int(float64(ty)*tileH), int(float64(ty+1)*tileH)
+			var histogram [256]int
+			for y := y0; y < y1; y++ {
+				for _, v := range gray.Pix[y*gray.Stride+x0 : y*gray.Stride+x1] {
+					histogram[v]++
+				}
+			}
+			luts[ty*tilesX+tx] = equalize(histogram, (x1-x0)*(y1-y0))
+		}
+	}
+
+	// Each pixel blends the mappings of the centers of the tiles around it
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		fy := math.Max(0, math.Min(float64(tilesY-1), (float64(y)+0.5)/tileH-0.5))
+		ty0 := int(fy)
+		ty1, wy := min(ty0+1, tilesY-1), fy-float64(ty0)
+		for x := 0; x < w; x++ {
+			fx := math.Max(0, math.Min(float64(tilesX-1), (float64(x)+0.5)/tileW-0.5))
+			tx0 := int(fx)
+			tx1, wx := min(tx0+1, tilesX-1), fx-float64(tx0)
+
+			v := gray.Pix[y*gray.Stride+x]
+			top := (1-wx)*float64(luts[ty0*tilesX+tx0][v]) + wx*float64(luts[ty0*tilesX+tx1][v])
+			bottom := (1-wx)*float64(luts[ty1*tilesX+tx0][v]) + wx*float64(luts[ty1*tilesX+tx1][v])
+			out.Pix[y*out.Stride+x] = clamp((1-wy)*top + wy*bottom)
+		}
+	}
+	return out
+}
+
+// equalize returns the histogram equalization of a tile, after clipping its bins and spreading the excess evenly
+func equalize(histogram [256]int, pixels int) [256]uint8 {
+	var lut [256]uint8
+	if pixels == 0 {
+		return lut
+	}
+	limit := max(1, int(claheClipLimit*float64(pixels)/256))
+	excess := 0
+	for i, n := range histogram {
+		if n > limit {
+			excess += n - limit
+			histogram[i] = limit
+		}
+	}
+	for i := range histogram {
+		histogram[i] += excess / 256
+		if i < excess%256 {
+			histogram[i]++
+		}
+	}
+
+	sum := 0
+	for i, n := range histogram {
+		sum += n
+		lut[i] = clamp(float64(sum) * 255 / float64(pixels))
+	}
+	return lut
+}
+
+// removeShadows divides each pixel by the brightness of the paper around it. The paper is estimated on a small copy
+// of the page, where the ink is removed by keeping the lightest pixel of each neighborhood.
+func removeShadows(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	paper := dilate(dilate(imaging.Sample(gray, shadowSampleSize)))
+	paper = boxBlur(paper)
+	background := image.NewGray(image.Rect(0, 0, w, h))
+	draw.BiLinear.Scale(background, background.Bounds(), paper, paper.Bounds(), draw.Src, nil)
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := float64(gray.Pix[y*gray.Stride+x])
+			bg := math.Max(1, float64(background.Pix[y*background.Stride+x]))
+			out.Pix[y*out.Stride+x] = clamp(v / bg * 255)
+		}
+	}
+	return out
+}
+
+// dilate replaces each pixel with the lightest pixel of its 3x3 neighborhood
+func dilate(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	out := image.NewGray(gray.Rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var lightest uint8
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := min(max(x+dx, 0), w-1), min(max(y+dy, 0), h-1)
+					lightest = max(lightest, gray.Pix[ny*gray.Stride+nx])
+				}
+			}
+			out.Pix[y*out.Stride+x] = lightest
+		}
+	}
+	return out
+}
+
+// boxBlur replaces each pixel with the mean of its 3x3 neighborhood
+func boxBlur(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	out := image.NewGray(gray.Rect)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sum := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := min(max(x+dx, 0), w-1), min(max(y+dy, 0), h-1)
+					sum += int(gray.Pix[ny*gray.Stride+nx])
+				}
+			}
+			out.Pix[y*out.Stride+x] = uint8(sum / 9)
+		}
+	}
+	return out
+}
+
+// binarize turns the image into black and white with Sauvola's threshold, which follows the mean and the deviation
+// of the neighborhood of each pixel, so faint pencil is kept where the paper is dark
+func binarize(gray *image.Gray) *image.Gray {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	radius := max(7, min(w, h)/80)
+
+	// Integral images of the values and their squares give the sums of any window at once
+	stride := w + 1
+	sums := make([]float64, stride*(h+1))
+	squares := make([]float64, stride*(h+1))
+	for y := 0; y < h; y++ {
+		var rowSum, rowSquares float64
+		for x := 0; x < w; x++ {
+			v := float64(gray.Pix[y*gray.Stride+x])
+			rowSum += v
+			rowSquares += v * v
+			sums[(y+1)*stride+x+1] = sums[y*stride+x+1] + rowSum
+			squares[(y+1)*stride+x+1] = squares[y*stride+x+1] + rowSquares
+		}
+	}
+	window := func(table []float64, x0, y0, x1, y1 int) float64 {
+		return table[y1*stride+x1] - table[y0*stride+x1] - table[y1*stride+x0] + table[y0*stride+x0]
+	}
+
+	out := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := max(0, y-radius), min(h, y+radius+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := max(0, x-radius), min(w, x+radius+1)
+			n := float64((x1 - x0) * (y1 - y0))
+			mean := window(sums, x0, y0, x1, y1) / n
+			deviation := math.Sqrt(math.Max(0, window(squares, x0, y0, x1, y1)/n-mean*mean))
+			threshold := mean * (1 + sauvolaK*(deviation/128-1))
+			if float64(gray.Pix[y*gray.Stride+x]) > threshold {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+	return out
+}
+
+// cropMargins cuts the image down to the rows and columns with ink, plus cropPadding. Rows and columns that are
+// mostly dark, such as the desk around a page or the shadow of a binding, do not count as ink.
+// The image is kept as it is when it has no ink.
+func cropMargins(img image.Image) image.Image {
+	gray := imaging.Gray(img)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	threshold := imaging.OtsuThreshold(gray.Pix)
+	rows, cols := make([]int, h), make([]int, w)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if gray.Pix[y*gray.Stride+x] < threshold {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+
+	top, bottom, ok := inkSpan(rows, w)
+	left, right, ok2 := inkSpan(cols, h)
+	if !ok || !ok2 {
+		return img
+	}
+	padX, padY := int(float64(w)*cropPadding), int(float64(h)*cropPadding)
+	rect := image.Rect(max(0, left-padX), max(0, top-padY), min(w, right+1+padX), min(h, bottom+1+padY))
+
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min.Add(rect.Min), draw.Src)
+	if _, ok := img.(*image.Gray); ok {
+		return imaging.Gray(out)
+	}
+	return out
+}
+
+// inkSpan returns the first and last position of a projection profile whose ink is above the noise of the paper
+// and below half of its length
+func inkSpan(profile []int, length int) (first, last int, ok bool) {
+	noise := max(1, length/200)
+	first, last = -1, -1
+	for i, n := range profile {
+		if n >= noise && n < length/2 {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	return first, last, first >= 0
+}
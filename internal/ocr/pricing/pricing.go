@@ -0,0 +1,142 @@
+package pricing
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Price is the price of a model in dollars per million tokens
+type Price struct {
+	Input float64
+	// CachedInput is the price of input tokens read from the provider's prompt cache
+	CachedInput float64
+	Output      float64
+}
+
+// Usage is the number of tokens billed for one or more requests
+type Usage struct {
+	// InputTokens are the input tokens that were not read from the prompt cache
+	InputTokens       int
+	CachedInputTokens int
+	OutputTokens      int
+}
+
+// Cost returns the price of the usage in dollars
+func (p Price) Cost(usage Usage) float64 {
+	return (float64(usage.InputTokens)*p.Input +
+		float64(usage.CachedInputTokens)*p.CachedInput +
+		float64(usage.OutputTokens)*p.Output) / 1e6
+}
+
+// Table maps model names to their prices. A model without an entry of its own uses the entry
+// of the longest name it starts with, so "gpt-4o-2024-08-06" is priced as "gpt-4o".
+type Table map[string]Price
+
+// defaultPrices are the published list prices of the models of each provider
+var defaultPrices = Table{
+	// OpenAI
+	"gpt-4o":       {Input: 2.5, CachedInput: 1.25, Output: 10},
+	"gpt-4o-mini":  {Input: 0.15, CachedInput: 0.075, Output: 0.6},
+	"gpt-4.1":      {Input: 2, CachedInput: 0.5, Output: 8},
+	"gpt-4.1-mini": {Input: 0.4, CachedInput: 0.1, Output: 1.6},
+	"gpt-4.1-nano": {Input: 0.1, CachedInput: 0.025, Output: 0.4},
+	"gpt-4-turbo":  {Input: 10, CachedInput: 10, Output: 30},
+	"gpt-5":        {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gpt-5-mini":   {Input: 0.25, CachedInput: 0.025, Output: 2},
+	"gpt-5-nano":   {Input: 0.05, CachedInput: 0.005, Output: 0.4},
+	"o4-mini":      {Input: 1.1, CachedInput: 0.275, Output: 4.4},
+
+	// Anthropic
+	"claude-opus-4":     {Input: 15, CachedInput: 1.5, Output: 75},
+	"claude-opus-4-5":   {Input: 5, CachedInput: 0.5, Output: 25},
+	"claude-sonnet-4":   {Input: 3, CachedInput: 0.3, Output: 15},
+	"claude-3-7-sonnet": {Input: 3, CachedInput: 0.3, Output: 15},
+	"claude-haiku-4-5":  {Input: 1, CachedInput: 0.1, Output: 5},
+	"claude-3-5-haiku":  {Input: 0.8, CachedInput: 0.08, Output: 4},
+
+	// Google Gemini
+	"gemini-2.5-pro":        {Input: 1.25, CachedInput: 0.125, Output: 10},
+	"gemini-2.5-flash":      {Input: 0.3, CachedInput: 0.03, Output: 2.5},
+	"gemini-2.5-flash-lite": {Input: 0.1, CachedInput: 0.01, Output: 0.4},
+	"gemini-2.0-flash":      {Input: 0.1, CachedInput: 0.025, Output: 0.4},
+	"gemini-2.0-flash-lite": {Input: 0.075, CachedInput: 0.075, Output: 0.3},
+}
+
+// ErrInvalidPricingFile is returned when a pricing file cannot be read or parsed
+var ErrInvalidPricingFile = fmt.Errorf("invalid pricing file")
+
+// Default returns a copy of the built-in price table
+func Default() Table {
+	table := make(Table, len(defaultPrices))
+	for model, price := range defaultPrices {
+		table[model] = price
+	}
+	return table
+}
+
+// Lookup returns the price of the model, or false when the table has no price for it
+func (t Table) Lookup(model string) (Price, bool) {
+	if price, ok := t[model]; ok {
+		return price, true
+	}
+
+	var price Price
+	var matched string
+	for name, p := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(matched) {
+			price, matched = p, name
+		}
+	}
+	return price, matched != ""
+}
+
+// Cost returns the price of the usage of the model in dollars, or 0 when the table has no price for it
+func (t Table) Cost(model string, usage Usage) float64 {
+	price, _ := t.Lookup(model)
+	return price.Cost(usage)
+}
+
+// filePrice is a price in a pricing file. The cached input price is optional and defaults to the input price.
+type filePrice struct {
+	Input       *float64 `yaml:"input"`
+	CachedInput *float64 `yaml:"cached-input"`
+	Output      *float64 `yaml:"output"`
+}
+
+// Load returns the built-in price table with the prices of the YAML file at path added on top.
+// The file maps model names to their prices in dollars per million tokens, e.g.
+//
+//	gpt-4o:
+//	  input: 2.5
+//	  cached-input: 1.25
+//	  output: 10
+func Load(path string) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPricingFile, err)
+	}
+
+	var prices map[string]filePrice
+	if err := yaml.Unmarshal(data, &prices); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPricingFile, path, err)
+	}
+
+	table := Default()
+	for model, p := range prices {
+		if p.Input == nil || p.Output == nil {
+			return nil, fmt.Errorf("%w: %s: %s needs an input and an output price", ErrInvalidPricingFile, path, model)
+		}
+		price := Price{Input: *p.Input, CachedInput: *p.Input, Output: *p.Output}
+		if p.CachedInput != nil {
+			price.CachedInput = *p.CachedInput
+		}
+		if price.Input < 0 || price.CachedInput < 0 || price.Output < 0 {
+			return nil, fmt.Errorf("%w: %s: %s has a negative price", ErrInvalidPricingFile, path, model)
+		}
+		table[model] = price
+	}
+	return table, nil
+}
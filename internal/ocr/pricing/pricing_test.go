@@ -0,0 +1,94 @@
+package pricing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable_Lookup(t *testing.T) {
+	table := Default()
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{model: "gpt-4o", want: "gpt-4o"},
+		{model: "gpt-4o-2024-08-06", want: "gpt-4o"},
+		{model: "gpt-4o-mini-2024-07-18", want: "gpt-4o-mini"},
+		{model: "claude-sonnet-4-5-20250929", want: "claude-sonnet-4"},
+		{model: "claude-opus-4-5", want: "claude-opus-4-5"},
+		{model: "gemini-2.5-flash-lite", want: "gemini-2.5-flash-lite"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, ok := table.Lookup(tt.model)
+			assert.True(t, ok)
+			assert.Equal(t, table[tt.want], price)
+		})
+	}
+
+	_, ok := table.Lookup("llama3.2-vision")
+	assert.False(t, ok)
+}
+
+func TestTable_Cost(t *testing.T) {
+	table := Default()
+
+	// 800K uncached and 200K cached input tokens at $2.50 and $1.25, and 100K output tokens at $10 per million
+	cost := table.Cost("gpt-4o", Usage{InputTokens: 800_000, CachedInputTokens: 200_000, OutputTokens: 100_000})
+	assert.InDelta(t, 2+0.25+1, cost, 1e-9)
+
+	assert.Zero(t, table.Cost("unknown", Usage{InputTokens: 1_000_000}))
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+gpt-4o:
+  input: 5
+  cached-input: 2.5
+  output: 15
+llama3.2-vision:
+  input: 0
+  output: 0
+my-model:
+  input: 1
+  output: 2
+`), 0644))
+
+	table, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, Price{Input: 5, CachedInput: 2.5, Output: 15}, table["gpt-4o"], "the file should override built-in prices")
+	assert.Equal(t, Price{Input: 1, CachedInput: 1, Output: 2}, table["my-model"], "the cached input price should default to the input price")
+	assert.Equal(t, Default()["claude-sonnet-4"], table["claude-sonnet-4"], "built-in prices should be kept")
+	_, ok := table.Lookup("llama3.2-vision")
+	assert.True(t, ok, "free models should be priced")
+
+	// The built-in table is not changed
+	assert.Equal(t, 2.5, Default()["gpt-4o"].Input)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+
+	tests := map[string]string{
+		"missing":        filepath.Join(dir, "missing.yaml"),
+		"invalid yaml":   write("invalid.yaml", "gpt-4o: ["),
+		"missing output": write("output.yaml", "gpt-4o:\n  input: 1\n"),
+		"negative price": write("negative.yaml", "gpt-4o:\n  input: -1\n  output: 1\n"),
+	}
+	for name, path := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(path)
+			assert.ErrorIs(t, err, ErrInvalidPricingFile)
+		})
+	}
+}
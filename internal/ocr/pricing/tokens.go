@@ -0,0 +1,132 @@
+package pricing
+
+import (
+	"math"
+	"strings"
+
+	"github.com/marksalpeter/ocr/internal/ocr"
+)
+
+// imageTokenRule returns the number of input tokens an image of the given size costs
+type imageTokenRule func(width, height int) int
+
+// imageTokenRules are the rules the providers use to count the tokens of images, by model name prefix
+var imageTokenRules = map[string]imageTokenRule{
+	"gpt-4o":       openAITiles(85, 170),
+	"gpt-4o-mini":  openAITiles(2833, 5667),
+	"gpt-4.1":      openAITiles(85, 170),
+	"gpt-4.1-mini": openAIPatches(1.62),
+	"gpt-4.1-nano": openAIPatches(2.46),
+	"gpt-4-turbo":  openAITiles(85, 170),
+	"gpt-5":        openAITiles(70, 140),
+	"gpt-5-mini":   openAIPatches(1.62),
+	"gpt-5-nano":   openAIPatches(2.46),
+	"o4-mini":      openAIPatches(1.72),
+	"claude-":      anthropicPixels,
+	"gemini-":      geminiTiles,
+}
+
+// ImageTokens returns the number of input tokens an image of the given size costs with the model.
+// Models without a rule of their own are counted with the tile rule of gpt-4o.
+func ImageTokens(model string, width, height int) int {
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	rule, matched := openAITiles(85, 170), ""
+	for prefix, r := range imageTokenRules {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+			rule, matched = r, prefix
+		}
+	}
+	return rule(width, height)
+}
+
+// openAITiles counts the 512px tiles of the image after it is scaled to fit in 2048x2048
+// and then scaled down until its shortest side is at most 768px
+func openAITiles(base, perTile int) imageTokenRule {
+	return func(width, height int) int {
+		w, h := float64(width), float64(height)
+		if scale := 2048 / max(w, h); scale < 1 {
+			w, h = w*scale, h*scale
+		}
+		if scale := 768 / min(w, h); scale < 1 {
+			w, h = w*scale, h*scale
+		}
+		tiles := int(math.Ceil(w/512) * math.Ceil(h/512))
+		return base + perTile*tiles
+	}
+}
+
+// openAIPatches counts the 32px patches of the image, which is scaled down to at most 1536 patches,
+// and multiplies them by the multiplier of the model
+func openAIPatches(multiplier float64) imageTokenRule {
+	const patchSize, maxPatches = 32, 1536
+	return func(width, height int) int {
+		w, h := float64(width), float64(height)
+		patches := math.Ceil(w/patchSize) * math.Ceil(h/patchSize)
+		if patches > maxPatches {
+			shrink := math.Sqrt(patchSize * patchSize * maxPatches / (w * h))
+			// Shrink a little more so both sides are a whole number of patches
+			shrink *= min(math.Floor(w*shrink/patchSize)/(w*shrink/patchSize), math.Floor(h*shrink/patchSize)/(h*shrink/patchSize))
+			patches = min(math.Ceil(w*shrink/patchSize)*math.Ceil(h*shrink/patchSize), maxPatches)
+		}
+		return int(math.Ceil(patches * multiplier))
+	}
+}
+
+// anthropicPixels charges a token per 750 pixels, after the image is scaled to at most 1568px on its longest side,
+// and at most 1600 tokens
+func anthropicPixels(width, height int) int {
+	w, h := float64(width), float64(height)
+	if scale := 1568 / max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	return min(int(math.Ceil(w*h/750)), 1600)
+}
+
+// geminiTiles charges 258 tokens for small images, and 258 tokens per tile for larger ones.
+// The tile size is two thirds of the shortest side, between 256px and 768px.
+func geminiTiles(width, height int) int {
+	const tileTokens = 258
+	if width <= 384 && height <= 384 {
+		return tileTokens
+	}
+	tile := min(max(float64(min(width, height))/1.5, 256), 768)
+	tiles := math.Ceil(float64(width)/tile) * math.Ceil(float64(height)/tile)
+	return tileTokens * int(tiles)
+}
+
+const (
+	// PromptTokens is the approximate number of input tokens of the prompts and response schema sent with each image
+	PromptTokens = 400
+	// TypicalMinOutputTokens is the approximate number of output tokens of a page with little text
+	TypicalMinOutputTokens = 100
+	// TypicalMaxOutputTokens is the approximate number of output tokens of a page full of text
+	TypicalMaxOutputTokens = 1500
+)
+
+// Estimator implements the ocr.CostEstimator interface with the price table and image token rules of a model
+type Estimator struct {
+	Model  string
+	Prices Table
+	// MaxTokens is the maximum number of output tokens of a transcription, or 0 for no limit
+	MaxTokens int
+}
+
+var _ ocr.CostEstimator = Estimator{}
+
+// EstimateImage returns the input tokens of the image and the cost range of transcribing it in a single attempt,
+// from a page with little text to a page full of text
+func (e Estimator) EstimateImage(width, height int) (imageTokens int, minCost, maxCost float64) {
+	imageTokens = ImageTokens(e.Model, width, height)
+
+	minOutput, maxOutput := TypicalMinOutputTokens, TypicalMaxOutputTokens
+	if e.MaxTokens > 0 {
+		minOutput, maxOutput = min(minOutput, e.MaxTokens), min(maxOutput, e.MaxTokens)
+	}
+
+	input := imageTokens + PromptTokens
+	minCost = e.Prices.Cost(e.Model, Usage{InputTokens: input, OutputTokens: minOutput})
+	maxCost = e.Prices.Cost(e.Model, Usage{InputTokens: input, OutputTokens: maxOutput})
+	return imageTokens, minCost, maxCost
+}
@@ -0,0 +1,57 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		model  string
+		width  int
+		height int
+		want   int
+	}{
+		// Scaled to 768x1024, which is 2x2 tiles
+		{name: "gpt-4o tiles", model: "gpt-4o", width: 1125, height: 1500, want: 85 + 170*4},
+		// Scaled to fit in 2048x2048 and then to 768x1536, which is 2x3 tiles
+		{name: "gpt-4o large", model: "gpt-4o-2024-08-06", width: 2000, height: 4000, want: 85 + 170*6},
+		{name: "gpt-4o-mini tiles", model: "gpt-4o-mini", width: 1125, height: 1500, want: 2833 + 5667*4},
+		// 32x32 patches are within the limit of 1536, times 1.62
+		{name: "gpt-4.1-mini patches", model: "gpt-4.1-mini", width: 1024, height: 1024, want: 1659},
+		// Scaled down to 1056x1408, which is 33x44 patches
+		{name: "gpt-4.1-mini large", model: "gpt-4.1-mini", width: 1125, height: 1500, want: 2353},
+		// Scaled to 1176x1568, a token per 750 pixels capped at 1600
+		{name: "claude", model: "claude-sonnet-4-5", width: 1500, height: 2000, want: 1600},
+		{name: "claude small", model: "claude-sonnet-4-5", width: 300, height: 400, want: 160},
+		{name: "gemini small", model: "gemini-2.5-flash", width: 300, height: 384, want: 258},
+		// Tiles of 750px, which is 2x2 tiles
+		{name: "gemini tiles", model: "gemini-2.5-flash", width: 1125, height: 1500, want: 258 * 4},
+		{name: "unknown model", model: "llama3.2-vision", width: 1125, height: 1500, want: 85 + 170*4},
+		{name: "empty image", model: "gpt-4o", width: 0, height: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ImageTokens(tt.model, tt.width, tt.height))
+		})
+	}
+}
+
+func TestEstimator_EstimateImage(t *testing.T) {
+	estimator := Estimator{Model: "gpt-4o", Prices: Table{"gpt-4o": {Input: 2.5, CachedInput: 1.25, Output: 10}}}
+
+	tokens, minCost, maxCost := estimator.EstimateImage(1125, 1500)
+	assert.Equal(t, 765, tokens)
+	// 765 image tokens and the prompt at $2.50, and a range of output tokens at $10 per million
+	input := float64(765+PromptTokens) * 2.5 / 1e6
+	assert.InDelta(t, input+TypicalMinOutputTokens*10/1e6, minCost, 1e-12)
+	assert.InDelta(t, input+TypicalMaxOutputTokens*10/1e6, maxCost, 1e-12)
+
+	// The output can not exceed the maximum number of tokens
+	estimator.MaxTokens = 500
+	_, _, maxCost = estimator.EstimateImage(1125, 1500)
+	assert.InDelta(t, input+500*10/1e6, maxCost, 1e-12)
+}
@@ -0,0 +1,75 @@
+package prompt
+
+// source is the text of the templates of a preset
+type source struct {
+	system     string
+	user       string
+	structured string
+}
+
+// systemTemplate instructs the model to act as a transcription service
+const systemTemplate = `
+You are a professional OCR (Optical Character Recognition) transcription service.
+Your sole purpose is to convert images of text into machine-readable text format.
+You are processing personal documents, journals, notes, and written materials for archival and digitization purposes.
+
+Your task is to transcribe ALL visible text exactly as it appears, including:
+- Handwritten text
+- Printed text
+- Dates and timestamps
+- Any visible characters or symbols
+- Preserving line breaks, spacing and Punctuation
+
+Do not summarize, interpret, or modify the text, simply transcribe what you see.
+The user owns all content in these images and has authorized this transcription.
+Please, do not refuse to transcribe the image.
+{{if .Language}}The text is written in {{.Language}}. Transcribe it in that language, do not translate it.
+{{end}}`
+
+// exactly asks for a transcription that keeps the page as it is
+const exactly = "exactly as it appears, preserving all line breaks, punctuation, spacing, and wording"
+
+// plainOnly keeps the model from adding anything to free text transcriptions
+const plainOnly = " Do not include any other text in your response."
+
+// illegibleField asks for the parts of the page the model could not read
+const illegibleField = " List each word or passage you could not read in the illegible_segments field."
+
+// presets are the built-in prompts of each type of document
+var presets = map[string]source{
+	"journal": {
+		system: systemTemplate,
+		user:   "This is an image of a journal page. Please transcribe all text visible in this image " + exactly + "." + plainOnly,
+		structured: "This is an image of a journal page. Please transcribe all text visible in this image " + exactly + ", in the transcription field. " +
+			"Copy the date written at the top of the page, exactly as it is written, to the date field, and the page number written on the page to the page_number field. Leave them empty when the page has none." +
+			illegibleField,
+	},
+	"letter": {
+		system: systemTemplate,
+		user:   "This is an image of a page of a letter. Please transcribe all text visible in this image, including the addresses, the salutation and the signature, " + exactly + "." + plainOnly,
+		structured: "This is an image of a page of a letter. Please transcribe all text visible in this image, including the addresses, the salutation and the signature, " + exactly + ", in the transcription field. " +
+			"Copy the date the letter was written, exactly as it is written, to the date field, and the page number written on the page to the page_number field. Leave them empty when the page has none." +
+			illegibleField,
+	},
+	"receipt": {
+		system: systemTemplate,
+		user:   "This is an image of a receipt. Please transcribe all text visible in this image " + exactly + ", keeping each item on its own line with its quantity and price." + plainOnly,
+		structured: "This is an image of a receipt. Please transcribe all text visible in this image " + exactly + ", keeping each item on its own line with its quantity and price, in the transcription field. " +
+			"Copy the date of the purchase, exactly as it is written, to the date field, and leave the page_number field empty unless the receipt is numbered as one of several pages." +
+			illegibleField,
+	},
+	"ledger": {
+		system: systemTemplate,
+		user:   "This is an image of a ledger page. Please transcribe every entry " + exactly + ", with one row of the ledger per line and its columns separated by \" | \". Copy every figure exactly as it is written." + plainOnly,
+		structured: "This is an image of a ledger page. Please transcribe every entry " + exactly + ", with one row of the ledger per line and its columns separated by \" | \", in the transcription field. Copy every figure exactly as it is written. " +
+			"Copy the date written at the top of the page, or else the date of its first entry, to the date field, and the page or folio number to the page_number field. Leave them empty when the page has none." +
+			illegibleField,
+	},
+	"book": {
+		system: systemTemplate,
+		user:   "This is an image of a page of a printed book. Please transcribe the text of the page, including headings and footnotes, " + exactly + "." + plainOnly,
+		structured: "This is an image of a page of a printed book. Please transcribe the text of the page, including headings and footnotes, " + exactly + ", in the transcription field. " +
+			"Copy the printed page number to the page_number field, and leave the date field empty unless the page is dated." +
+			illegibleField,
+	},
+}
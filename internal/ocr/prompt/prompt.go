@@ -0,0 +1,157 @@
+package prompt
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// Vars are the values available to prompt templates, e.g. {{.ImageName}}
+type Vars struct {
+	// ImageName is the name of the image being transcribed
+	ImageName string
+	// PageIndex is the position of the image in the run, starting at 1, which the pages of a split spread share
+	PageIndex int
+	// Language is the language the pages are written in, or empty when it is not known
+	Language string
+}
+
+// Rendered is a prompt with its variables filled in
+type Rendered struct {
+	System string
+	// User asks for a free text transcription
+	User string
+	// Structured asks for a transcription in the response schema
+	Structured string
+}
+
+// Prompt is the set of templates used to ask a model for a transcription
+type Prompt struct {
+	name       string
+	system     *template.Template
+	user       *template.Template
+	structured *template.Template
+	hash       string
+}
+
+var (
+	// ErrUnknownPreset is returned when there is no preset with the requested name
+	ErrUnknownPreset = fmt.Errorf("unknown prompt preset")
+	// ErrInvalidTemplate is returned when a prompt template file cannot be read, parsed or executed
+	ErrInvalidTemplate = fmt.Errorf("invalid prompt template")
+)
+
+// DefaultPreset is the preset used when none is selected
+const DefaultPreset = "journal"
+
+// Presets returns the names of the built-in presets in alphabetical order
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Default returns the default preset
+func Default() *Prompt {
+	p, _ := Preset(DefaultPreset)
+	return p
+}
+
+// Preset returns the built-in preset with the given name
+func Preset(name string) (*Prompt, error) {
+	src, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s, must be one of %s", ErrUnknownPreset, name, strings.Join(Presets(), ", "))
+	}
+	hash := sha256.Sum256([]byte(src.system + "\x00" + src.user + "\x00" + src.structured))
+	return &Prompt{
+		name:       name,
+		system:     template.Must(template.New("system").Parse(src.system)),
+		user:       template.Must(template.New("user").Parse(src.user)),
+		structured: template.Must(template.New("structured").Parse(src.structured)),
+		hash:       fmt.Sprintf("%x", hash[:8]),
+	}, nil
+}
+
+// Load returns base with the templates of the text/template file at path in place of its own.
+// The file replaces the system, user or structured prompt by defining a template of that name, e.g.
+//
+//	{{define "user"}}This is a page of a letter written in {{.Language}}. Transcribe it exactly.{{end}}
+//
+// Text outside of any definition replaces both the user and the structured prompt.
+// The prompts the file does not replace are those of base.
+func Load(path string, base *Prompt) (*Prompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	t, err := template.New(filepath.Base(path)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+
+	hash := sha256.Sum256([]byte(base.hash + "\x00" + string(data)))
+	p := &Prompt{
+		name:       filepath.Base(path),
+		system:     base.system,
+		user:       base.user,
+		structured: base.structured,
+		hash:       fmt.Sprintf("%x", hash[:8]),
+	}
+
+	// Unknown variables only fail when the template is executed, so every template is tried once
+	var body strings.Builder
+	if err := t.Execute(&body, Vars{}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	if strings.TrimSpace(body.String()) != "" {
+		p.user, p.structured = t, t
+	}
+	for name, tmpl := range map[string]**template.Template{"system": &p.system, "user": &p.user, "structured": &p.structured} {
+		if defined := t.Lookup(name); defined != nil {
+			if err := defined.Execute(io.Discard, Vars{}); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+			}
+			*tmpl = defined
+		}
+	}
+	return p, nil
+}
+
+// Name is the name of the preset, or the file name of a loaded template
+func (p *Prompt) Name() string {
+	return p.name
+}
+
+// Hash identifies the templates, so results are only reused when the prompts would not change
+func (p *Prompt) Hash() string {
+	return p.hash
+}
+
+// Render fills the variables into the templates
+func (p *Prompt) Render(vars Vars) (Rendered, error) {
+	var rendered Rendered
+	for _, t := range []struct {
+		tmpl *template.Template
+		out  *string
+	}{
+		{p.system, &rendered.System},
+		{p.user, &rendered.User},
+		{p.structured, &rendered.Structured},
+	} {
+		var b strings.Builder
+		if err := t.tmpl.Execute(&b, vars); err != nil {
+			return Rendered{}, fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		*t.out = b.String()
+	}
+	return rendered, nil
+}
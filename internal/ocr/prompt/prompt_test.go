@@ -0,0 +1,101 @@
+package prompt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreset(t *testing.T) {
+	assert.Equal(t, []string{"book", "journal", "ledger", "letter", "receipt"}, Presets())
+
+	for _, name := range Presets() {
+		t.Run(name, func(t *testing.T) {
+			p, err := Preset(name)
+			assert.NoError(t, err)
+			assert.Equal(t, name, p.Name())
+
+			rendered, err := p.Render(Vars{})
+			assert.NoError(t, err)
+			assert.NotEmpty(t, rendered.System)
+			assert.NotEmpty(t, rendered.User)
+			assert.Contains(t, rendered.Structured, "transcription field")
+		})
+	}
+
+	_, err := Preset("diary")
+	assert.ErrorIs(t, err, ErrUnknownPreset)
+
+	journal, _ := Preset("journal")
+	letter, _ := Preset("letter")
+	assert.Equal(t, Default().Hash(), journal.Hash())
+	assert.NotEqual(t, journal.Hash(), letter.Hash())
+}
+
+func TestPrompt_Render_Language(t *testing.T) {
+	rendered, err := Default().Render(Vars{Language: "German"})
+	assert.NoError(t, err)
+	assert.Contains(t, rendered.System, "The text is written in German.")
+
+	rendered, err = Default().Render(Vars{})
+	assert.NoError(t, err)
+	assert.NotContains(t, rendered.System, "The text is written in")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		return path
+	}
+	vars := Vars{ImageName: "letter-03.jpg", PageIndex: 3, Language: "French"}
+
+	t.Run("defined templates", func(t *testing.T) {
+		path := write("defined.tmpl", `{{define "system"}}You transcribe letters in {{.Language}}.{{end}}`+
+			`{{define "user"}}Transcribe page {{.PageIndex}} ({{.ImageName}}).{{end}}`)
+		p, err := Load(path, Default())
+		assert.NoError(t, err)
+		assert.Equal(t, "defined.tmpl", p.Name())
+		assert.NotEqual(t, Default().Hash(), p.Hash())
+
+		rendered, err := p.Render(vars)
+		assert.NoError(t, err)
+		assert.Equal(t, "You transcribe letters in French.", rendered.System)
+		assert.Equal(t, "Transcribe page 3 (letter-03.jpg).", rendered.User)
+
+		// The structured prompt is not defined, so it is the one of the preset
+		base, _ := Default().Render(vars)
+		assert.Equal(t, base.Structured, rendered.Structured)
+	})
+
+	t.Run("plain text", func(t *testing.T) {
+		path := write("plain.tmpl", "Transcribe the recipe card {{.ImageName}}.\n")
+		p, err := Load(path, Default())
+		assert.NoError(t, err)
+
+		rendered, err := p.Render(vars)
+		assert.NoError(t, err)
+		assert.Equal(t, "Transcribe the recipe card letter-03.jpg.\n", rendered.User)
+		assert.Equal(t, rendered.User, rendered.Structured)
+		base, _ := Default().Render(vars)
+		assert.Equal(t, base.System, rendered.System)
+	})
+
+	t.Run("unknown variable", func(t *testing.T) {
+		_, err := Load(write("unknown.tmpl", `{{define "user"}}Transcribe {{.Author}}'s page.{{end}}`), Default())
+		assert.ErrorIs(t, err, ErrInvalidTemplate)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		_, err := Load(write("syntax.tmpl", "Transcribe {{.ImageName"), Default())
+		assert.ErrorIs(t, err, ErrInvalidTemplate)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Load(filepath.Join(dir, "missing.tmpl"), Default())
+		assert.ErrorIs(t, err, ErrInvalidTemplate)
+	})
+}
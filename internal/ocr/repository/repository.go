@@ -4,21 +4,48 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+
+	"github.com/marksalpeter/ocr/internal/ocr/pdf"
 )
 
+// Config holds the optional settings of the Repository
+type Config struct {
+	// Include are the glob patterns of the images to list, e.g. *.jpg, or empty to list every image
+	Include []string
+	// Exclude are the glob patterns of the images and directories to leave out, e.g. drafts
+	Exclude []string
+	// MaxDepth is how many levels of directories are searched for images, 1 for the base directory alone,
+	// or 0 for no limit
+	MaxDepth int
+}
+
 // Repository implements the ocr.Repository interface for file operations
 type Repository struct {
 	baseDir    string
 	outputPath string
+	config     Config
+
+	mu sync.Mutex
+	// documents are the PDF files opened most recently, by file name
+	documents map[string]*pdf.Document
+	// recent are the names of the open documents, from the least to the most recently used
+	recent []string
 }
 
+// maxOpenDocuments is the number of parsed PDF files kept for the pages loaded after them. Pages are loaded
+// roughly in order, so only the few files the workers are on are needed at once.
+const maxOpenDocuments = 4
+
 // New creates a new Repository instance with the specified base directory and output path.
 // If baseDir is empty, it defaults to the current working directory.
 // If outputPath is relative, it will be joined with baseDir.
-func New(baseDir, outputPath string) (*Repository, error) {
+func New(baseDir, outputPath string, config Config) (*Repository, error) {
 	if baseDir == "" {
 		wd, _ := os.Getwd()
 		baseDir = wd
@@ -29,6 +56,12 @@ func New(baseDir, outputPath string) (*Repository, error) {
 		outputPath = filepath.Join(baseDir, outputPath)
 	}
 
+	for _, pattern := range slices.Concat(config.Include, config.Exclude) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPattern, pattern)
+		}
+	}
+
 	// Check if image directory exists
 	if info, err := os.Stat(baseDir); err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrDirectoryNotFound, err)
@@ -39,6 +72,8 @@ func New(baseDir, outputPath string) (*Repository, error) {
 	return &Repository{
 		baseDir:    baseDir,
 		outputPath: outputPath,
+		config:     config,
+		documents:  map[string]*pdf.Document{},
 	}, nil
 }
 
@@ -49,30 +84,61 @@ var (
 	ErrImageNotFound = fmt.Errorf("image not found")
 	// ErrFailedToSave is returned when saving output fails
 	ErrFailedToSave = fmt.Errorf("failed to save output")
+	// ErrPageExtraction is returned when a page of a PDF or TIFF file cannot be extracted
+	ErrPageExtraction = fmt.Errorf("failed to extract page")
+	// ErrInvalidPattern is returned when an include or exclude pattern is not a valid glob pattern
+	ErrInvalidPattern = fmt.Errorf("invalid pattern")
 )
 
-// GetImageNames returns sorted image filenames from the repository's base directory.
-func (r *Repository) GetImageNames() ([]string, error) {
-	var imageNames []string
-	imageExts := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".bmp":  true,
-		".webp": true,
-	}
+// pageSeparator separates the name of a PDF or TIFF file from the number of one of its pages, e.g. scan.pdf#p3
+const pageSeparator = "#p"
 
-	err := filepath.WalkDir(r.baseDir, func(path string, d fs.DirEntry, err error) error {
+// imageExts are the extensions of the files that are listed as images
+var imageExts = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".webp": true,
+	".tif":  true,
+	".tiff": true,
+	".pdf":  true,
+}
+
+// GetImageNames returns the paths of the images in the repository's base directory and its subdirectories,
+// relative to the base directory and separated by slashes, e.g. vol1/IMG_0001.jpg. The images of each
+// directory are sorted by name and listed together, those of the base directory first, then those of each
+// subdirectory in order. The include and exclude patterns and the max depth of the config select the images.
+// Each page of a PDF file or multi-page TIFF file is listed in page order as an image of its own,
+// named after the file and the page, e.g. scan.pdf#p1, scan.pdf#p2. A PDF file that cannot be read
+// is listed by its own name, so the error is reported when it is loaded.
+func (r *Repository) GetImageNames() ([]string, error) {
+	var fileNames []string
+	err := filepath.WalkDir(r.baseDir, func(filePath string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
+		rel, err := filepath.Rel(r.baseDir, filePath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
 		if d.IsDir() {
+			if name == "." {
+				return nil
+			}
+			// The images of a directory are one level deeper than the directory
+			if matchesAny(r.config.Exclude, name) || (r.config.MaxDepth > 0 && depth(name) >= r.config.MaxDepth) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		ext := strings.ToLower(filepath.Ext(path))
-		if imageExts[ext] {
-			imageNames = append(imageNames, filepath.Base(path))
+		if !imageExts[strings.ToLower(filepath.Ext(name))] || matchesAny(r.config.Exclude, name) {
+			return nil
+		}
+		if len(r.config.Include) == 0 || matchesAny(r.config.Include, name) {
+			fileNames = append(fileNames, name)
 		}
 		return nil
 	})
@@ -81,16 +147,41 @@ func (r *Repository) GetImageNames() ([]string, error) {
 		return nil, fmt.Errorf("failed to walk directory: %w", err)
 	}
 
-	// Sort alphabetically
-	sort.Strings(imageNames)
+	// Sort by directory, then by name, then list the pages of each PDF and TIFF file in order
+	slices.SortFunc(fileNames, compareNames)
+	imageNames := make([]string, 0, len(fileNames))
+	for _, name := range fileNames {
+		pages := r.pageCount(name)
+		if pages == 0 {
+			imageNames = append(imageNames, name)
+			continue
+		}
+		for page := 1; page <= pages; page++ {
+			imageNames = append(imageNames, name+pageSeparator+strconv.Itoa(page))
+		}
+	}
 
 	return imageNames, nil
 }
 
-// LoadImageByName loads image data by filename from the repository's base directory.
+// LoadImageByName loads image data by its path relative to the repository's base directory.
+// The name of a page, e.g. scan.pdf#p3, loads the image of that page.
 func (r *Repository) LoadImageByName(filename string) ([]byte, error) {
-	path := filepath.Join(r.baseDir, filename)
-	data, err := os.ReadFile(path)
+	if name, page, ok := splitPageName(filename); ok {
+		return r.loadPage(name, page)
+	}
+	if isPDF(filename) {
+		return r.loadPage(filename, 1)
+	}
+	return r.readFile(filename)
+}
+
+// readFile reads a file by its path relative to the repository's base directory
+func (r *Repository) readFile(filename string) ([]byte, error) {
+	if !filepath.IsLocal(filepath.FromSlash(filename)) {
+		return nil, fmt.Errorf("%w: %s", ErrImageNotFound, filename)
+	}
+	data, err := os.ReadFile(filepath.Join(r.baseDir, filepath.FromSlash(filename)))
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("%w: %s", ErrImageNotFound, filename)
@@ -100,6 +191,141 @@ func (r *Repository) LoadImageByName(filename string) ([]byte, error) {
 	return data, nil
 }
 
+// pageCount returns the number of pages of a PDF file or multi-page TIFF file.
+// It is 0 for other images, single page TIFF files and files that cannot be read.
+func (r *Repository) pageCount(filename string) int {
+	switch {
+	case isPDF(filename):
+		if doc, err := r.openPDF(filename); err == nil {
+			return doc.PageCount()
+		}
+	case isTIFF(filename):
+		data, err := r.readFile(filename)
+		if err != nil {
+			return 0
+		}
+		if pages, err := tiffPages(data); err == nil && len(pages) > 1 {
+			return len(pages)
+		}
+	}
+	return 0
+}
+
+// loadPage extracts the image of a page of a PDF or TIFF file
+func (r *Repository) loadPage(filename string, page int) ([]byte, error) {
+	if isTIFF(filename) {
+		data, err := r.readFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = tiffPage(data, page); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrPageExtraction, filename, err)
+		}
+		return data, nil
+	}
+
+	doc, err := r.openPDF(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err := doc.PageImage(page)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPageExtraction, filename, err)
+	}
+	return data, nil
+}
+
+// openPDF parses a PDF file and keeps it for the pages loaded after it, releasing the least recently used
+// file when more than maxOpenDocuments are open
+func (r *Repository) openPDF(filename string) (*pdf.Document, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if doc, ok := r.documents[filename]; ok {
+		i := slices.Index(r.recent, filename)
+		r.recent = append(slices.Delete(r.recent, i, i+1), filename)
+		return doc, nil
+	}
+
+	data, err := r.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := pdf.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrPageExtraction, filename, err)
+	}
+	r.documents[filename] = doc
+	r.recent = append(r.recent, filename)
+	if len(r.recent) > maxOpenDocuments {
+		delete(r.documents, r.recent[0])
+		r.recent = slices.Delete(r.recent, 0, 1)
+	}
+	return doc, nil
+}
+
+// matchesAny reports whether a path relative to the base directory matches any of the patterns.
+// Patterns with a slash match the whole path, e.g. vol1/*.jpg, and other patterns match the last element
+// of the path, e.g. *.jpg or drafts.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		target := path.Base(name)
+		if strings.Contains(pattern, "/") {
+			target = name
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// depth returns the number of elements of a path relative to the base directory, e.g. 2 for vol1/IMG_0001.jpg
+func depth(name string) int {
+	return strings.Count(name, "/") + 1
+}
+
+// compareNames orders paths relative to the base directory by their directory, element by element,
+// and then by their file name, so the files of a directory come before those of its subdirectories
+func compareNames(a, b string) int {
+	dirA, dirB := path.Dir(a), path.Dir(b)
+	if dirA != dirB {
+		return slices.Compare(dirElements(dirA), dirElements(dirB))
+	}
+	return strings.Compare(path.Base(a), path.Base(b))
+}
+
+// dirElements splits a directory relative to the base directory into its elements, with none for the base directory
+func dirElements(dir string) []string {
+	if dir == "." {
+		return nil
+	}
+	return strings.Split(dir, "/")
+}
+
+// isPDF reports whether the file is a PDF file
+func isPDF(filename string) bool {
+	return strings.ToLower(filepath.Ext(filename)) == ".pdf"
+}
+
+// isTIFF reports whether the file is a TIFF file
+func isTIFF(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	return ext == ".tif" || ext == ".tiff"
+}
+
+// splitPageName splits the name of a page, e.g. scan.pdf#p3, into the name of the file and the page number
+func splitPageName(name string) (string, int, bool) {
+	i := strings.LastIndex(name, pageSeparator)
+	if i < 0 || !(isPDF(name[:i]) || isTIFF(name[:i])) {
+		return "", 0, false
+	}
+	page, err := strconv.Atoi(name[i+len(pageSeparator):])
+	if err != nil || page < 1 {
+		return "", 0, false
+	}
+	return name[:i], page, true
+}
+
 // SaveOutput saves the output text to the repository's configured output path
 func (r *Repository) SaveOutput(content string) error {
 	err := os.WriteFile(r.outputPath, []byte(content), 0644)
@@ -108,3 +334,66 @@ func (r *Repository) SaveOutput(content string) error {
 	}
 	return nil
 }
+
+// SaveOutputs saves each output to its own file in the directory of the configured output path.
+// The files are named after the output keys and use the extension of the output path,
+// e.g. the key "2024-01-01" with the output path "journal.md" is saved to "2024-01-01.md".
+func (r *Repository) SaveOutputs(outputs map[string]string) error {
+	return r.saveOutputs(filepath.Dir(r.outputPath), outputs)
+}
+
+// SaveDirectoryOutput saves the output of the images of a subdirectory of the base directory, e.g. vol1,
+// under the file name of the configured output path in the same subdirectory of the output's directory,
+// e.g. vol1/output.txt. The subdirectory is created when it does not exist.
+func (r *Repository) SaveDirectoryOutput(dir, content string) error {
+	outputDir, err := r.outputDir(dir)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, filepath.Base(r.outputPath)), []byte(content), 0644); err != nil {
+		return fmt.Errorf("%w: %v", ErrFailedToSave, err)
+	}
+	return nil
+}
+
+// SaveDirectoryOutputs saves each output of the images of a subdirectory of the base directory to its own file
+// named after its key, like SaveOutputs, in the same subdirectory of the output's directory, e.g. vol1/2024-01-01.md
+func (r *Repository) SaveDirectoryOutputs(dir string, outputs map[string]string) error {
+	outputDir, err := r.outputDir(dir)
+	if err != nil {
+		return err
+	}
+	return r.saveOutputs(outputDir, outputs)
+}
+
+// outputDir creates the subdirectory of the output's directory with the path of a subdirectory of the base directory
+func (r *Repository) outputDir(dir string) (string, error) {
+	if dir == "." || !filepath.IsLocal(filepath.FromSlash(dir)) {
+		return "", fmt.Errorf("%w: invalid output directory %q", ErrFailedToSave, dir)
+	}
+	outputDir := filepath.Join(filepath.Dir(r.outputPath), filepath.FromSlash(dir))
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrFailedToSave, err)
+	}
+	return outputDir, nil
+}
+
+// saveOutputs saves each output to its own file in the directory, named after its key with the extension of the
+// configured output path
+func (r *Repository) saveOutputs(dir string, outputs map[string]string) error {
+	ext := filepath.Ext(r.outputPath)
+	for name, content := range outputs {
+		if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+			return fmt.Errorf("%w: invalid output name %q", ErrFailedToSave, name)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+ext), []byte(content), 0644); err != nil {
+			return fmt.Errorf("%w: %v", ErrFailedToSave, err)
+		}
+	}
+	return nil
+}
+
+// OutputPath returns the path the output is saved to
+func (r *Repository) OutputPath() string {
+	return r.outputPath
+}
@@ -1,9 +1,17 @@
 package repository
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"golang.org/x/image/tiff"
 )
 
 func TestRepository_GetImageNames(t *testing.T) {
@@ -14,7 +22,7 @@ func TestRepository_GetImageNames(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	repo, err := New(tmpDir, "")
+	repo, err := New(tmpDir, "", Config{})
 	if err != nil {
 		t.Fatalf("Failed to create repository: %v", err)
 	}
@@ -53,7 +61,7 @@ func TestRepository_GetImageNames(t *testing.T) {
 	}
 
 	// Test non-existent directory
-	badRepo, err := New("/nonexistent/dir", "")
+	badRepo, err := New("/nonexistent/dir", "", Config{})
 	if err == nil {
 		t.Error("Expected error for non-existent directory")
 	}
@@ -75,7 +83,7 @@ func TestRepository_LoadImageByName(t *testing.T) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	repo, err := New(tmpDir, "")
+	repo, err := New(tmpDir, "", Config{})
 	if err != nil {
 		t.Fatalf("Failed to create repository: %v", err)
 	}
@@ -108,6 +116,265 @@ func TestRepository_LoadImageByName(t *testing.T) {
 	}
 }
 
+func TestRepository_Subdirectories(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, f := range []string{"Img-0002.jpg", "vol1/Img-0001.jpg", "vol1/Img-0002.jpg", "vol1/notes/Img-0001.jpg", "vol1-b/Img-0001.png", "vol2/Img-0001.jpg", "vol2/drafts/Img-0009.jpg", "vol2/notes.txt"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create test directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(f), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   []string
+	}{
+		{
+			// The images of a directory are listed together, before those of its subdirectories
+			name: "all images",
+			want: []string{"Img-0002.jpg", "vol1/Img-0001.jpg", "vol1/Img-0002.jpg", "vol1/notes/Img-0001.jpg", "vol1-b/Img-0001.png", "vol2/Img-0001.jpg", "vol2/drafts/Img-0009.jpg"},
+		},
+		{
+			name:   "max depth",
+			config: Config{MaxDepth: 2},
+			want:   []string{"Img-0002.jpg", "vol1/Img-0001.jpg", "vol1/Img-0002.jpg", "vol1-b/Img-0001.png", "vol2/Img-0001.jpg"},
+		},
+		{
+			name:   "base directory only",
+			config: Config{MaxDepth: 1},
+			want:   []string{"Img-0002.jpg"},
+		},
+		{
+			name:   "include",
+			config: Config{Include: []string{"*.png", "vol2/*"}},
+			want:   []string{"vol1-b/Img-0001.png", "vol2/Img-0001.jpg"},
+		},
+		{
+			name:   "exclude",
+			config: Config{Exclude: []string{"drafts", "notes", "Img-0002.jpg"}},
+			want:   []string{"vol1/Img-0001.jpg", "vol1-b/Img-0001.png", "vol2/Img-0001.jpg"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, err := New(tmpDir, "", tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create repository: %v", err)
+			}
+			names, err := repo.GetImageNames()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, names)
+			}
+		})
+	}
+
+	// Images of the same name in different directories are loaded by their path
+	repo, err := New(tmpDir, "", Config{})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	for _, name := range []string{"vol1/Img-0001.jpg", "vol2/Img-0001.jpg"} {
+		data, err := repo.LoadImageByName(name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(data) != name {
+			t.Errorf("Expected %s, got %s", name, string(data))
+		}
+	}
+	if _, err := repo.LoadImageByName("../Img-0001.jpg"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Expected ErrImageNotFound for a path outside the base directory, got %v", err)
+	}
+
+	if _, err := New(tmpDir, "", Config{Exclude: []string{"[drafts"}}); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+}
+
+// writePDF writes a PDF file with a page for each gray value, scanned as a 1x1 image of that value
+func writePDF(t *testing.T, path string, grays ...byte) {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	kids := ""
+	for i := range grays {
+		kids += fmt.Sprintf("%d 0 R ", 3+2*i)
+	}
+	fmt.Fprintf(&b, "2 0 obj\n<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", kids, len(grays))
+	for i, gray := range grays {
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Type /Page /Parent 2 0 R /Resources << /XObject << /Im0 %d 0 R >> >> >>\nendobj\n", 3+2*i, 4+2*i)
+		fmt.Fprintf(&b, "%d 0 obj\n<< /Subtype /Image /Width 1 /Height 1 /ColorSpace /DeviceGray /BitsPerComponent 8 /Length 1 >>\nstream\n", 4+2*i)
+		b.WriteByte(gray)
+		b.WriteString("\nendstream\nendobj\n")
+	}
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+func TestRepository_PDF(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo, err := New(tmpDir, "", Config{})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	writePDF(t, filepath.Join(tmpDir, "b-scan.pdf"), 10, 20, 30)
+	for _, f := range []string{"a.jpg", "c.png", "broken.pdf"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, f), []byte("test"), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// Each page is listed in page order between the files before and after the PDF
+	names, err := repo.GetImageNames()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"a.jpg", "b-scan.pdf#p1", "b-scan.pdf#p2", "b-scan.pdf#p3", "broken.pdf", "c.png"}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	// Each page loads the image scanned on it
+	for i, gray := range []uint8{10, 20, 30} {
+		data, err := repo.LoadImageByName(fmt.Sprintf("b-scan.pdf#p%d", i+1))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to decode page %d: %v", i+1, err)
+		}
+		if got := img.(*image.Gray).GrayAt(0, 0).Y; got != gray {
+			t.Errorf("Expected gray %d on page %d, got %d", gray, i+1, got)
+		}
+	}
+
+	// Errors
+	if _, err := repo.LoadImageByName("b-scan.pdf#p4"); !errors.Is(err, ErrPageExtraction) {
+		t.Errorf("Expected ErrPageExtraction for a missing page, got %v", err)
+	}
+	if _, err := repo.LoadImageByName("broken.pdf"); !errors.Is(err, ErrPageExtraction) {
+		t.Errorf("Expected ErrPageExtraction for a broken PDF, got %v", err)
+	}
+	if _, err := repo.LoadImageByName("missing.pdf#p1"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Expected ErrImageNotFound for a missing PDF, got %v", err)
+	}
+}
+
+func TestRepository_PDFCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo, err := New(tmpDir, "", Config{})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+	for i := 0; i < maxOpenDocuments+2; i++ {
+		writePDF(t, filepath.Join(tmpDir, fmt.Sprintf("scan-%d.pdf", i)), byte(i), byte(i))
+	}
+
+	// Listing opens every file, but only the most recent ones are kept
+	if _, err := repo.GetImageNames(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(repo.documents) != maxOpenDocuments || len(repo.recent) != maxOpenDocuments {
+		t.Errorf("Expected %d open documents, got %d", maxOpenDocuments, len(repo.documents))
+	}
+	if _, ok := repo.documents["scan-0.pdf"]; ok {
+		t.Errorf("Expected the least recently used document to be released")
+	}
+
+	// A released file is opened again, and releases the least recently used one in turn
+	data, err := repo.LoadImageByName("scan-0.pdf#p2")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode page: %v", err)
+	}
+	if got := img.(*image.Gray).GrayAt(0, 0).Y; got != 0 {
+		t.Errorf("Expected gray 0, got %d", got)
+	}
+	if _, ok := repo.documents["scan-2.pdf"]; ok || len(repo.documents) != maxOpenDocuments {
+		t.Errorf("Expected scan-2.pdf to be released, open documents are %v", repo.recent)
+	}
+}
+
+// writeTIFF writes a TIFF file with a page for each gray value, each an uncompressed 1x1 image of that value
+func writeTIFF(t *testing.T, path string, grays ...byte) {
+	var b bytes.Buffer
+	b.WriteString("II*\x00")
+	binary.Write(&b, binary.LittleEndian, uint32(8))
+	for i, gray := range grays {
+		// Each page is its directory of 8 entries followed by its pixel
+		offset := uint32(b.Len())
+		pixel := offset + 2 + 8*12 + 4
+		binary.Write(&b, binary.LittleEndian, uint16(8))
+		for _, entry := range [][2]uint32{{256, 1}, {257, 1}, {258, 8}, {259, 1}, {262, 1}, {273, pixel}, {278, 1}, {279, 1}} {
+			binary.Write(&b, binary.LittleEndian, uint16(entry[0]))
+			binary.Write(&b, binary.LittleEndian, uint16(4)) // LONG
+			binary.Write(&b, binary.LittleEndian, uint32(1))
+			binary.Write(&b, binary.LittleEndian, entry[1])
+		}
+		next := uint32(0)
+		if i < len(grays)-1 {
+			next = pixel + 1
+		}
+		binary.Write(&b, binary.LittleEndian, next)
+		b.WriteByte(gray)
+	}
+	if err := os.WriteFile(path, b.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+func TestRepository_TIFF(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo, err := New(tmpDir, "", Config{})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	writeTIFF(t, filepath.Join(tmpDir, "pages.tiff"), 10, 20)
+	writeTIFF(t, filepath.Join(tmpDir, "single.tif"), 30)
+
+	// Single page TIFF files are listed by their own name
+	names, err := repo.GetImageNames()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"pages.tiff#p1", "pages.tiff#p2", "single.tif"}
+	if fmt.Sprint(names) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, names)
+	}
+
+	for name, gray := range map[string]uint8{"pages.tiff#p1": 10, "pages.tiff#p2": 20, "single.tif": 30} {
+		data, err := repo.LoadImageByName(name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		img, err := tiff.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Failed to decode %s: %v", name, err)
+		}
+		if got := img.(*image.Gray).GrayAt(0, 0).Y; got != gray {
+			t.Errorf("Expected gray %d for %s, got %d", gray, name, got)
+		}
+	}
+
+	if _, err := repo.LoadImageByName("pages.tiff#p3"); !errors.Is(err, ErrPageExtraction) {
+		t.Errorf("Expected ErrPageExtraction for a missing page, got %v", err)
+	}
+}
+
 func TestRepository_SaveOutput(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "ocr_test_*")
 	if err != nil {
@@ -117,7 +384,7 @@ func TestRepository_SaveOutput(t *testing.T) {
 
 	// Test saving output
 	outputPath := filepath.Join(tmpDir, "output.txt")
-	repo, err := New("", outputPath)
+	repo, err := New("", outputPath, Config{})
 	if err != nil {
 		t.Fatalf("Failed to create repository: %v", err)
 	}
@@ -137,3 +404,71 @@ func TestRepository_SaveOutput(t *testing.T) {
 		t.Errorf("Expected %s, got %s", content, string(data))
 	}
 }
+
+func TestRepository_SaveOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	repo, err := New(tmpDir, "journal.md", Config{})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	outputs := map[string]string{
+		"2024-01-01": "first entry",
+		"2024-01-02": "second entry",
+	}
+	if err := repo.SaveOutputs(outputs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Verify each output was saved next to the output path with its extension
+	for name, content := range outputs {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name+".md"))
+		if err != nil {
+			t.Fatalf("Failed to read saved file: %v", err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s, got %s", content, string(data))
+		}
+	}
+
+	// Test names that would escape the output directory
+	for _, name := range []string{"", "..", "../escape", "sub/dir"} {
+		if err := repo.SaveOutputs(map[string]string{name: "content"}); !errors.Is(err, ErrFailedToSave) {
+			t.Errorf("Expected ErrFailedToSave for %q, got %v", name, err)
+		}
+	}
+}
+
+func TestRepository_SaveDirectoryOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	repo, err := New(tmpDir, "journal.md", Config{})
+	if err != nil {
+		t.Fatalf("Failed to create repository: %v", err)
+	}
+
+	// The outputs are saved in the subdirectory of the output's directory, which is created
+	if err := repo.SaveDirectoryOutput("1921/vol1", "first volume"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repo.SaveDirectoryOutputs("vol2", map[string]string{"2024-01-01": "first entry"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for path, content := range map[string]string{"1921/vol1/journal.md": "first volume", "vol2/2024-01-01.md": "first entry"} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatalf("Failed to read saved file: %v", err)
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s, got %s", content, string(data))
+		}
+	}
+
+	// Test directories that would escape the output directory
+	for _, dir := range []string{"", ".", "..", "../escape"} {
+		if err := repo.SaveDirectoryOutput(dir, "content"); !errors.Is(err, ErrFailedToSave) {
+			t.Errorf("Expected ErrFailedToSave for %q, got %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// maxTIFFPages limits how many pages are read from a TIFF file, so a malformed file cannot loop forever
+const maxTIFFPages = 10000
+
+// tiffPages returns the offsets of the image file directories of a TIFF file, one for each page
+func tiffPages(data []byte) ([]uint32, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("invalid TIFF header")
+	}
+	var order binary.ByteOrder
+	switch string(data[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid TIFF header")
+	}
+
+	var pages []uint32
+	seen := map[uint32]bool{}
+	for offset := order.Uint32(data[4:8]); offset != 0; {
+		if seen[offset] || len(pages) >= maxTIFFPages {
+			return nil, fmt.Errorf("invalid TIFF page list")
+		}
+		if uint64(offset)+2 > uint64(len(data)) {
+			return nil, fmt.Errorf("TIFF page %d is out of bounds", len(pages)+1)
+		}
+		entries := uint64(order.Uint16(data[offset:]))
+		next := uint64(offset) + 2 + entries*12
+		if next+4 > uint64(len(data)) {
+			return nil, fmt.Errorf("TIFF page %d is out of bounds", len(pages)+1)
+		}
+		seen[offset] = true
+		pages = append(pages, offset)
+		offset = order.Uint32(data[next:])
+	}
+	return pages, nil
+}
+
+// tiffPage returns a TIFF file whose first page is page n of data, counting from 1.
+// TIFF decoders only read the first page, and offsets in a TIFF file are absolute,
+// so pointing the header at the directory of page n is enough.
+func tiffPage(data []byte, n int) ([]byte, error) {
+	pages, err := tiffPages(data)
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 || n > len(pages) {
+		return nil, fmt.Errorf("page %d of %d not found", n, len(pages))
+	}
+	page := make([]byte, len(data))
+	copy(page, data)
+	if data[0] == 'I' {
+		binary.LittleEndian.PutUint32(page[4:8], pages[n-1])
+	} else {
+		binary.BigEndian.PutUint32(page[4:8], pages[n-1])
+	}
+	return page, nil
+}
@@ -0,0 +1,143 @@
+package resizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+)
+
+// orientationTag is the EXIF tag that records how the camera was held
+const orientationTag = 0x0112
+
+// orientation returns the EXIF orientation of JPEG, PNG and TIFF images, from 1 (upright) to 8.
+// It is 1 when the image has no valid orientation.
+func orientation(data []byte, format string) int {
+	var exif []byte
+	switch format {
+	case "jpeg":
+		exif = jpegExif(data)
+	case "png":
+		exif = pngExif(data)
+	case "tiff":
+		exif = data
+	}
+	if o := tiffOrientation(exif); o >= 1 && o <= 8 {
+		return o
+	}
+	return 1
+}
+
+// jpegExif returns the TIFF structure of the EXIF segment of a JPEG image
+func jpegExif(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return nil
+		}
+		marker := data[pos+1]
+		// Fill bytes and markers without a length
+		if marker == 0xFF || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			pos++
+			continue
+		}
+		// The EXIF segment comes before the image data
+		if marker == 0xDA || marker == 0xD9 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:]
+		}
+		pos = end
+	}
+	return nil
+}
+
+// pngExif returns the TIFF structure of the eXIf chunk of a PNG image
+func pngExif(data []byte) []byte {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil
+	}
+	for pos := len(signature); pos+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunk := string(data[pos+4 : pos+8])
+		end := pos + 8 + length + 4 // the chunk is followed by its CRC
+		if length < 0 || end > len(data) {
+			return nil
+		}
+		switch chunk {
+		case "eXIf":
+			return data[pos+8 : pos+8+length]
+		case "IDAT", "IEND":
+			// Only an eXIf chunk before the image data is valid
+			return nil
+		}
+		pos = end
+	}
+	return nil
+}
+
+// tiffOrientation returns the orientation tag of the first directory of a TIFF structure, or 0 when it has none
+func tiffOrientation(data []byte) int {
+	if len(data) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(data[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int(order.Uint32(data[4:8]))
+	if offset < 8 || offset+2 > len(data) {
+		return 0
+	}
+	entries := int(order.Uint16(data[offset:]))
+	for i := 0; i < entries; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(data) {
+			return 0
+		}
+		// The orientation is a SHORT, stored in the first two bytes of the value
+		if order.Uint16(data[entry:]) == orientationTag && order.Uint16(data[entry+2:]) == 3 {
+			return int(order.Uint16(data[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// applyOrientation transforms an image so it is upright for the given EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.Transform(img, false, true, false)
+	case 3:
+		return imaging.Rotate(img, 180)
+	case 4:
+		return imaging.Transform(img, false, false, true)
+	case 5:
+		return imaging.Transform(img, true, false, false)
+	case 6:
+		return imaging.Rotate(img, 90)
+	case 7:
+		return imaging.Transform(img, true, true, true)
+	case 8:
+		return imaging.Rotate(img, 270)
+	default:
+		return img
+	}
+}
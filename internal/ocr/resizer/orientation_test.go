@@ -0,0 +1,169 @@
+package resizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+	"github.com/stretchr/testify/assert"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// textPage renders lines of text on a white page, scaled up to the size of a scan
+func textPage() image.Image {
+	lines := []string{
+		"Tuesday, the 14th of May. Walked along the harbour",
+		"with the children before the rain, then wrote letters",
+		"to Agnes and Joseph about the garden and the quay.",
+		"The lilac by the gate is finally in bloom, and the",
+		"light stayed until half past nine in the evening.",
+		"Bought bread, apples and a jar of honey at the market.",
+		"Thought of the old days at the lighthouse and slept well.",
+	}
+	page := image.NewGray(image.Rect(0, 0, 420, 600))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+	drawer := font.Drawer{Dst: page, Src: image.NewUniform(color.Black), Face: basicfont.Face7x13}
+	for i, line := range lines {
+		drawer.Dot = fixed.P(15, 20+17*i)
+		drawer.DrawString(line)
+	}
+	scaled := image.NewGray(image.Rect(0, 0, 1260, 1800))
+	xdraw.NearestNeighbor.Scale(scaled, scaled.Bounds(), page, page.Bounds(), draw.Src, nil)
+	return scaled
+}
+
+func TestDetectRotation(t *testing.T) {
+	page := textPage()
+	for _, turned := range []int{0, 90, 180, 270} {
+		// Turning the page counterclockwise by the angle is undone by turning it clockwise by the angle
+		img := imaging.Rotate(page, (360-turned)%360)
+		assert.Equal(t, turned, detectRotation(img), "page turned %d degrees counterclockwise", turned)
+	}
+
+	blank := image.NewGray(image.Rect(0, 0, 100, 100))
+	draw.Draw(blank, blank.Bounds(), image.White, image.Point{}, draw.Src)
+	assert.Equal(t, 0, detectRotation(blank))
+}
+
+// exifSegment returns the TIFF structure of an EXIF block with the orientation tag
+func exifSegment(orientation uint16) []byte {
+	var b bytes.Buffer
+	b.WriteString("MM\x00*")
+	binary.Write(&b, binary.BigEndian, uint32(8))
+	binary.Write(&b, binary.BigEndian, uint16(1))
+	binary.Write(&b, binary.BigEndian, []uint16{orientationTag, 3})
+	binary.Write(&b, binary.BigEndian, uint32(1))
+	binary.Write(&b, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	return b.Bytes()
+}
+
+// withJPEGOrientation adds an EXIF segment with the orientation to a JPEG image, right after its start marker
+func withJPEGOrientation(data []byte, orientation uint16) []byte {
+	segment := append([]byte("Exif\x00\x00"), exifSegment(orientation)...)
+	var b bytes.Buffer
+	b.Write(data[:2])
+	b.Write([]byte{0xFF, 0xE1})
+	binary.Write(&b, binary.BigEndian, uint16(len(segment)+2))
+	b.Write(segment)
+	b.Write(data[2:])
+	return b.Bytes()
+}
+
+// withPNGOrientation adds an eXIf chunk with the orientation to a PNG image, right after its header chunk
+func withPNGOrientation(data []byte, orientation uint16) []byte {
+	exif := exifSegment(orientation)
+	headerEnd := 8 + 8 + 13 + 4
+	var b bytes.Buffer
+	b.Write(data[:headerEnd])
+	binary.Write(&b, binary.BigEndian, uint32(len(exif)))
+	b.WriteString("eXIf")
+	b.Write(exif)
+	binary.Write(&b, binary.BigEndian, crc32.ChecksumIEEE(append([]byte("eXIf"), exif...)))
+	b.Write(data[headerEnd:])
+	return b.Bytes()
+}
+
+// halfBlack returns an image whose left half is black and right half is white
+func halfBlack(width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, width/2, height), image.Black, image.Point{}, draw.Src)
+	return img
+}
+
+func TestOrientation(t *testing.T) {
+	jpegData, err := encodeJPEG(halfBlack(40, 20))
+	assert.NoError(t, err)
+	pngData, err := encodePNG(halfBlack(40, 20))
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, orientation(jpegData, "jpeg"))
+	assert.Equal(t, 6, orientation(withJPEGOrientation(jpegData, 6), "jpeg"))
+	assert.Equal(t, 3, orientation(withPNGOrientation(pngData, 3), "png"))
+	assert.Equal(t, 1, orientation(withJPEGOrientation(jpegData, 9), "jpeg"), "invalid orientations are ignored")
+
+	// The images still decode with the orientation added
+	_, err = jpeg.Decode(bytes.NewReader(withJPEGOrientation(jpegData, 6)))
+	assert.NoError(t, err)
+	_, err = png.Decode(bytes.NewReader(withPNGOrientation(pngData, 3)))
+	assert.NoError(t, err)
+}
+
+func TestResizer_ResizeImage_Orientation(t *testing.T) {
+	r := New(Config{})
+	jpegData, err := encodeJPEG(halfBlack(200, 100))
+	assert.NoError(t, err)
+
+	// The black left half is on top after turning the image clockwise
+	result, err := r.ResizeImage(withJPEGOrientation(jpegData, 6), 1500)
+	assert.NoError(t, err)
+	img, format, err := r.decodeImage(result)
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, image.Rect(0, 0, 100, 200), img.Bounds())
+	assert.Less(t, color.GrayModel.Convert(img.At(50, 50)).(color.Gray).Y, uint8(64))
+	assert.Greater(t, color.GrayModel.Convert(img.At(50, 150)).(color.Gray).Y, uint8(192))
+
+	// The sides are swapped before the image is resized
+	result, err = r.ResizeImage(withJPEGOrientation(jpegData, 8), 50)
+	assert.NoError(t, err)
+	img, _, err = r.decodeImage(result)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 25, 50), img.Bounds())
+	assert.Greater(t, color.GrayModel.Convert(img.At(12, 10)).(color.Gray).Y, uint8(192))
+}
+
+func TestResizer_ResizeImage_DetectRotation(t *testing.T) {
+	r := New(Config{DetectRotation: true})
+
+	upright, err := encodePNG(textPage())
+	assert.NoError(t, err)
+	result, err := r.ResizeImage(upright, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, upright, result, "upright pages are returned unchanged")
+
+	turned, err := encodePNG(imaging.Rotate(textPage(), 90))
+	assert.NoError(t, err)
+	result, err = r.ResizeImage(turned, 2000)
+	assert.NoError(t, err)
+	img, _, err := r.decodeImage(result)
+	assert.NoError(t, err)
+	assert.Equal(t, textPage().Bounds(), img.Bounds())
+	assert.Equal(t, 0, detectRotation(img))
+
+	// Without detection the page is sent as it is
+	result, err = New(Config{}).ResizeImage(turned, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, turned, result)
+}
@@ -0,0 +1,228 @@
+package resizer
+
+import (
+	"image"
+	"math"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+	"golang.org/x/image/draw"
+)
+
+const (
+	// pageSampleSize is the longest side of the copy of a photo that the page is found on
+	pageSampleSize = 400
+	// minPageArea is the smallest share of the photo that a page must cover
+	minPageArea = 0.2
+	// maxPageFill is the largest share of the photo that the bounds of a page may cover,
+	// since pages that fill the photo, like scans, have no background to cut away
+	maxPageFill = 0.95
+	// minQuadFill is the smallest share of its quadrilateral that a page must fill,
+	// so shapes other than a page, like a light object on the desk, are left alone
+	minQuadFill = 0.85
+)
+
+// point is a position in an image, in pixels
+type point struct {
+	x, y float64
+}
+
+// correctPerspective finds a page photographed on a darker background, like a desk, and warps its quadrilateral
+// to a flat rectangle, cutting away the background. It returns false when no page is found.
+func correctPerspective(img image.Image) (image.Image, bool) {
+	corners, ok := findPage(img)
+	if !ok {
+		return img, false
+	}
+	// The rectangle takes the longer of each pair of opposite sides
+	width := math.Max(distance(corners[0], corners[1]), distance(corners[3], corners[2]))
+	height := math.Max(distance(corners[0], corners[3]), distance(corners[1], corners[2]))
+	return warp(img, corners, int(math.Round(width)), int(math.Round(height))), true
+}
+
+// findPage returns the corners of the page, clockwise from the top left, in the coordinates of the image.
+// The page is the largest region lighter than Otsu's threshold, and its corners are its points that lie
+// farthest towards each corner of the photo.
+func findPage(img image.Image) ([4]point, bool) {
+	var corners [4]point
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return corners, false
+	}
+	gray := imaging.Sample(img, pageSampleSize)
+	sw, sh := gray.Rect.Dx(), gray.Rect.Dy()
+
+	threshold := imaging.OtsuThreshold(gray.Pix)
+	page := largestRegion(gray, threshold)
+	if float64(len(page)) < minPageArea*float64(sw*sh) {
+		return corners, false
+	}
+
+	// The extremes of x+y and x-y are the corners of a page that is turned by less than 45 degrees
+	tl, tr, br, bl := page[0], page[0], page[0], page[0]
+	for _, p := range page {
+		if p.X+p.Y < tl.X+tl.Y {
+			tl = p
+		}
+		if p.X-p.Y > tr.X-tr.Y {
+			tr = p
+		}
+		if p.X+p.Y > br.X+br.Y {
+			br = p
+		}
+		if p.X-p.Y < bl.X-bl.Y {
+			bl = p
+		}
+	}
+	sx, sy := float64(w)/float64(sw), float64(h)/float64(sh)
+	for i, p := range []image.Point{tl, tr, br, bl} {
+		corners[i] = point{x: (float64(p.X) + 0.5) * sx, y: (float64(p.Y) + 0.5) * sy}
+	}
+
+	area := quadArea(corners)
+	if area > maxPageFill*float64(w*h) || filledArea(page, sh)*sx*sy < minQuadFill*area {
+		return corners, false
+	}
+	return corners, true
+}
+
+// filledArea returns the area of a region with its holes, such as the text on a page, filled in,
+// as the sum of the distance between the first and last pixel of each of its rows
+func filledArea(region []image.Point, height int) float64 {
+	first, last := make([]int, height), make([]int, height)
+	for y := range first {
+		first[y], last[y] = math.MaxInt, -1
+	}
+	for _, p := range region {
+		first[p.Y], last[p.Y] = min(first[p.Y], p.X), max(last[p.Y], p.X)
+	}
+	var area float64
+	for y := range first {
+		if last[y] >= 0 {
+			area += float64(last[y] - first[y] + 1)
+		}
+	}
+	return area
+}
+
+// largestRegion returns the pixels of the largest 4-connected region of pixels at or above the threshold
+func largestRegion(gray *image.Gray, threshold uint8) []image.Point {
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	seen := make([]bool, w*h)
+	var largest, queue []image.Point
+	for start := range seen {
+		if seen[start] || gray.Pix[start] < threshold {
+			continue
+		}
+		seen[start] = true
+		queue = append(queue[:0], image.Pt(start%w, start/w))
+		for i := 0; i < len(queue); i++ {
+			p := queue[i]
+			for _, n := range []image.Point{{p.X - 1, p.Y}, {p.X + 1, p.Y}, {p.X, p.Y - 1}, {p.X, p.Y + 1}} {
+				if n.X < 0 || n.Y < 0 || n.X >= w || n.Y >= h {
+					continue
+				}
+				if j := n.Y*w + n.X; !seen[j] && gray.Pix[j] >= threshold {
+					seen[j] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+		if len(queue) > len(largest) {
+			largest = append(largest[:0], queue...)
+		}
+	}
+	return largest
+}
+
+// quadArea returns the area of a quadrilateral with the shoelace formula
+func quadArea(q [4]point) float64 {
+	var sum float64
+	for i := range q {
+		j := (i + 1) % len(q)
+		sum += q[i].x*q[j].y - q[j].x*q[i].y
+	}
+	return math.Abs(sum) / 2
+}
+
+// distance returns the length of the line between two points
+func distance(a, b point) float64 {
+	return math.Hypot(a.x-b.x, a.y-b.y)
+}
+
+// warp maps the quadrilateral of the image onto a width by height rectangle, sampling it bilinearly
+func warp(img image.Image, quad [4]point, width, height int) image.Image {
+	src := image.NewRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(src, src.Bounds(), img, img.Bounds().Min, draw.Src)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+
+	rect := [4]point{{0, 0}, {float64(width), 0}, {float64(width), float64(height)}, {0, float64(height)}}
+	h := homography(rect, quad)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Sample the source at the center of the pixel
+			u, v := float64(x)+0.5, float64(y)+0.5
+			d := h[6]*u + h[7]*v + 1
+			sx := (h[0]*u+h[1]*v+h[2])/d - 0.5
+			sy := (h[3]*u+h[4]*v+h[5])/d - 0.5
+
+			x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+			fx, fy := sx-float64(x0), sy-float64(y0)
+			x0, x1 := min(max(x0, 0), sw-1), min(max(x0+1, 0), sw-1)
+			y0, y1 := min(max(y0, 0), sh-1), min(max(y0+1, 0), sh-1)
+			i00, i10 := src.PixOffset(x0, y0), src.PixOffset(x1, y0)
+			i01, i11 := src.PixOffset(x0, y1), src.PixOffset(x1, y1)
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[i00+c])*(1-fx) + float64(src.Pix[i10+c])*fx
+				bottom := float64(src.Pix[i01+c])*(1-fx) + float64(src.Pix[i11+c])*fx
+				dst.Pix[o+c] = uint8(top*(1-fy) + bottom*fy + 0.5)
+			}
+		}
+	}
+	return dst
+}
+
+// homography returns the projective transform that maps the corners of from onto the corners of to,
+// as the first eight entries of its 3x3 matrix with the last entry fixed to 1
+func homography(from, to [4]point) [8]float64 {
+	// Each pair of corners gives two linear equations in the eight unknowns
+	var a [8][9]float64
+	for i := range from {
+		u, v, x, y := from[i].x, from[i].y, to[i].x, to[i].y
+		a[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		a[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+
+	// Gaussian elimination with partial pivoting
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		if a[col][col] == 0 {
+			continue
+		}
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := a[row][col] / a[col][col]
+			for k := col; k < 9; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+		}
+	}
+
+	var h [8]float64
+	for i := range h {
+		if a[i][i] != 0 {
+			h[i] = a[i][8] / a[i][i]
+		}
+	}
+	return h
+}
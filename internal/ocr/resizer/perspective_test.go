@@ -0,0 +1,107 @@
+package resizer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// photoCorners are the corners of the page in photo, clockwise from the top left
+var photoCorners = [4]point{{330, 60}, {870, 110}, {910, 850}, {280, 820}}
+
+// photo returns a 1200x900 photo of a skewed 600x800 page with lines of text on a dark desk
+func photo() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 1200, 900))
+	rect := [4]point{{0, 0}, {600, 0}, {600, 800}, {0, 800}}
+	toPage := homography(photoCorners, rect)
+	for y := 0; y < 900; y++ {
+		for x := 0; x < 1200; x++ {
+			// The grain of the desk
+			desk := uint8(70 + 15*math.Sin(float64(x+y)/9))
+			c := color.RGBA{desk + 20, desk, desk - 20, 255}
+
+			px, py := float64(x)+0.5, float64(y)+0.5
+			d := toPage[6]*px + toPage[7]*py + 1
+			u := (toPage[0]*px + toPage[1]*py + toPage[2]) / d
+			v := (toPage[3]*px + toPage[4]*py + toPage[5]) / d
+			if u >= 0 && u < 600 && v >= 0 && v < 800 {
+				c = color.RGBA{235, 230, 215, 255}
+				if u >= 60 && u < 540 && v >= 80 && v < 720 && int(v)%40 < 6 {
+					c = color.RGBA{30, 30, 50, 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestHomography(t *testing.T) {
+	rect := [4]point{{0, 0}, {600, 0}, {600, 800}, {0, 800}}
+	h := homography(rect, photoCorners)
+	for i, p := range rect {
+		d := h[6]*p.x + h[7]*p.y + 1
+		assert.InDelta(t, photoCorners[i].x, (h[0]*p.x+h[1]*p.y+h[2])/d, 1e-6)
+		assert.InDelta(t, photoCorners[i].y, (h[3]*p.x+h[4]*p.y+h[5])/d, 1e-6)
+	}
+}
+
+func TestFindPage(t *testing.T) {
+	corners, ok := findPage(photo())
+	assert.True(t, ok)
+	for i, corner := range corners {
+		assert.Less(t, distance(corner, photoCorners[i]), 12.0, "corner %d is %v, expected %v", i, corner, photoCorners[i])
+	}
+
+	// Scans have no background around the page
+	_, ok = findPage(textPage())
+	assert.False(t, ok)
+
+	desk := image.NewGray(image.Rect(0, 0, 300, 200))
+	_, ok = findPage(desk)
+	assert.False(t, ok)
+}
+
+func TestCorrectPerspective(t *testing.T) {
+	img, ok := correctPerspective(photo())
+	assert.True(t, ok)
+
+	// The page is the size of its longest sides
+	bounds := img.Bounds()
+	assert.InDelta(t, distance(photoCorners[3], photoCorners[2]), bounds.Dx(), 15)
+	assert.InDelta(t, distance(photoCorners[0], photoCorners[3]), bounds.Dy(), 15)
+
+	// The desk is cut away, and the lines of text run straight across the page
+	lum := func(x, y int) uint8 { return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y }
+	for _, p := range []image.Point{{5, 5}, {bounds.Dx() - 6, 5}, {5, bounds.Dy() - 6}, {bounds.Dx() - 6, bounds.Dy() - 6}} {
+		assert.Greater(t, lum(p.X, p.Y), uint8(180), "corner %v", p)
+	}
+	line := 80 * bounds.Dy() / 800
+	for _, x := range []int{bounds.Dx() / 5, bounds.Dx() / 2, 4 * bounds.Dx() / 5} {
+		assert.Less(t, lum(x, line+2), uint8(100), "ink of the first line at x %d", x)
+		assert.Greater(t, lum(x, line+20), uint8(180), "paper below the first line at x %d", x)
+	}
+}
+
+func TestResizer_ResizeImage_CorrectPerspective(t *testing.T) {
+	data, err := encodeJPEG(photo())
+	assert.NoError(t, err)
+
+	result, err := New(Config{CorrectPerspective: true}).ResizeImage(data, 500)
+	assert.NoError(t, err)
+	img, format, err := New(Config{}).decodeImage(result)
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", format)
+	assert.Equal(t, 500, img.Bounds().Dy(), "the page is resized after it is cut out")
+	assert.InDelta(t, 0.83, float64(img.Bounds().Dx())/float64(img.Bounds().Dy()), 0.03)
+
+	// Scans are left as they are
+	scan, err := encodePNG(textPage())
+	assert.NoError(t, err)
+	result, err = New(Config{CorrectPerspective: true}).ResizeImage(scan, 2000)
+	assert.NoError(t, err)
+	assert.Equal(t, scan, result)
+}
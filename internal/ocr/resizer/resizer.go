@@ -8,33 +8,65 @@ import (
 	"image/jpeg"
 	"image/png"
 
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+	_ "golang.org/x/image/bmp" // register the BMP decoder
 	"golang.org/x/image/draw"
-	"golang.org/x/image/webp"
+	_ "golang.org/x/image/tiff" // register the TIFF decoder
+	_ "golang.org/x/image/webp" // register the WebP decoder
 )
 
+// sendableFormats are the formats the OCR APIs accept. Images in other formats are converted to PNG.
+var sendableFormats = map[string]bool{
+	"jpeg": true,
+	"png":  true,
+	"gif":  true,
+	"webp": true,
+}
+
+// Config holds the settings of the Resizer
+type Config struct {
+	// DetectRotation turns pages whose text runs sideways or upside down upright.
+	// It is meant for scans and photos without an EXIF orientation, which is always applied.
+	DetectRotation bool
+	// CorrectPerspective finds the page in photos of a page on a darker background, like a desk,
+	// and warps it to a flat rectangle, cutting away the background
+	CorrectPerspective bool
+	// Tiles is the fewest overlapping horizontal bands TileImage splits each image into, or 1 or less
+	// to keep images whole. Images are split into more bands when their bands would otherwise be higher
+	// than the max dimension.
+	Tiles int
+}
+
 // Resizer implements the ocr.Resizer interface for image resizing operations
-type Resizer struct{}
+type Resizer struct {
+	config Config
+}
 
 // New creates a new Resizer instance
-func New() *Resizer {
-	return &Resizer{}
+func New(config Config) *Resizer {
+	return &Resizer{config: config}
 }
 
-// ResizeImage resizes an image if its longest dimension exceeds maxDimension, maintaining aspect ratio
+// ResizeImage resizes an image if its longest dimension exceeds maxDimension, maintaining aspect ratio.
+// Images are turned upright first, following their EXIF orientation, and, if enabled, the page is flattened
+// and turned by the detected rotation before the image is resized.
 func (r *Resizer) ResizeImage(imageData []byte, maxDimension int) ([]byte, error) {
 	if maxDimension <= 0 {
 		return nil, fmt.Errorf("maxDimension must be positive")
 	}
 
-	// Decode image to determine format and dimensions
-	img, format, err := r.decodeImage(imageData)
+	// Sniff the format and dimensions from the header, so small images are not decoded at all
+	config, format, err := image.DecodeConfig(bytes.NewReader(imageData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	bounds := img.Bounds()
-	width := bounds.Dx()
-	height := bounds.Dy()
+	// Orientations 5 to 8 swap the sides of the image
+	exifOrientation := orientation(imageData, format)
+	width, height := config.Width, config.Height
+	if exifOrientation >= 5 {
+		width, height = height, width
+	}
 
 	// Find the longest dimension
 	longestDim := width
@@ -42,11 +74,84 @@ func (r *Resizer) ResizeImage(imageData []byte, maxDimension int) ([]byte, error
 		longestDim = height
 	}
 
-	// If image is already small enough, return original
-	if longestDim <= maxDimension {
+	// If image is already small enough and upright, return original
+	fits := longestDim <= maxDimension && sendableFormats[format]
+	if fits && exifOrientation == 1 && !r.config.DetectRotation && !r.config.CorrectPerspective {
 		return imageData, nil
 	}
 
+	img, format, err := r.decodeImage(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	img, changed := r.prepare(img, exifOrientation)
+	if fits && !changed {
+		return imageData, nil
+	}
+	return r.fit(img, format, maxDimension)
+}
+
+// TileImage splits an image into at least the configured number of overlapping horizontal bands, from top to
+// bottom, that span the full width of the image so lines of text are never cut across. The bands are cut from the
+// image at its full resolution and are at most maxDimension high; a band wider than maxDimension is downscaled to
+// fit. The image is turned upright and flattened first. It returns the image resized as a single tile when tiling
+// is not configured.
+func (r *Resizer) TileImage(imageData []byte, maxDimension int) ([][]byte, error) {
+	if r.config.Tiles <= 1 {
+		data, err := r.ResizeImage(imageData, maxDimension)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
+	}
+	if maxDimension <= 0 {
+		return nil, fmt.Errorf("maxDimension must be positive")
+	}
+
+	img, format, err := r.decodeImage(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	img, _ = r.prepare(img, orientation(imageData, format))
+
+	bands := tileBands(img.Bounds(), r.config.Tiles, maxDimension)
+	tiles := make([][]byte, 0, len(bands))
+	for _, band := range bands {
+		data, err := r.fit(crop(img, band), format, maxDimension)
+		if err != nil {
+			return nil, err
+		}
+		tiles = append(tiles, data)
+	}
+	return tiles, nil
+}
+
+// prepare turns an image upright following its EXIF orientation and, if enabled, flattens its page and turns it
+// by the detected rotation. It reports whether the image was changed.
+func (r *Resizer) prepare(img image.Image, exifOrientation int) (image.Image, bool) {
+	img = applyOrientation(img, exifOrientation)
+	corrected := false
+	if r.config.CorrectPerspective {
+		img, corrected = correctPerspective(img)
+	}
+	rotation := 0
+	if r.config.DetectRotation {
+		rotation = detectRotation(img)
+		img = imaging.Rotate(img, rotation)
+	}
+	return img, exifOrientation != 1 || corrected || rotation != 0
+}
+
+// fit resizes an image so its longest dimension is at most maxDimension, maintaining aspect ratio, and encodes it
+func (r *Resizer) fit(img image.Image, format string, maxDimension int) ([]byte, error) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Images that are small enough are only turned upright or converted
+	if max(width, height) <= maxDimension {
+		return r.encodeImage(img, format)
+	}
+
 	// Calculate new dimensions maintaining aspect ratio
 	var newWidth, newHeight int
 	if width > height {
@@ -69,41 +174,13 @@ func (r *Resizer) ResizeImage(imageData []byte, maxDimension int) ([]byte, error
 	return r.encodeImage(dst, format)
 }
 
-// decodeImage decodes image data and returns the image, format, and error
+// decodeImage decodes image data with the decoder registered for its format and returns the image, format, and error
 func (r *Resizer) decodeImage(data []byte) (image.Image, string, error) {
-	// Try to detect format by attempting to decode
-	reader := bytes.NewReader(data)
-
-	// Try WebP first (needs external library)
-	if img, err := webp.Decode(reader); err == nil {
-		return img, "webp", nil
-	}
-
-	// Reset reader for next attempt
-	reader.Seek(0, 0)
-
-	// Try PNG
-	if img, err := png.Decode(reader); err == nil {
-		return img, "png", nil
-	}
-
-	// Reset reader for next attempt
-	reader.Seek(0, 0)
-
-	// Try JPEG
-	if img, err := jpeg.Decode(reader); err == nil {
-		return img, "jpeg", nil
-	}
-
-	// Reset reader for next attempt
-	reader.Seek(0, 0)
-
-	// Try GIF
-	if img, err := gif.Decode(reader); err == nil {
-		return img, "gif", nil
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported image format or invalid image data: %w", err)
 	}
-
-	return nil, "", fmt.Errorf("unsupported image format or invalid image data")
+	return img, format, nil
 }
 
 // encodeImage encodes an image to the specified format
@@ -130,10 +207,15 @@ func (r *Resizer) encodeImage(img image.Image, format string) ([]byte, error) {
 		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 92}); err != nil {
 			return nil, fmt.Errorf("failed to encode WebP (fallback to JPEG): %w", err)
 		}
+	case "bmp", "tiff":
+		// The APIs do not accept BMP or TIFF, so they are converted to PNG, which keeps every pixel of a scan
+		encoder := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := encoder.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode PNG: %w", err)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported format for encoding: %s", format)
 	}
 
 	return buf.Bytes(), nil
 }
-
@@ -5,10 +5,13 @@ import (
 	"image"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/bmp"
 	"golang.org/x/image/draw"
+	"golang.org/x/image/tiff"
 )
 
 // createTestImage creates a test image with the specified dimensions
@@ -34,7 +37,7 @@ func encodePNG(img image.Image) ([]byte, error) {
 }
 
 func TestResizer_ResizeImage_SmallImage(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create a small image (570x562) that doesn't need resizing
 	img := createTestImage(570, 562)
@@ -48,7 +51,7 @@ func TestResizer_ResizeImage_SmallImage(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_LargeImage(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create a large image (4032x2707) that needs resizing
 	img := createTestImage(4032, 2707)
@@ -77,14 +80,14 @@ func TestResizer_ResizeImage_LargeImage(t *testing.T) {
 	// Verify aspect ratio is maintained
 	// Original: 4032/2707 ≈ 1.489
 	// Resized: width/height should be approximately the same
-	expectedWidth := (4032 * 1500) / 4032 // = 1500
+	expectedWidth := (4032 * 1500) / 4032  // = 1500
 	expectedHeight := (2707 * 1500) / 4032 // ≈ 1006
 	assert.Equal(t, expectedWidth, width)
 	assert.InDelta(t, expectedHeight, height, 1, "Height should maintain aspect ratio")
 }
 
 func TestResizer_ResizeImage_PortraitImage(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create a tall portrait image (1000x2000)
 	img := createTestImage(1000, 2000)
@@ -109,7 +112,7 @@ func TestResizer_ResizeImage_PortraitImage(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_LandscapeImage(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create a wide landscape image (3000x1500)
 	img := createTestImage(3000, 1500)
@@ -134,7 +137,7 @@ func TestResizer_ResizeImage_LandscapeImage(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_PNGFormat(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create a large PNG image
 	img := createTestImage(2000, 2000)
@@ -156,7 +159,7 @@ func TestResizer_ResizeImage_PNGFormat(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_InvalidMaxDimension(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	img := createTestImage(100, 100)
 	imageData, err := encodeJPEG(img)
@@ -174,7 +177,7 @@ func TestResizer_ResizeImage_InvalidMaxDimension(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_InvalidImageData(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Test with invalid image data
 	invalidData := []byte("not an image")
@@ -184,7 +187,7 @@ func TestResizer_ResizeImage_InvalidImageData(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_ExactThreshold(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create an image exactly at the threshold (1500x1500)
 	img := createTestImage(1500, 1500)
@@ -198,7 +201,7 @@ func TestResizer_ResizeImage_ExactThreshold(t *testing.T) {
 }
 
 func TestResizer_ResizeImage_JustOverThreshold(t *testing.T) {
-	r := New()
+	r := New(Config{})
 
 	// Create an image just over the threshold (1501x1501)
 	img := createTestImage(1501, 1501)
@@ -218,3 +221,36 @@ func TestResizer_ResizeImage_JustOverThreshold(t *testing.T) {
 	assert.Equal(t, 1500, bounds.Dy(), "Height should be 1500")
 }
 
+func TestResizer_ResizeImage_ConvertedFormats(t *testing.T) {
+	r := New(Config{})
+
+	for _, test := range []struct {
+		format string
+		encode func(w io.Writer, img image.Image) error
+	}{
+		{format: "bmp", encode: bmp.Encode},
+		{format: "tiff", encode: func(w io.Writer, img image.Image) error { return tiff.Encode(w, img, nil) }},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			// Small images are converted to PNG, since the APIs do not accept them
+			var buf bytes.Buffer
+			assert.NoError(t, test.encode(&buf, createTestImage(100, 50)))
+			result, err := r.ResizeImage(buf.Bytes(), 1500)
+			assert.NoError(t, err)
+			decoded, format, err := r.decodeImage(result)
+			assert.NoError(t, err)
+			assert.Equal(t, "png", format)
+			assert.Equal(t, image.Rect(0, 0, 100, 50), decoded.Bounds())
+
+			// Large images are resized as well
+			buf.Reset()
+			assert.NoError(t, test.encode(&buf, createTestImage(3000, 2000)))
+			result, err = r.ResizeImage(buf.Bytes(), 1500)
+			assert.NoError(t, err)
+			decoded, format, err = r.decodeImage(result)
+			assert.NoError(t, err)
+			assert.Equal(t, "png", format)
+			assert.Equal(t, image.Rect(0, 0, 1500, 1000), decoded.Bounds())
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package resizer
+
+import (
+	"image"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+)
+
+const (
+	// rotationSampleSize is the longest side of the copy of a page that rotation is detected on
+	rotationSampleSize = 800
+	// lineContrast is how much more uneven the projection of the ink must be in one direction than in the other
+	// before the page is considered to be turned by 90 degrees
+	lineContrast = 1.5
+	// ascenderMargin is how much more ink must hang below the text lines than above them
+	// before the page is considered to be upside down
+	ascenderMargin = 1.2
+	// minInk is the fewest ink pixels a page needs for its rotation to be detected
+	minInk = 200
+)
+
+// detectRotation returns the clockwise rotation, 0, 90, 180 or 270 degrees, that turns a page of text upright.
+// Text lines run along the direction in which the ink projects to the most uneven profile, and the ascenders of Latin
+// script outnumber its descenders, so upright lines carry more ink above their core than below it.
+// It returns 0 when the page does not have enough text to tell.
+func detectRotation(img image.Image) int {
+	ink := inkMask(img)
+	if ink == nil {
+		return 0
+	}
+
+	rows, cols := ink.profiles()
+	rotation := 0
+	if contrast(cols) > lineContrast*contrast(rows) {
+		// The lines run from top to bottom, so the page is turned by 90 degrees in one direction or the other
+		ink = ink.rotate90()
+		rows, _ = ink.profiles()
+		rotation = 90
+	}
+
+	above, below := ascenders(rows)
+	if float64(below) > ascenderMargin*float64(above) {
+		rotation += 180
+	}
+	return rotation
+}
+
+// mask is a binarized page where true marks ink
+type mask struct {
+	w, h int
+	ink  []bool
+}
+
+// inkMask scales the page down to at most rotationSampleSize pixels, converts it to grayscale
+// and separates the ink from the paper with Otsu's threshold. It returns nil when there is too little ink.
+func inkMask(img image.Image) *mask {
+	if img.Bounds().Empty() {
+		return nil
+	}
+	gray := imaging.Sample(img, rotationSampleSize)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+
+	threshold := imaging.OtsuThreshold(gray.Pix)
+	m := &mask{w: w, h: h, ink: make([]bool, w*h)}
+	count := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if gray.Pix[y*gray.Stride+x] < threshold {
+				m.ink[y*w+x] = true
+				count++
+			}
+		}
+	}
+	// A blank page, or a page that is mostly ink, has no text lines to go by
+	if count < minInk || count > w*h/2 {
+		return nil
+	}
+	return m
+}
+
+// profiles counts the ink of each row and each column
+func (m *mask) profiles() (rows, cols []int) {
+	rows, cols = make([]int, m.h), make([]int, m.w)
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			if m.ink[y*m.w+x] {
+				rows[y]++
+				cols[x]++
+			}
+		}
+	}
+	return rows, cols
+}
+
+// contrast measures how unevenly the ink of a projection profile is spread, as its variance relative to its mean.
+// Text lines alternate with the gaps between them, so the profile across them is uneven, while the profile along
+// them evens out over the letters of all the lines.
+func contrast(profile []int) float64 {
+	if len(profile) == 0 {
+		return 0
+	}
+	var sum, squares float64
+	for _, v := range profile {
+		sum += float64(v)
+		squares += float64(v) * float64(v)
+	}
+	mean := sum / float64(len(profile))
+	if mean == 0 {
+		return 0
+	}
+	return (squares/float64(len(profile)) - mean*mean) / (mean * mean)
+}
+
+// rotate90 turns the mask clockwise by 90 degrees
+func (m *mask) rotate90() *mask {
+	r := &mask{w: m.h, h: m.w, ink: make([]bool, len(m.ink))}
+	for y := 0; y < m.h; y++ {
+		for x := 0; x < m.w; x++ {
+			r.ink[x*r.w+(m.h-1-y)] = m.ink[y*m.w+x]
+		}
+	}
+	return r
+}
+
+// ascenders sums the ink above and below the core of each text line. A line is a run of rows with more ink than
+// the noise between lines, and its core, the height of its lowercase letters, is the rows with at least half of
+// its densest row's ink.
+func ascenders(rows []int) (above, below int) {
+	noise := 0
+	for _, v := range rows {
+		noise = max(noise, v/20)
+	}
+	for start := 0; start < len(rows); {
+		if rows[start] <= noise {
+			start++
+			continue
+		}
+		end := start
+		peak := 0
+		for end < len(rows) && rows[end] > noise {
+			peak = max(peak, rows[end])
+			end++
+		}
+
+		coreStart, coreEnd := -1, -1
+		for y := start; y < end; y++ {
+			if 2*rows[y] >= peak {
+				if coreStart < 0 {
+					coreStart = y
+				}
+				coreEnd = y
+			}
+		}
+		for y := start; y < coreStart; y++ {
+			above += rows[y]
+		}
+		for y := coreEnd + 1; y < end; y++ {
+			below += rows[y]
+		}
+		start = end
+	}
+	return above, below
+}
@@ -0,0 +1,47 @@
+package resizer
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/marksalpeter/ocr/internal/ocr/spread"
+)
+
+// SplitSpread returns the left and right pages of an open-book spread, cut at its gutter, or the image alone when
+// it shows a single page. The image is turned upright following its EXIF orientation before the gutter is searched,
+// and its pages are encoded in the format the image would be sent in. Images that are too narrow to be a spread,
+// either way up, are returned without being decoded.
+func (r *Resizer) SplitSpread(imageData []byte) ([][]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image format or invalid image data: %w", err)
+	}
+	if float64(max(config.Width, config.Height)) < spread.MinAspect*float64(min(config.Width, config.Height)) {
+		return [][]byte{imageData}, nil
+	}
+
+	img, format, err := r.decodeImage(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	img = applyOrientation(img, orientation(imageData, format))
+	gutter, ok := spread.Find(img)
+	if !ok {
+		return [][]byte{imageData}, nil
+	}
+
+	bounds := img.Bounds()
+	pages := make([][]byte, 0, 2)
+	for _, page := range []image.Rectangle{
+		image.Rect(bounds.Min.X, bounds.Min.Y, gutter, bounds.Max.Y),
+		image.Rect(gutter, bounds.Min.Y, bounds.Max.X, bounds.Max.Y),
+	} {
+		data, err := r.encodeImage(crop(img, page), format)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, data)
+	}
+	return pages, nil
+}
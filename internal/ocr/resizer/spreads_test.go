@@ -0,0 +1,56 @@
+package resizer
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// spreadImage draws two facing pages with rows of ink, split by the shadow of the binding at x 280
+func spreadImage(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(230)
+			switch {
+			case x >= 270 && x < 290:
+				v = 90
+			case y%20 < 4 && x%280 > 20 && x%280 < 250:
+				v = 20
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+	return img
+}
+
+func TestResizer_SplitSpread(t *testing.T) {
+	r := New(Config{})
+
+	// The pages are cut at the gutter, left before right
+	data, err := encodePNG(spreadImage(600, 400))
+	require.NoError(t, err)
+	pages, err := r.SplitSpread(data)
+	require.NoError(t, err)
+	require.Len(t, pages, 2)
+	for i, width := range []int{280, 320} {
+		img, err := png.Decode(bytes.NewReader(pages[i]))
+		require.NoError(t, err)
+		assert.InDelta(t, width, img.Bounds().Dx(), 3)
+		assert.Equal(t, 400, img.Bounds().Dy())
+	}
+
+	// A single page is returned as it is
+	data, err = encodePNG(spreadImage(300, 400))
+	require.NoError(t, err)
+	pages, err = r.SplitSpread(data)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{data}, pages)
+
+	_, err = r.SplitSpread([]byte("not an image"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,64 @@
+package resizer
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// tileOverlap is the share of the size of each tile that it shares with the next one, so a line of text cut
+// at the edge of a tile is whole in the tile next to it
+const tileOverlap = 0.15
+
+// tileBands splits the bounds into overlapping horizontal bands, from top to bottom, that span the full width and
+// are at most maxDimension high. There are at least minBands bands, and more when the height needs them.
+func tileBands(bounds image.Rectangle, minBands, maxDimension int) []image.Rectangle {
+	spans := tileSpans(bounds.Min.Y, bounds.Max.Y, tileCount(bounds.Dy(), minBands, maxDimension))
+
+	bands := make([]image.Rectangle, 0, len(spans))
+	for _, span := range spans {
+		bands = append(bands, image.Rect(bounds.Min.X, span[0], bounds.Max.X, span[1]))
+	}
+	return bands
+}
+
+// tileCount returns the fewest tiles, and at least minimum, that split a length into overlapping spans
+// of at most maxDimension
+func tileCount(length, minimum, maxDimension int) int {
+	n := max(minimum, 1)
+	for tileSize(length, n) > maxDimension {
+		n++
+	}
+	return n
+}
+
+// tileSize returns the size of each of n spans of equal size that split a length and overlap by tileOverlap
+func tileSize(length, n int) int {
+	return int(math.Ceil(float64(length) / (float64(n) - float64(n-1)*tileOverlap)))
+}
+
+// tileSpans splits the range from start to end into n spans of equal size that overlap by tileOverlap,
+// in order
+func tileSpans(start, end, n int) [][2]int {
+	size := tileSize(end-start, n)
+	step := float64(size) * (1 - tileOverlap)
+
+	spans := make([][2]int, 0, n)
+	for i := 0; i < n; i++ {
+		from := start + int(math.Round(float64(i)*step))
+		to := min(end, from+size)
+		if i == n-1 {
+			from, to = max(start, end-size), end
+		}
+		spans = append(spans, [2]int{from, to})
+	}
+	return spans
+}
+
+// crop copies a rectangle of an image into an image of its own
+func crop(img image.Image, rect image.Rectangle) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
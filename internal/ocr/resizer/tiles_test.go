@@ -0,0 +1,89 @@
+package resizer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTileBands(t *testing.T) {
+	// A narrow page is split into the bands asked for
+	bands := tileBands(image.Rect(0, 0, 1000, 3000), 4, 1500)
+	assert.Len(t, bands, 4)
+	assert.Equal(t, 0, bands[0].Min.Y)
+	assert.Equal(t, 3000, bands[3].Max.Y)
+	for i, band := range bands {
+		assert.Equal(t, 0, band.Min.X)
+		assert.Equal(t, 1000, band.Max.X)
+		assert.Equal(t, 846, band.Dy(), "band %d", i)
+		if i > 0 {
+			// Each band overlaps the one above it
+			assert.GreaterOrEqual(t, bands[i-1].Max.Y-band.Min.Y, 126, "band %d", i)
+		}
+	}
+
+	// A large page is split into more bands, so no band is higher than the max dimension,
+	// and each band still spans the full width so lines of text are never cut across
+	bands = tileBands(image.Rect(0, 0, 4000, 6000), 3, 1500)
+	assert.Len(t, bands, 5)
+	for _, band := range bands {
+		assert.Equal(t, 0, band.Min.X)
+		assert.Equal(t, 4000, band.Max.X)
+		assert.LessOrEqual(t, band.Dy(), 1500)
+	}
+	assert.Equal(t, 6000, bands[4].Max.Y)
+
+	assert.Equal(t, []image.Rectangle{image.Rect(0, 0, 10, 20)}, tileBands(image.Rect(0, 0, 10, 20), 1, 1500))
+}
+
+func TestResizer_TileImage(t *testing.T) {
+	// Each row of the page has its own shade, so the bands can be told apart
+	page := image.NewGray(image.Rect(0, 0, 1000, 3000))
+	for y := 0; y < 3000; y++ {
+		for x := 0; x < 1000; x++ {
+			page.SetGray(x, y, color.Gray{Y: uint8(y / 12)})
+		}
+	}
+	data, err := encodePNG(page)
+	assert.NoError(t, err)
+
+	r := New(Config{Tiles: 4})
+	tiles, err := r.TileImage(data, 1500)
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 4)
+
+	// The bands are cut at full resolution
+	first, format, err := r.decodeImage(tiles[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, image.Rect(0, 0, 1000, 846), first.Bounds())
+	assert.Equal(t, uint8(0), color.GrayModel.Convert(first.At(500, 0)).(color.Gray).Y)
+	last, _, err := r.decodeImage(tiles[3])
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(249), color.GrayModel.Convert(last.At(500, last.Bounds().Dy()-1)).(color.Gray).Y)
+
+	// A smaller max dimension splits the page into more bands, which span the full width
+	// and are downscaled when they are wider than the max dimension
+	tiles, err = r.TileImage(data, 500)
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 7)
+	for _, tile := range tiles {
+		img, _, err := r.decodeImage(tile)
+		assert.NoError(t, err)
+		assert.Equal(t, 500, img.Bounds().Dx())
+		assert.LessOrEqual(t, img.Bounds().Dy(), 500)
+	}
+	bottom, _, err := r.decodeImage(tiles[6])
+	assert.NoError(t, err)
+	assert.InDelta(t, 249, color.GrayModel.Convert(bottom.At(250, bottom.Bounds().Dy()-1)).(color.Gray).Y, 1)
+
+	// Without tiling the image is resized whole
+	tiles, err = New(Config{}).TileImage(data, 1500)
+	assert.NoError(t, err)
+	assert.Len(t, tiles, 1)
+	whole, _, err := r.decodeImage(tiles[0])
+	assert.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 500, 1500), whole.Bounds())
+}
@@ -0,0 +1,159 @@
+// Package spread finds the gutter of open-book spreads, the photos and scans that show two facing pages.
+package spread
+
+import (
+	"image"
+	"math"
+	"slices"
+
+	"github.com/marksalpeter/ocr/internal/ocr/imaging"
+)
+
+const (
+	// MinAspect is the smallest ratio of width to height of a spread. Two facing portrait pages are about 1.4 times
+	// as wide as they are high, so images that are narrower than this show a single page.
+	MinAspect = 1.2
+	// sampleSize is the longest side of the copy of an image that the gutter is found on
+	sampleSize = 800
+	// gutterRange is the share of the width on each side of the center that the gutter is searched in
+	gutterRange = 0.15
+	// gutterShadow is how much darker than the paper of both pages the shadow of the binding must be
+	gutterShadow = 0.15
+	// minGap is the narrowest blank band between the text of the two pages, as a share of the width
+	minGap = 0.02
+	// minText is the smallest share of the columns of each page that must have text when the pages are
+	// told apart by the blank band between them
+	minText = 0.2
+)
+
+// Find returns the x coordinate of the gutter between the two pages of a spread, or false when the image shows
+// a single page. The gutter is the shadow of the binding near the center of the image, or else a blank band
+// near the center between two pages of text.
+func Find(img image.Image) (int, bool) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if h == 0 || float64(w)/float64(h) < MinAspect {
+		return 0, false
+	}
+	// The image is wider than it is high, so the sample is sampleSize wide
+	gray := imaging.Sample(img, sampleSize)
+	sw, sh := gray.Rect.Dx(), gray.Rect.Dy()
+	scale := float64(sw) / float64(w)
+
+	// The top and bottom of a photo often show the desk, so only the middle rows are measured
+	top, bottom := sh/10, sh-sh/10
+	means, ink := profiles(gray, top, bottom)
+	from, to := int(float64(sw)*(0.5-gutterRange)), int(float64(sw)*(0.5+gutterRange))
+
+	x, ok := shadow(means, from, to)
+	if !ok {
+		x, ok = gap(ink, from, to, (bottom-top)/200)
+	}
+	if !ok {
+		return 0, false
+	}
+	return bounds.Min.X + int((float64(x)+0.5)/scale), true
+}
+
+// profiles returns the mean brightness of each column and the number of its ink pixels, between the rows top
+// and bottom. Ink is darker than Otsu's threshold.
+func profiles(gray *image.Gray, top, bottom int) (means []float64, ink []int) {
+	w := gray.Rect.Dx()
+	threshold := imaging.OtsuThreshold(gray.Pix)
+	means, ink = make([]float64, w), make([]int, w)
+	for y := top; y < bottom; y++ {
+		for x, v := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			means[x] += float64(v)
+			if v < threshold {
+				ink[x]++
+			}
+		}
+	}
+	for x := range means {
+		means[x] /= float64(max(1, bottom-top))
+	}
+	return means, ink
+}
+
+// shadow returns the middle of the darkest valley between from and to, when it is darker than the typical paper
+// of both pages by gutterShadow. The brightness of each column is averaged with its neighbors, so a single line
+// of text is not taken for the binding.
+func shadow(means []float64, from, to int) (int, bool) {
+	w := len(means)
+	radius := max(1, w/200)
+	smoothed := make([]float64, w)
+	for x := range means {
+		var sum float64
+		lo, hi := max(0, x-radius), min(w, x+radius+1)
+		for _, v := range means[lo:hi] {
+			sum += v
+		}
+		smoothed[x] = sum / float64(hi-lo)
+	}
+
+	darkest := from
+	for x := from; x < to; x++ {
+		if smoothed[x] < smoothed[darkest] {
+			darkest = x
+		}
+	}
+	paper := math.Min(median(means[w/10:from]), median(means[to:w-w/10]))
+	if smoothed[darkest] >= (1-gutterShadow)*paper {
+		return 0, false
+	}
+
+	// A wide shadow is cut in its middle, where it is within a quarter of its depth of its darkest column
+	limit := smoothed[darkest] + (paper-smoothed[darkest])/4
+	start, end := darkest, darkest
+	for start > from && smoothed[start-1] <= limit {
+		start--
+	}
+	for end < to-1 && smoothed[end+1] <= limit {
+		end++
+	}
+	return (start + end) / 2, true
+}
+
+// gap returns the center of the widest run of columns between from and to without ink, when it is at least minGap
+// wide and both pages beside it have text
+func gap(ink []int, from, to, noise int) (int, bool) {
+	w := len(ink)
+	start, end := 0, 0
+	for x := from; x < to; {
+		if ink[x] > noise {
+			x++
+			continue
+		}
+		run := x
+		for x < to && ink[x] <= noise {
+			x++
+		}
+		if x-run > end-start {
+			start, end = run, x
+		}
+	}
+	if float64(end-start) < minGap*float64(w) {
+		return 0, false
+	}
+
+	text := func(columns []int) bool {
+		count := 0
+		for _, n := range columns {
+			if n > noise {
+				count++
+			}
+		}
+		return float64(count) >= minText*float64(len(columns))
+	}
+	return (start + end) / 2, text(ink[:start]) && text(ink[end:])
+}
+
+// median returns the middle value of a profile, or 0 when it is empty
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
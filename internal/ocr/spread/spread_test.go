@@ -0,0 +1,84 @@
+package spread
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// writePage writes lines of text on the page at the given rectangle of the image
+func writePage(img draw.Image, page image.Rectangle) {
+	lines := []string{
+		"Monday. Rain all morning, the garden is soaked",
+		"and the path to the gate has turned to mud.",
+		"Wrote to Agnes about the harvest and the fair.",
+		"Tuesday. Clear skies at last, walked to town",
+		"and bought thread, candles and a new notebook.",
+	}
+	drawer := font.Drawer{Dst: img, Src: image.NewUniform(color.Black), Face: basicfont.Face7x13}
+	for i := 0; i < 20; i++ {
+		drawer.Dot = fixed.P(page.Min.X+20, page.Min.Y+40+20*i)
+		drawer.DrawString(lines[i%len(lines)])
+	}
+}
+
+// spreadImage returns two facing 360x480 pages of text, with the shadow of the binding between them when shaded
+func spreadImage(shaded bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 720, 480))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 230}), image.Point{}, draw.Src)
+	if shaded {
+		for x := 340; x < 380; x++ {
+			depth := 20 - x + 340
+			if x >= 360 {
+				depth = x - 359
+			}
+			shade := color.Gray{Y: uint8(100 + depth*6)}
+			draw.Draw(img, image.Rect(x, 0, x+1, 480), image.NewUniform(shade), image.Point{}, draw.Src)
+		}
+	}
+	writePage(img, image.Rect(0, 0, 360, 480))
+	writePage(img, image.Rect(360, 0, 720, 480))
+	return img
+}
+
+func TestFind(t *testing.T) {
+	t.Run("shadow of the binding", func(t *testing.T) {
+		gutter, ok := Find(spreadImage(true))
+		assert.True(t, ok)
+		assert.InDelta(t, 360, gutter, 4)
+	})
+
+	t.Run("blank band between the pages", func(t *testing.T) {
+		gutter, ok := Find(spreadImage(false))
+		assert.True(t, ok)
+		assert.InDelta(t, 360, gutter, 25)
+	})
+
+	t.Run("offset bounds", func(t *testing.T) {
+		img := spreadImage(true).SubImage(image.Rect(10, 0, 720, 480))
+		gutter, ok := Find(img)
+		assert.True(t, ok)
+		assert.InDelta(t, 360, gutter, 4)
+	})
+
+	t.Run("single landscape page", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 720, 480))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.Gray{Y: 230}), image.Point{}, draw.Src)
+		writePage(img, image.Rect(200, 0, 720, 480))
+		writePage(img, image.Rect(0, 0, 720, 480))
+		_, ok := Find(img)
+		assert.False(t, ok)
+	})
+
+	t.Run("portrait page", func(t *testing.T) {
+		img := spreadImage(true).SubImage(image.Rect(0, 0, 360, 480))
+		_, ok := Find(img)
+		assert.False(t, ok)
+	})
+}
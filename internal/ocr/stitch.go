@@ -0,0 +1,108 @@
+package ocr
+
+import "strings"
+
+const (
+	// stitchSkip is how many lines at the edge of a band may be left out of the overlap, since a line cut by the
+	// edge is read in part, or not at all, in the band where it is cut
+	stitchSkip = 2
+	// stitchMinLine is the fewest characters a line needs to be taken for the whole overlap on its own,
+	// so short lines such as a dash or a single word are not mistaken for it
+	stitchMinLine = 12
+	// stitchDistance is the share of the characters of two lines that may differ for them to be the same line,
+	// since the same line is not always read the same way twice
+	stitchDistance = 0.1
+)
+
+// stitch joins the transcriptions of the bands of an image, from top to bottom. The lines in the overlap of two
+// bands are in both transcriptions, so the lines at the end of one that are repeated at the start of the next
+// are kept once.
+func stitch(texts []string) string {
+	if len(texts) == 0 {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(texts[0]), "\n")
+	for _, text := range texts[1:] {
+		lines = stitchLines(lines, strings.Split(strings.TrimSpace(text), "\n"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// stitchLines joins the lines of two bands at the longest run of lines that ends within stitchSkip lines of the
+// end of the top band and starts within stitchSkip lines of the start of the bottom band. The lines of the
+// top band after the run and of the bottom band before it are cut by the edge of their band, so they are
+// dropped. Blank lines are not compared. The bands are joined as they are when they have no lines in common.
+func stitchLines(top, bottom []string) []string {
+	topLines, bottomLines := textLines(top), textLines(bottom)
+
+	bestLength, bestTop, bestBottom := 0, 0, 0
+	for i := range topLines {
+		for j := 0; j < len(bottomLines) && j <= stitchSkip; j++ {
+			length := 0
+			for i+length < len(topLines) && j+length < len(bottomLines) && sameLine(top[topLines[i+length]], bottom[bottomLines[j+length]]) {
+				length++
+			}
+			if length == 0 || i+length < len(topLines)-stitchSkip {
+				continue
+			}
+			if length == 1 && len([]rune(normalizeLine(top[topLines[i]]))) < stitchMinLine {
+				continue
+			}
+			if length > bestLength {
+				bestLength, bestTop, bestBottom = length, i, j
+			}
+		}
+	}
+	if bestLength == 0 {
+		return append(top, bottom...)
+	}
+
+	joined := append([]string{}, top[:topLines[bestTop+bestLength-1]+1]...)
+	return append(joined, bottom[bottomLines[bestBottom+bestLength-1]+1:]...)
+}
+
+// textLines returns the indexes of the lines that are not blank
+func textLines(lines []string) []int {
+	var indexes []int
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// sameLine reports whether two lines are transcriptions of the same line, ignoring case and spacing,
+// with at most stitchDistance of their characters read differently
+func sameLine(a, b string) bool {
+	ra, rb := []rune(normalizeLine(a)), []rune(normalizeLine(b))
+	if string(ra) == string(rb) {
+		return true
+	}
+	return float64(editDistance(ra, rb)) <= stitchDistance*float64(max(len(ra), len(rb)))
+}
+
+// normalizeLine lowercases a line and collapses its spaces
+func normalizeLine(line string) string {
+	return strings.ToLower(strings.Join(strings.Fields(line), " "))
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b []rune) int {
+	previous, current := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,77 @@
+package ocr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStitch(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  string
+	}{
+		{
+			name:  "single band",
+			texts: []string{"March 3, 1921\nPaid the grocer 4s 6d"},
+			want:  "March 3, 1921\nPaid the grocer 4s 6d",
+		},
+		{
+			name: "overlapping lines are kept once",
+			texts: []string{
+				"March 3, 1921\nPaid the grocer 4s 6d\nCoal for the week 2s 1d\nRent to Mr Hale 12s",
+				"Coal for the week 2s 1d\nRent to Mr Hale 12s\nStamps and paper 9d",
+			},
+			want: "March 3, 1921\nPaid the grocer 4s 6d\nCoal for the week 2s 1d\nRent to Mr Hale 12s\nStamps and paper 9d",
+		},
+		{
+			name: "lines cut by the edge of a band are dropped",
+			texts: []string{
+				"Paid the grocer 4s 6d\nCoal for the week 2s 1d\nRent to Mr Hale 12s\nStamps an",
+				"for the week 2s 1d\nRent to Mr Hale 12s\nStamps and paper 9d",
+			},
+			want: "Paid the grocer 4s 6d\nCoal for the week 2s 1d\nRent to Mr Hale 12s\nStamps and paper 9d",
+		},
+		{
+			name: "lines read slightly differently still match",
+			texts: []string{
+				"Paid the grocer 4s 6d\nCoal for the week 2s 1d",
+				"Coal for the weck 2s 1d.\nStamps and paper 9d",
+			},
+			want: "Paid the grocer 4s 6d\nCoal for the week 2s 1d\nStamps and paper 9d",
+		},
+		{
+			name: "three bands",
+			texts: []string{
+				"one line of the ledger\ntwo lines of the ledger",
+				"two lines of the ledger\nthree lines of the ledger",
+				"three lines of the ledger\nfour lines of the ledger",
+			},
+			want: "one line of the ledger\ntwo lines of the ledger\nthree lines of the ledger\nfour lines of the ledger",
+		},
+		{
+			name:  "short lines alone are not an overlap",
+			texts: []string{"Total\n--", "--\nCarried forward"},
+			want:  "Total\n--\n--\nCarried forward",
+		},
+		{
+			name:  "bands without lines in common are joined",
+			texts: []string{"Paid the grocer 4s 6d", "", "Stamps and paper 9d"},
+			want:  "Paid the grocer 4s 6d\n\nStamps and paper 9d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stitch(tt.texts))
+		})
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance([]rune("ledger"), []rune("ledger")))
+	assert.Equal(t, 1, editDistance([]rune("ledger"), []rune("ledqer")))
+	assert.Equal(t, 3, editDistance([]rune("kitten"), []rune("sitting")))
+	assert.Equal(t, 4, editDistance(nil, []rune("page")))
+}